@@ -14,10 +14,76 @@ CREATE TABLE IF NOT EXISTS users  (
 
 CREATE TABLE IF NOT EXISTS tokens (
     spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
-    refresh VARCHAR(200) NOT NULL,
+    refresh VARCHAR(400) NOT NULL,
     UNIQUE(refresh)
 );
 
+CREATE TABLE IF NOT EXISTS obscurity_snapshots (
+    spotify_id VARCHAR(200) NOT NULL,
+    time_range VARCHAR(20) NOT NULL,
+    score DECIMAL(5,2) NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (spotify_id, time_range)
+);
+
+CREATE TABLE IF NOT EXISTS taste_snapshots (
+    spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
+    snapshot MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pairings (
+    code VARCHAR(36) NOT NULL PRIMARY KEY,
+    inviter_id VARCHAR(200) NOT NULL,
+    invitee_id VARCHAR(200) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at DATETIME NULL,
+    INDEX (inviter_id),
+    INDEX (invitee_id)
+);
+
+CREATE TABLE IF NOT EXISTS blends (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    creator_id VARCHAR(200) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    size INT NOT NULL,
+    recommendations INT NOT NULL DEFAULT 0,
+    refresh_hours INT NOT NULL,
+    playlist_id VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refreshed_at DATETIME NULL,
+    failed_refreshes INT NOT NULL DEFAULT 0,
+    failed_at DATETIME NULL,
+    INDEX (status)
+);
+
+CREATE TABLE IF NOT EXISTS blend_members (
+    blend_id VARCHAR(36) NOT NULL,
+    spotify_id VARCHAR(200) NOT NULL,
+    joined_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blend_id, spotify_id)
+);
+
+CREATE TABLE IF NOT EXISTS shares (
+    slug VARCHAR(32) NOT NULL PRIMARY KEY,
+    spotify_id VARCHAR(200) NOT NULL,
+    share MEDIUMTEXT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    INDEX (spotify_id)
+);
+CREATE TABLE IF NOT EXISTS lyrics (
+    artist VARCHAR(255) NOT NULL,
+    track VARCHAR(255) NOT NULL,
+    words TEXT NOT NULL,
+    found BOOLEAN NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    PRIMARY KEY (artist, track)
+);
+
 
 CREATE DATABASE IF NOT EXISTS spotify_views_development;
 USE spotify_views_development;
@@ -32,10 +98,76 @@ CREATE TABLE IF NOT EXISTS users (
 
 CREATE TABLE IF NOT EXISTS tokens (
     spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
-    refresh VARCHAR(200) NOT NULL,
+    refresh VARCHAR(400) NOT NULL,
     UNIQUE(refresh)
 );
 
+CREATE TABLE IF NOT EXISTS obscurity_snapshots (
+    spotify_id VARCHAR(200) NOT NULL,
+    time_range VARCHAR(20) NOT NULL,
+    score DECIMAL(5,2) NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (spotify_id, time_range)
+);
+
+CREATE TABLE IF NOT EXISTS taste_snapshots (
+    spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
+    snapshot MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pairings (
+    code VARCHAR(36) NOT NULL PRIMARY KEY,
+    inviter_id VARCHAR(200) NOT NULL,
+    invitee_id VARCHAR(200) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at DATETIME NULL,
+    INDEX (inviter_id),
+    INDEX (invitee_id)
+);
+
+CREATE TABLE IF NOT EXISTS blends (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    creator_id VARCHAR(200) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    size INT NOT NULL,
+    recommendations INT NOT NULL DEFAULT 0,
+    refresh_hours INT NOT NULL,
+    playlist_id VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refreshed_at DATETIME NULL,
+    failed_refreshes INT NOT NULL DEFAULT 0,
+    failed_at DATETIME NULL,
+    INDEX (status)
+);
+
+CREATE TABLE IF NOT EXISTS blend_members (
+    blend_id VARCHAR(36) NOT NULL,
+    spotify_id VARCHAR(200) NOT NULL,
+    joined_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blend_id, spotify_id)
+);
+
+CREATE TABLE IF NOT EXISTS shares (
+    slug VARCHAR(32) NOT NULL PRIMARY KEY,
+    spotify_id VARCHAR(200) NOT NULL,
+    share MEDIUMTEXT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    INDEX (spotify_id)
+);
+CREATE TABLE IF NOT EXISTS lyrics (
+    artist VARCHAR(255) NOT NULL,
+    track VARCHAR(255) NOT NULL,
+    words TEXT NOT NULL,
+    found BOOLEAN NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    PRIMARY KEY (artist, track)
+);
+
 CREATE DATABASE IF NOT EXISTS spotify_views_test;
 USE spotify_views_test;
 
@@ -48,6 +180,72 @@ CREATE TABLE IF NOT EXISTS users (
 
 CREATE TABLE IF NOT EXISTS tokens (
     spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
-    refresh VARCHAR(200) NOT NULL,
+    refresh VARCHAR(400) NOT NULL,
     UNIQUE(refresh)
+);
+
+CREATE TABLE IF NOT EXISTS obscurity_snapshots (
+    spotify_id VARCHAR(200) NOT NULL,
+    time_range VARCHAR(20) NOT NULL,
+    score DECIMAL(5,2) NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (spotify_id, time_range)
+);
+
+CREATE TABLE IF NOT EXISTS taste_snapshots (
+    spotify_id VARCHAR(200) NOT NULL PRIMARY KEY,
+    snapshot MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pairings (
+    code VARCHAR(36) NOT NULL PRIMARY KEY,
+    inviter_id VARCHAR(200) NOT NULL,
+    invitee_id VARCHAR(200) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at DATETIME NULL,
+    INDEX (inviter_id),
+    INDEX (invitee_id)
+);
+
+CREATE TABLE IF NOT EXISTS blends (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    creator_id VARCHAR(200) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    size INT NOT NULL,
+    recommendations INT NOT NULL DEFAULT 0,
+    refresh_hours INT NOT NULL,
+    playlist_id VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refreshed_at DATETIME NULL,
+    failed_refreshes INT NOT NULL DEFAULT 0,
+    failed_at DATETIME NULL,
+    INDEX (status)
+);
+
+CREATE TABLE IF NOT EXISTS blend_members (
+    blend_id VARCHAR(36) NOT NULL,
+    spotify_id VARCHAR(200) NOT NULL,
+    joined_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blend_id, spotify_id)
+);
+
+CREATE TABLE IF NOT EXISTS shares (
+    slug VARCHAR(32) NOT NULL PRIMARY KEY,
+    spotify_id VARCHAR(200) NOT NULL,
+    share MEDIUMTEXT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    INDEX (spotify_id)
+);
+CREATE TABLE IF NOT EXISTS lyrics (
+    artist VARCHAR(255) NOT NULL,
+    track VARCHAR(255) NOT NULL,
+    words TEXT NOT NULL,
+    found BOOLEAN NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    PRIMARY KEY (artist, track)
 );
\ No newline at end of file
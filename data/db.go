@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type DB interface {
-	Exec(context.Context, string) (sql.Result, error)
+	Exec(context.Context, string, ...interface{}) (sql.Result, error)
+	Select(context.Context, interface{}, string, ...interface{}) error
 }
 
 type LiveDB struct {
@@ -24,31 +28,88 @@ func GetLiveDB(conn string) (*LiveDB, error) {
 	return &LiveDB{db: db}, nil
 }
 
-func (db *LiveDB) Exec(ctx context.Context, sql string) (sql.Result, error) {
+func (db *LiveDB) Exec(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
 	conn, err := db.db.Conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	res, err := conn.ExecContext(ctx, sql)
+	res, err := conn.ExecContext(ctx, sql, args...)
 	return res, err
 }
 
+// Select runs the query and scans every row into dest, which should be a
+// pointer to a slice.
+func (db *LiveDB) Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return db.db.SelectContext(ctx, dest, sql, args...)
+}
+
+// TestResult is the result of an Exec on a TestDB, reporting its value as
+// the rows affected
+type TestResult int64
+
+func (r TestResult) LastInsertId() (int64, error) { return 0, nil }
+func (r TestResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+// TestDB is a fake database for tests. Select returns the rows stored under
+// its arguments joined by spaces, falling back to the rows stored under "",
+// as long as they're the same type as the destination. Every Exec is
+// recorded and reports Affected rows, unless there's a result to return.
 type TestDB struct {
-	shouldExecErr bool
-	execResult    *sql.Result
+	shouldExecErr   bool
+	execResult      *sql.Result
+	shouldSelectErr bool
+
+	Rows     map[string]interface{}
+	Affected int64
+	// Err is returned by every Exec and Select
+	Err error
+	// Args are the arguments of the last Exec or Select
+	Args  []interface{}
+	Execs [][]interface{}
 }
 
-func (db *TestDB) Exec(ctx context.Context, sql string) (sql.Result, error) {
+func (db *TestDB) Exec(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
+	db.Args = args
+	db.Execs = append(db.Execs, args)
 	if db.shouldExecErr {
 		return nil, errors.New("test error")
 	}
 
-	res := db.execResult
-	if res == nil {
-		return nil, errors.New("no result from sql")
+	if db.Err != nil {
+		return nil, db.Err
+	}
+
+	if db.execResult != nil {
+		return *db.execResult, nil
+	}
+
+	return TestResult(db.Affected), nil
+}
+
+func (db *TestDB) Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	db.Args = args
+	if db.shouldSelectErr {
+		return errors.New("test error")
+	}
+
+	if db.Err != nil {
+		return db.Err
+	}
+
+	rows, ok := db.Rows[strings.TrimSpace(fmt.Sprintln(args...))]
+	if !ok {
+		rows, ok = db.Rows[""]
+	}
+	if !ok {
+		return nil
+	}
+
+	d := reflect.ValueOf(dest).Elem()
+	if v := reflect.ValueOf(rows); v.Type().AssignableTo(d.Type()) {
+		d.Set(v)
 	}
 
-	return *res, nil
+	return nil
 }
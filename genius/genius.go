@@ -2,24 +2,53 @@ package genius
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bbalet/stopwords"
 	"github.com/mike-webster/spotify-views/keys"
 	"github.com/mike-webster/spotify-views/logging"
+	"github.com/mike-webster/spotify-views/spotify"
 	lyrics "github.com/rhnvrm/lyric-api-go"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// LyricsTTL is how long found lyrics are kept, they rarely change
+	LyricsTTL = 90 * 24 * time.Hour
+	// NotFoundTTL is how long a song that couldn't be found is remembered.
+	// The lyrics package doesn't say why a search failed, so it's short
+	// enough that an outage doesn't hide a song for long.
+	NotFoundTTL = 6 * time.Hour
+	// MaxConcurrentLookups caps how many songs are searched for at once
+	MaxConcurrentLookups = 5
+)
+
 // LyricSearch holds the information for which a lyric search is desired
 type LyricSearch struct {
 	Artist string
 	Track  string
 }
 
+// Searcher finds the lyrics for a song
+type Searcher interface {
+	Search(artist string, track string) (string, error)
+}
+
+// storedLyrics is a search that's been made, and what was found. Only the
+// word counts are kept, as json, not the lyrics themselves.
+type storedLyrics struct {
+	Artist string `db:"artist"`
+	Track  string `db:"track"`
+	Words  string `db:"words"`
+	Found  bool   `db:"found"`
+}
+
 type tempResp struct {
 	Response struct {
 		Hits []struct {
@@ -38,33 +67,57 @@ type tempResp struct {
 // GetLyricCountForSong will retrieve the song lyrics for all of the provided searches
 // and return a map of each word with a value of how many times it occurred.
 //
+// Lyrics that have been searched for before are used without asking genius
+// again, including songs that weren't found. The rest are searched for a few
+// at a time.
+//
 // When single songs are not found, we just log the error and move on.  If we can't
 // do the search at all, the error encountered is returned.
 func GetLyricCountForSong(ctx context.Context, searches []LyricSearch) (map[string]int, error) {
-	logger := logging.GetLogger(ctx)
 	token := keys.GetContextValue(ctx, keys.ContextLyricsToken)
 	if token == nil {
 		return nil, errors.New("no access token provided")
 	}
 
-	maps := []map[string]int{}
 	l := lyrics.New(lyrics.WithoutProviders(), lyrics.WithGeniusLyrics(fmt.Sprint(token)))
+	return getLyricCounts(ctx, &l, searches), nil
+}
 
+// getLyricCounts combines the word counts of the stored songs with the ones
+// the searcher finds
+func getLyricCounts(ctx context.Context, searcher Searcher, searches []LyricSearch) map[string]int {
+	found := getStoredLyrics(ctx, searches)
+	misses := []LyricSearch{}
 	for _, i := range searches {
-		lyric, err := l.Search(i.Artist, i.Track)
-		if err != nil {
-			logger.WithFields(logrus.Fields{
-				"event":  "genius_error",
-				"artist": i.Artist,
-				"track":  i.Track,
-			}).WithError(err).Warn("song not found")
+		if _, ok := found[i.key()]; !ok {
+			found[i.key()] = nil
+			misses = append(misses, i)
+		}
+	}
+
+	fetched := fetchLyrics(ctx, searcher, misses)
+	storeLyrics(ctx, fetched)
+	for _, i := range fetched {
+		found[LyricSearch{Artist: i.Artist, Track: i.Track}] = i
+	}
+
+	maps := []map[string]int{}
+	for _, i := range searches {
+		l := found[i.key()]
+		if l == nil || !l.Found {
 			continue
 		}
 
-		maps = append(maps, convertToMap(ctx, lyric))
+		counts := map[string]int{}
+		if err := json.Unmarshal([]byte(l.Words), &counts); err != nil {
+			logging.GetLogger(ctx).WithField("artist", l.Artist).WithField("track", l.Track).
+				WithError(err).Warn("couldnt parse stored word counts")
+			continue
+		}
+		maps = append(maps, counts)
 	}
 
-	return combineMaps(maps), nil
+	return combineMaps(maps)
 }
 
 func convertToMap(ctx context.Context, lyric string) map[string]int {
@@ -139,3 +192,132 @@ func combineMaps(maps []map[string]int) map[string]int {
 
 	return ret
 }
+
+// key is how the search is stored, so small differences in how a song is
+// named don't search for it again
+func (s LyricSearch) key() LyricSearch {
+	return LyricSearch{
+		Artist: strings.ToLower(strings.TrimSpace(s.Artist)),
+		Track:  strings.ToLower(strings.TrimSpace(s.Track)),
+	}
+}
+
+// getStoredLyrics returns the searches that have been made recently enough
+// to use. Without a database nothing is stored, so everything is searched.
+func getStoredLyrics(ctx context.Context, searches []LyricSearch) map[LyricSearch]*storedLyrics {
+	ret := map[LyricSearch]*storedLyrics{}
+	deps := spotify.GetDependencies(ctx)
+	if deps == nil || deps.DB == nil || len(searches) < 1 {
+		return ret
+	}
+
+	pairs := []string{}
+	args := []interface{}{}
+	for _, i := range searches {
+		k := i.key()
+		pairs = append(pairs, "(?, ?)")
+		args = append(args, k.Artist, k.Track)
+	}
+	args = append(args, int(LyricsTTL.Seconds()), int(NotFoundTTL.Seconds()))
+
+	rows := []storedLyrics{}
+	err := deps.DB.Select(ctx, &rows, fmt.Sprint(`SELECT artist, track, words, found FROM lyrics
+		WHERE (artist, track) IN (`, strings.Join(pairs, ", "), `)
+		AND fetched_at > UTC_TIMESTAMP() - INTERVAL IF(found, ?, ?) SECOND`), args...)
+	if err != nil {
+		logging.GetLogger(ctx).WithError(err).Warn("couldnt retrieve stored lyrics")
+		return ret
+	}
+
+	for i := range rows {
+		ret[LyricSearch{Artist: rows[i].Artist, Track: rows[i].Track}] = &rows[i]
+	}
+
+	return ret
+}
+
+// storeLyrics keeps the searches so they don't need to be made again. If
+// none of them were found it's more likely genius couldn't be reached than
+// that every song is missing, so nothing is kept.
+func storeLyrics(ctx context.Context, fetched []*storedLyrics) {
+	deps := spotify.GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return
+	}
+
+	anyFound := false
+	for _, i := range fetched {
+		anyFound = anyFound || i.Found
+	}
+	if !anyFound {
+		return
+	}
+
+	rows := []string{}
+	args := []interface{}{}
+	for _, i := range fetched {
+		rows = append(rows, "(?, ?, ?, ?, UTC_TIMESTAMP())")
+		args = append(args, i.Artist, i.Track, i.Words, i.Found)
+	}
+
+	_, err := deps.DB.Exec(ctx, fmt.Sprint(`INSERT INTO lyrics (artist, track, words, found, fetched_at) VALUES `,
+		strings.Join(rows, ", "), ` ON DUPLICATE KEY UPDATE words = VALUES(words), found = VALUES(found),
+		fetched_at = VALUES(fetched_at)`), args...)
+	if err != nil {
+		logging.GetLogger(ctx).WithError(err).Warn("couldnt store lyrics")
+	}
+}
+
+// fetchLyrics searches for each song, no more than MaxConcurrentLookups at
+// a time, and counts the words in the lyrics that are found. Searches that
+// weren't made because the request was cancelled are left out, so they
+// aren't remembered as not found.
+func fetchLyrics(ctx context.Context, searcher Searcher, searches []LyricSearch) []*storedLyrics {
+	logger := logging.GetLogger(ctx)
+
+	results := make([]*storedLyrics, len(searches))
+	sem := make(chan struct{}, MaxConcurrentLookups)
+	wg := sync.WaitGroup{}
+	for n, i := range searches {
+		wg.Add(1)
+		go func(n int, i LyricSearch) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			k := i.key()
+			lyric, err := searcher.Search(i.Artist, i.Track)
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"event":  "genius_error",
+					"artist": i.Artist,
+					"track":  i.Track,
+				}).WithError(err).Warn("song not found")
+				results[n] = &storedLyrics{Artist: k.Artist, Track: k.Track}
+				return
+			}
+
+			words, err := json.Marshal(convertToMap(ctx, lyric))
+			if err != nil {
+				logger.WithError(err).Warn("couldnt encode word counts")
+				return
+			}
+
+			results[n] = &storedLyrics{Artist: k.Artist, Track: k.Track, Words: string(words), Found: true}
+		}(n, i)
+	}
+	wg.Wait()
+
+	ret := []*storedLyrics{}
+	for _, i := range results {
+		if i != nil {
+			ret = append(ret, i)
+		}
+	}
+
+	return ret
+}
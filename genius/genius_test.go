@@ -0,0 +1,160 @@
+package genius
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+type testDB struct {
+	rows       []storedLyrics
+	selectArgs []interface{}
+	execs      [][]interface{}
+}
+
+func (db *testDB) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db.execs = append(db.execs, args)
+	return nil, nil
+}
+
+func (db *testDB) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	db.selectArgs = args
+	*(dest.(*[]storedLyrics)) = db.rows
+	return nil
+}
+
+// testSearcher finds the lyrics by track, keeping track of how many
+// searches are running at once
+type testSearcher struct {
+	lyrics map[string]string
+
+	lock      sync.Mutex
+	calls     []string
+	active    int
+	maxActive int
+}
+
+func (s *testSearcher) Search(artist string, track string) (string, error) {
+	s.lock.Lock()
+	s.calls = append(s.calls, track)
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	s.lock.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.lock.Lock()
+	s.active--
+	s.lock.Unlock()
+
+	if l, ok := s.lyrics[track]; ok {
+		return l, nil
+	}
+	return "", errors.New("not found")
+}
+
+func testContext(db *testDB) context.Context {
+	return context.WithValue(context.Background(), keys.ContextDependencies, &spotify.Dependencies{DB: db})
+}
+
+func TestGetLyricCounts(t *testing.T) {
+	db := &testDB{rows: []storedLyrics{
+		{Artist: "blink-182", Track: "dammit", Words: `{"growing":2}`, Found: true},
+		{Artist: "blink-182", Track: "missing"},
+	}}
+	searcher := &testSearcher{lyrics: map[string]string{"Basket Case": "skateboard skateboard"}}
+
+	counts := getLyricCounts(testContext(db), searcher, []LyricSearch{
+		{Artist: " Blink-182", Track: "Dammit "},
+		{Artist: "blink-182", Track: "Missing"},
+		{Artist: "Green Day", Track: "Basket Case"},
+		{Artist: "Green Day", Track: "Nope"},
+	})
+	assert.Equal(t, map[string]int{"growing": 2, "skateboard": 2}, counts)
+
+	// the stored songs are looked up by their normalized names, and found
+	// songs are kept longer than ones that weren't
+	assert.Equal(t, []interface{}{"blink-182", "dammit"}, db.selectArgs[:2])
+	assert.Equal(t, []interface{}{int(LyricsTTL.Seconds()), int(NotFoundTTL.Seconds())}, db.selectArgs[8:])
+	assert.True(t, NotFoundTTL < LyricsTTL)
+
+	// only the songs that weren't stored are searched for
+	assert.ElementsMatch(t, []string{"Basket Case", "Nope"}, searcher.calls)
+
+	// only the word counts are stored, and the song that wasn't found is
+	// remembered since another search in the batch worked
+	assert.Equal(t, 1, len(db.execs))
+	assert.ElementsMatch(t, []interface{}{
+		"green day", "basket case", `{"skateboard":2}`, true,
+		"green day", "nope", "", false,
+	}, db.execs[0])
+
+	t.Run("Normalized", func(t *testing.T) {
+		db := &testDB{}
+		searcher := &testSearcher{lyrics: map[string]string{"Dammit": "growing growing"}}
+
+		getLyricCounts(testContext(db), searcher, []LyricSearch{
+			{Artist: "blink-182", Track: "Dammit"},
+			{Artist: "Blink-182 ", Track: "dammit"},
+		})
+
+		// the same song named two ways is only searched for once
+		assert.Equal(t, []string{"Dammit"}, searcher.calls)
+		assert.Equal(t, []interface{}{"blink-182", "dammit", `{"growing":2}`, true}, db.execs[0])
+	})
+
+	t.Run("AllFailed", func(t *testing.T) {
+		db := &testDB{}
+		searcher := &testSearcher{}
+
+		counts := getLyricCounts(testContext(db), searcher, []LyricSearch{
+			{Artist: "Green Day", Track: "Basket Case"},
+			{Artist: "Green Day", Track: "Nope"},
+		})
+
+		// genius was probably down, so the songs aren't remembered as
+		// missing
+		assert.Equal(t, map[string]int{}, counts)
+		assert.Equal(t, 2, len(searcher.calls))
+		assert.Equal(t, 0, len(db.execs))
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		searches := []LyricSearch{}
+		for i := 0; i < MaxConcurrentLookups*4; i++ {
+			searches = append(searches, LyricSearch{Artist: "artist", Track: fmt.Sprint("track ", i)})
+		}
+		searcher := &testSearcher{}
+
+		getLyricCounts(testContext(&testDB{}), searcher, searches)
+		assert.Equal(t, len(searches), len(searcher.calls))
+		assert.True(t, searcher.maxActive > 1)
+		assert.True(t, searcher.maxActive <= MaxConcurrentLookups)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		db := &testDB{}
+		searcher := &testSearcher{}
+		ctx, cancel := context.WithCancel(testContext(db))
+		cancel()
+
+		getLyricCounts(ctx, searcher, []LyricSearch{{Artist: "Green Day", Track: "Basket Case"}})
+		assert.Equal(t, 0, len(searcher.calls))
+		assert.Equal(t, 0, len(db.execs))
+	})
+
+	t.Run("NoToken", func(t *testing.T) {
+		_, err := GetLyricCountForSong(context.Background(), []LyricSearch{{Artist: "Green Day", Track: "Basket Case"}})
+		assert.NotNil(t, err)
+	})
+}
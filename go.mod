@@ -4,18 +4,20 @@ go 1.14
 
 require (
 	github.com/bbalet/stopwords v1.0.0
+	github.com/fogleman/gg v1.3.0
 	github.com/gin-gonic/gin v1.7.0
 	github.com/go-redis/redis/v8 v8.0.0-beta.6
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gofrs/uuid v3.3.0+incompatible
 	github.com/golang-migrate/migrate/v4 v4.14.1
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/gopherjs/gopherjs v0.0.0-20200217142428-fce0ec30dd00 // indirect
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/kelseyhightower/envconfig v1.4.0
-	github.com/psykhi/wordclouds v0.0.0-20191111204742-28c4ac381ff3
 	github.com/rhnvrm/lyric-api-go v0.1.3
 	github.com/sirupsen/logrus v1.7.0
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
 	gopkg.in/yaml.v2 v2.2.8
 )
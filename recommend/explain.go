@@ -0,0 +1,133 @@
+package recommend
+
+import (
+	"context"
+
+	"github.com/mike-webster/spotify-views/logging"
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	SeedTypeArtist = "artist"
+	SeedTypeTrack  = "track"
+	SeedTypeGenre  = "genre"
+)
+
+// Seed is one of the user's artists, tracks or genres that led to a
+// recommendation
+type Seed struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Hop is a step through the related artist graph
+type Hop struct {
+	From Seed `json:"from"`
+	To   Seed `json:"to"`
+}
+
+// Explanation is the trail that led to a recommendation
+type Explanation struct {
+	Seeds        []Seed   `json:"seeds"`
+	Hops         []Hop    `json:"hops"`
+	SharedGenres []string `json:"shared_genres"`
+	// FeatureCloseness is how close the track sounds to the user's recent
+	// top tracks, from 0 to 1. It's nil when there aren't audio features to
+	// compare.
+	FeatureCloseness *float64 `json:"feature_closeness"`
+}
+
+// ----
+// API
+// ----
+
+// Explain fills in how close each candidate sounds to the average of the
+// user's recent top tracks, for the candidates that don't have it yet.
+func Explain(ctx context.Context, sp Spotify, cands []Candidate) error {
+	ids := []string{}
+	for _, c := range cands {
+		if c.Explanation == nil || c.Explanation.FeatureCloseness == nil {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	if len(ids) < 1 {
+		return nil
+	}
+
+	top, err := sp.TopTracks(ctx, spotify.TFShort)
+	if err != nil {
+		return err
+	}
+
+	if len(*top) < 1 {
+		return nil
+	}
+
+	topFeatures, err := sp.AudioFeatures(ctx, top.IDs())
+	if err != nil {
+		return err
+	}
+
+	af, err := sp.AudioFeatures(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	target := spotify.SimilarityTarget{Features: topFeatures.Mean()}
+	w := spotify.DefaultSimilarityWeights()
+	w.Genres = 0
+
+	features := af.ByID()
+	for i := range cands {
+		if cands[i].Explanation == nil {
+			cands[i].Explanation = newExplanation()
+		}
+
+		f, ok := features[cands[i].ID]
+		if !ok || cands[i].Explanation.FeatureCloseness != nil {
+			continue
+		}
+
+		closeness := target.Compare(cands[i].Track, f, nil, w).FeatureSimilarity
+		cands[i].Explanation.FeatureCloseness = &closeness
+	}
+
+	return nil
+}
+
+// ----
+// Helpers
+// ----
+
+// explain adds what it can to the explanations. They're only there to
+// help, so the recommendations are still returned without them.
+func explain(ctx context.Context, sp Spotify, cands []Candidate) {
+	if err := Explain(ctx, sp, cands); err != nil {
+		logging.GetLogger(ctx).WithError(err).Warn("couldnt explain recommendations")
+	}
+}
+
+func newExplanation() *Explanation {
+	return &Explanation{Seeds: []Seed{}, Hops: []Hop{}, SharedGenres: []string{}}
+}
+
+// sharedGenres returns the genres in a that are also in b, in the order
+// they appear in a
+func sharedGenres(a []string, b []string) []string {
+	found := map[string]bool{}
+	for _, g := range b {
+		found[g] = true
+	}
+
+	ret := []string{}
+	for _, g := range a {
+		if found[g] {
+			ret = append(ret, g)
+			found[g] = false
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,43 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	sp := &fakeSpotify{
+		topTracks: map[spotify.TimeFrame]spotify.Tracks{spotify.TFShort: {track("t1", "a")}},
+		features: map[string]spotify.AudioFeature{
+			"t1":   {ID: "t1", Energy: 0.8},
+			"same": {ID: "same", Energy: 0.8},
+		},
+	}
+
+	known := 0.5
+	cands := []Candidate{
+		{Track: track("same", "b")},
+		{Track: track("nofeatures", "c")},
+		{Track: track("known", "d"), Explanation: &Explanation{FeatureCloseness: &known}},
+	}
+
+	assert.Nil(t, Explain(context.Background(), sp, cands))
+	assert.InDelta(t, 1.0, *cands[0].Explanation.FeatureCloseness, 0.0001)
+	assert.Equal(t, []Seed{}, cands[0].Explanation.Seeds)
+	assert.Nil(t, cands[1].Explanation.FeatureCloseness)
+	assert.Equal(t, 0.5, *cands[2].Explanation.FeatureCloseness)
+
+	t.Run("NoTopTracks", func(t *testing.T) {
+		cands := []Candidate{{Track: track("same", "b")}}
+		assert.Nil(t, Explain(context.Background(), &fakeSpotify{}, cands))
+		assert.Nil(t, cands[0].Explanation)
+	})
+}
+
+func TestSharedGenres(t *testing.T) {
+	assert.Equal(t, []string{"punk", "emo"}, sharedGenres([]string{"punk", "ska", "emo", "punk"}, []string{"emo", "punk"}))
+	assert.Equal(t, []string{}, sharedGenres(nil, []string{"emo"}))
+}
@@ -0,0 +1,105 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	StrategyFeatures = "features"
+
+	featureFetchLimit = 100
+)
+
+// FeatureTarget averages the audio features of the user's recent top tracks
+// and scores spotify's recommendations by how closely they match that
+// average.
+type FeatureTarget struct{}
+
+// ----
+// Members
+// ----
+
+func (f *FeatureTarget) Name() string {
+	return StrategyFeatures
+}
+
+func (f *FeatureTarget) Recommend(ctx context.Context, sp Spotify, limit int) ([]Candidate, error) {
+	top, err := sp.TopTracks(ctx, spotify.TFShort)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*top) < 1 {
+		return nil, errors.New("no top tracks to build a target from")
+	}
+
+	topFeatures, err := sp.AudioFeatures(ctx, top.IDs())
+	if err != nil {
+		return nil, err
+	}
+
+	target := spotify.SimilarityTarget{Features: topFeatures.Mean()}
+
+	seeds := top.IDs()
+	if len(seeds) > spotify.RecommendationSeedLimit {
+		seeds = seeds[:spotify.RecommendationSeedLimit]
+	}
+
+	// spotify caps how many recommendations come back in one request
+	fetch := limit
+	if fetch > featureFetchLimit {
+		fetch = featureFetchLimit
+	}
+
+	explained := []Seed{}
+	for _, t := range (*top)[:len(seeds)] {
+		explained = append(explained, Seed{Type: SeedTypeTrack, ID: t.ID, Name: t.Name})
+	}
+
+	recs, err := sp.Recommendations(ctx, spotify.RecommendationRequest{SeedTracks: seeds, Limit: fetch})
+	if err != nil {
+		return nil, err
+	}
+
+	trax := spotify.Tracks(recs.Tracks)
+	if len(trax) < 1 {
+		return []Candidate{}, nil
+	}
+
+	af, err := sp.AudioFeatures(ctx, trax.IDs())
+	if err != nil {
+		return nil, err
+	}
+
+	// only the sound matters here, genres are left to the genre strategy
+	w := spotify.DefaultSimilarityWeights()
+	w.Genres = 0
+
+	features := af.ByID()
+	ret := []Candidate{}
+	for _, t := range trax {
+		feat, ok := features[t.ID]
+		if !ok {
+			continue
+		}
+
+		s := target.Compare(t, feat, nil, w)
+		e := newExplanation()
+		e.Seeds = explained
+		e.FeatureCloseness = &s.FeatureSimilarity
+		ret = append(ret, Candidate{
+			Track:       t,
+			Score:       s.Score,
+			Reason:      fmt.Sprint("matches the sound of your top tracks (", math.Round(s.Score*100), "% similar)"),
+			Strategy:    StrategyFeatures,
+			Explanation: e,
+		})
+	}
+
+	return rank(ret, limit), nil
+}
@@ -0,0 +1,45 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureTargetRecommend(t *testing.T) {
+	sp := &fakeSpotify{
+		topTracks: map[spotify.TimeFrame]spotify.Tracks{
+			spotify.TFShort: {track("t1", "a"), track("t2", "a")},
+		},
+		recs: spotify.Tracks{track("far", "b"), track("close", "c"), track("nofeatures", "d")},
+		features: map[string]spotify.AudioFeature{
+			"t1":    {ID: "t1", Energy: 0.8, Valence: 0.6, Tempo: 160},
+			"t2":    {ID: "t2", Energy: 0.6, Valence: 0.4, Tempo: 140},
+			"far":   {ID: "far", Energy: 0.1, Valence: 0.1, Tempo: 70, Acousticness: 0.9},
+			"close": {ID: "close", Energy: 0.7, Valence: 0.5, Tempo: 150},
+		},
+	}
+
+	ret, err := (&FeatureTarget{}).Recommend(context.Background(), sp, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"t1", "t2"}, sp.req.SeedTracks)
+
+	assert.Equal(t, 2, len(ret))
+	assert.Equal(t, "close", ret[0].ID)
+	assert.InDelta(t, 1.0, ret[0].Score, 0.0001)
+	assert.Equal(t, "matches the sound of your top tracks (100% similar)", ret[0].Reason)
+	assert.Equal(t, []Seed{
+		{Type: SeedTypeTrack, ID: "t1", Name: "track t1"},
+		{Type: SeedTypeTrack, ID: "t2", Name: "track t2"},
+	}, ret[0].Explanation.Seeds)
+	assert.InDelta(t, 1.0, *ret[0].Explanation.FeatureCloseness, 0.0001)
+	assert.Equal(t, "far", ret[1].ID)
+	assert.True(t, ret[1].Score < ret[0].Score)
+
+	t.Run("NoTopTracks", func(t *testing.T) {
+		_, err := (&FeatureTarget{}).Recommend(context.Background(), &fakeSpotify{}, 10)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,183 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	FilterSavedTrack    = "saved_track"
+	FilterTopTrack      = "top_track"
+	FilterTopArtist     = "top_artist"
+	FilterLibraryArtist = "library_artist"
+
+	// DefaultNovelty removes the tracks the user already knows and the
+	// tracks by their top artists
+	DefaultNovelty = 0.5
+
+	// filterOverfetch is how many times the limit is requested from a
+	// strategy, so there's enough left over after filtering
+	filterOverfetch = 3
+	// libraryArtistLimit is how many saved tracks an artist needs before
+	// their tracks are filtered at the default novelty. It drops to a
+	// single saved track as novelty approaches 1.
+	libraryArtistLimit = 10
+)
+
+// Familiarity is what the user already knows
+type Familiarity struct {
+	SavedTracks map[string]bool
+	TopTracks   map[string]bool
+	TopArtists  map[string]bool
+	// LibraryArtists is the number of saved tracks for each artist
+	LibraryArtists map[string]int
+}
+
+// Filter removes candidates the user is already familiar with. Novelty goes
+// from 0, where nothing is removed, to 1, where anything by an artist in
+// the user's library is removed.
+//   - above 0 the user's saved tracks and top tracks are removed
+//   - from 0.5 tracks by their top artists, and artists they've saved a lot
+//     of tracks by, are removed
+//
+// Artists the user follows aren't considered, since reading them needs the
+// user-follow-read scope, which isn't asked for when logging in.
+type Filter struct {
+	Novelty float64 `json:"novelty"`
+}
+
+// FilterReport describes what a filter removed
+type FilterReport struct {
+	Novelty    float64        `json:"novelty"`
+	Considered int            `json:"considered"`
+	Filtered   int            `json:"filtered"`
+	Reasons    map[string]int `json:"reasons"`
+}
+
+// ----
+// API
+// ----
+
+// GetFamiliarity collects the user's saved tracks along with their top
+// tracks and artists from every time range.
+func GetFamiliarity(ctx context.Context, sp Spotify) (*Familiarity, error) {
+	ret := Familiarity{
+		SavedTracks:    map[string]bool{},
+		TopTracks:      map[string]bool{},
+		TopArtists:     map[string]bool{},
+		LibraryArtists: map[string]int{},
+	}
+
+	saved, err := sp.SavedTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range *saved {
+		ret.SavedTracks[t.ID] = true
+		for _, a := range t.Artists {
+			ret.LibraryArtists[a.ID]++
+		}
+	}
+
+	for _, tf := range []spotify.TimeFrame{spotify.TFShort, spotify.TFMedium, spotify.TFLong} {
+		trax, err := sp.TopTracks(ctx, tf)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range *trax {
+			ret.TopTracks[t.ID] = true
+		}
+	}
+
+	artists, err := topArtists(ctx, sp)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range artists {
+		ret.TopArtists[a.ID] = true
+	}
+
+	return &ret, nil
+}
+
+// ----
+// Members
+// ----
+
+// Validate makes sure the novelty is within range
+func (f Filter) Validate() error {
+	if f.Novelty < 0 || f.Novelty > 1 {
+		return errors.New(fmt.Sprint("novelty must be between 0 and 1, got ", f.Novelty))
+	}
+
+	return nil
+}
+
+// Enabled returns whether the filter will remove anything
+func (f Filter) Enabled() bool {
+	return f.Novelty > 0
+}
+
+// Reason returns why the candidate should be removed, or an empty string if
+// it should be kept.
+func (f Filter) Reason(fam *Familiarity, c Candidate) string {
+	if !f.Enabled() {
+		return ""
+	}
+
+	if fam.SavedTracks[c.ID] {
+		return FilterSavedTrack
+	}
+
+	if fam.TopTracks[c.ID] {
+		return FilterTopTrack
+	}
+
+	if f.Novelty < DefaultNovelty {
+		return ""
+	}
+
+	for _, a := range c.Artists {
+		if fam.TopArtists[a.ID] {
+			return FilterTopArtist
+		}
+	}
+
+	limit := int(math.Ceil((1 - f.Novelty) * 2 * libraryArtistLimit))
+	if limit < 1 {
+		limit = 1
+	}
+
+	for _, a := range c.Artists {
+		if fam.LibraryArtists[a.ID] >= limit {
+			return FilterLibraryArtist
+		}
+	}
+
+	return ""
+}
+
+// Apply removes the candidates the user is familiar with, keeping the
+// order of the rest.
+func (f Filter) Apply(fam *Familiarity, cands []Candidate) ([]Candidate, *FilterReport) {
+	report := FilterReport{Novelty: f.Novelty, Considered: len(cands), Reasons: map[string]int{}}
+	ret := []Candidate{}
+	for _, c := range cands {
+		if reason := f.Reason(fam, c); len(reason) > 0 {
+			report.Filtered++
+			report.Reasons[reason]++
+			continue
+		}
+
+		ret = append(ret, c)
+	}
+
+	return ret, &report
+}
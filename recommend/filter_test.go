@@ -0,0 +1,100 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFamiliarity(t *testing.T) {
+	sp := &fakeSpotify{
+		saved: spotify.Tracks{track("1", "a"), track("2", "a")},
+		topTracks: map[spotify.TimeFrame]spotify.Tracks{
+			spotify.TFShort: {track("3", "b")},
+			spotify.TFLong:  {track("4", "c")},
+		},
+		topArtists: map[spotify.TimeFrame]spotify.Artists{
+			spotify.TFMedium: {{ID: "b"}},
+		},
+	}
+
+	fam, err := GetFamiliarity(context.Background(), sp)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"1": true, "2": true}, fam.SavedTracks)
+	assert.Equal(t, map[string]bool{"3": true, "4": true}, fam.TopTracks)
+	assert.Equal(t, map[string]bool{"b": true}, fam.TopArtists)
+	assert.Equal(t, map[string]int{"a": 2}, fam.LibraryArtists)
+
+	t.Run("SpotifyError", func(t *testing.T) {
+		_, err := GetFamiliarity(context.Background(), &fakeSpotify{err: errors.New("test error")})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestFilterValidate(t *testing.T) {
+	assert.Nil(t, Filter{}.Validate())
+	assert.Nil(t, Filter{Novelty: 1}.Validate())
+	assert.NotNil(t, Filter{Novelty: -0.1}.Validate())
+	assert.NotNil(t, Filter{Novelty: 1.1}.Validate())
+}
+
+func TestFilterApply(t *testing.T) {
+	fam := &Familiarity{
+		SavedTracks:    map[string]bool{"saved": true},
+		TopTracks:      map[string]bool{"top": true},
+		TopArtists:     map[string]bool{"topartist": true},
+		LibraryArtists: map[string]int{"fan": 5, "casual": 1},
+	}
+
+	cands := []Candidate{
+		{Track: track("saved", "x")},
+		{Track: track("top", "x")},
+		{Track: track("1", "topartist")},
+		{Track: track("2", "fan")},
+		{Track: track("3", "casual")},
+		{Track: track("4", "new")},
+	}
+
+	ids := func(cands []Candidate) []string {
+		ret := []string{}
+		for _, c := range cands {
+			ret = append(ret, c.ID)
+		}
+		return ret
+	}
+
+	t.Run("Off", func(t *testing.T) {
+		ret, report := Filter{}.Apply(fam, cands)
+		assert.Equal(t, 6, len(ret))
+		assert.Equal(t, 6, report.Considered)
+		assert.Equal(t, 0, report.Filtered)
+	})
+
+	t.Run("KnownTracks", func(t *testing.T) {
+		ret, report := Filter{Novelty: 0.2}.Apply(fam, cands)
+		assert.Equal(t, []string{"1", "2", "3", "4"}, ids(ret))
+		assert.Equal(t, map[string]int{FilterSavedTrack: 1, FilterTopTrack: 1}, report.Reasons)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		ret, report := Filter{Novelty: DefaultNovelty}.Apply(fam, cands)
+		assert.Equal(t, []string{"2", "3", "4"}, ids(ret))
+		assert.Equal(t, 3, report.Filtered)
+		assert.Equal(t, 1, report.Reasons[FilterTopArtist])
+	})
+
+	t.Run("Adventurous", func(t *testing.T) {
+		ret, report := Filter{Novelty: 0.75}.Apply(fam, cands)
+		assert.Equal(t, []string{"3", "4"}, ids(ret))
+		assert.Equal(t, 1, report.Reasons[FilterLibraryArtist])
+	})
+
+	t.Run("Max", func(t *testing.T) {
+		ret, report := Filter{Novelty: 1}.Apply(fam, cands)
+		assert.Equal(t, []string{"4"}, ids(ret))
+		assert.Equal(t, 2, report.Reasons[FilterLibraryArtist])
+	})
+}
@@ -0,0 +1,86 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	StrategyGenre = "genre"
+
+	// genreSeedLimit is how many of the user's top genres are searched
+	genreSeedLimit = 3
+)
+
+// GenreSeeded searches for tracks in the user's most common genres. The
+// more of the user's top artists share a genre, the more its tracks score.
+type GenreSeeded struct{}
+
+// ----
+// Members
+// ----
+
+func (g *GenreSeeded) Name() string {
+	return StrategyGenre
+}
+
+func (g *GenreSeeded) Recommend(ctx context.Context, sp Spotify, limit int) ([]Candidate, error) {
+	artists, err := sp.TopArtists(ctx, spotify.TFMedium)
+	if err != nil {
+		return nil, err
+	}
+
+	genres := artists.GetGenres(ctx)
+	sort.SliceStable(*genres, func(i, j int) bool {
+		if (*genres)[i].Value == (*genres)[j].Value {
+			return (*genres)[i].Key < (*genres)[j].Key
+		}
+		return (*genres)[i].Value > (*genres)[j].Value
+	})
+
+	total := 0
+	for _, i := range *genres {
+		total += int(i.Value)
+	}
+
+	known := map[string]bool{}
+	for _, i := range *artists {
+		known[i.ID] = true
+	}
+
+	ret := []Candidate{}
+	for _, i := range genres.Take(genreSeedLimit) {
+		res, err := sp.Search(ctx, fmt.Sprintf("genre:%q", i.Key), spotify.SearchOptions{Types: []string{spotify.SearchTypeTrack}})
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Tracks == nil {
+			continue
+		}
+
+		share := float64(i.Value) / float64(total)
+		for pos, t := range res.Tracks.Items {
+			// the user's own top artists won't be news to them
+			if len(t.Artists) > 0 && known[t.Artists[0].ID] {
+				continue
+			}
+
+			e := newExplanation()
+			e.Seeds = append(e.Seeds, Seed{Type: SeedTypeGenre, Name: i.Key})
+			e.SharedGenres = append(e.SharedGenres, i.Key)
+			ret = append(ret, Candidate{
+				Track:       t,
+				Score:       share * (1 - float64(pos)/float64(len(res.Tracks.Items))),
+				Reason:      fmt.Sprintf("popular in %s, one of your top genres", i.Key),
+				Strategy:    StrategyGenre,
+				Explanation: e,
+			})
+		}
+	}
+
+	return rank(ret, limit), nil
+}
@@ -0,0 +1,39 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenreSeededRecommend(t *testing.T) {
+	sp := &fakeSpotify{
+		topArtists: map[spotify.TimeFrame]spotify.Artists{
+			spotify.TFMedium: {
+				{ID: "a", Genres: []string{"pop punk", "punk"}},
+				{ID: "b", Genres: []string{"pop punk"}},
+			},
+		},
+		search: map[string]spotify.Tracks{
+			`genre:"pop punk"`: {track("1", "x"), track("2", "a"), track("3", "y")},
+			`genre:"punk"`:     {track("4", "z")},
+		},
+	}
+
+	ret, err := (&GenreSeeded{}).Recommend(context.Background(), sp, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`genre:"pop punk"`, `genre:"punk"`}, sp.queries)
+
+	ids := []string{}
+	for _, c := range ret {
+		ids = append(ids, c.ID)
+	}
+	// track 2 is by one of the user's top artists
+	assert.Equal(t, []string{"1", "4", "3"}, ids)
+	assert.InDelta(t, 2.0/3, ret[0].Score, 0.0001)
+	assert.Equal(t, "popular in pop punk, one of your top genres", ret[0].Reason)
+	assert.Equal(t, []Seed{{Type: SeedTypeGenre, Name: "pop punk"}}, ret[0].Explanation.Seeds)
+	assert.Equal(t, []string{"pop punk"}, ret[0].Explanation.SharedGenres)
+}
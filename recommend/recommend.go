@@ -0,0 +1,234 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	// DefaultStrategy is used when no strategy is requested
+	DefaultStrategy = StrategyRelated
+	// StrategySeeded is reported for recommendations from seeds the user
+	// picked themselves
+	StrategySeeded = "seeded"
+	// DefaultLimit is the number of candidates returned when no limit is
+	// requested
+	DefaultLimit = 20
+)
+
+// Strategy is a way of finding tracks the user might like
+type Strategy interface {
+	// Name is the value used to select the strategy
+	Name() string
+	// Recommend returns up to limit candidates, best first
+	Recommend(ctx context.Context, sp Spotify, limit int) ([]Candidate, error)
+}
+
+// Candidate is a recommended track along with why it was picked. The track
+// is embedded so candidates serialize the same way a spotify track does.
+type Candidate struct {
+	spotify.Track
+	Score       float64      `json:"score"`
+	Reason      string       `json:"reason"`
+	Strategy    string       `json:"strategy"`
+	Explanation *Explanation `json:"explanation"`
+}
+
+// Result is the output of a strategy
+type Result struct {
+	Strategy string        `json:"strategy"`
+	Tracks   []Candidate   `json:"tracks"`
+	Filtered *FilterReport `json:"filtered"`
+}
+
+var strategies = map[string]Strategy{
+	StrategyRelated:  &RelatedArtists{},
+	StrategyFeatures: &FeatureTarget{},
+	StrategyGenre:    &GenreSeeded{},
+}
+
+// ----
+// API
+// ----
+
+// Get returns the strategy with the given name
+func Get(name string) (Strategy, error) {
+	if len(name) < 1 {
+		name = DefaultStrategy
+	}
+
+	s, ok := strategies[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprint("unknown recommendation strategy: ", name))
+	}
+
+	return s, nil
+}
+
+// Names returns the name of every registered strategy
+func Names() []string {
+	ret := []string{}
+	for k := range strategies {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Recommend runs the named strategy and removes the candidates the user is
+// already familiar with.
+func Recommend(ctx context.Context, sp Spotify, name string, limit int, f Filter) (*Result, error) {
+	s, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	if limit < 1 {
+		limit = DefaultLimit
+	}
+
+	// familiarity takes a few requests to build, so it's skipped when
+	// nothing would be filtered
+	fam := &Familiarity{}
+	fetch := limit
+	if f.Enabled() {
+		fam, err = GetFamiliarity(ctx, sp)
+		if err != nil {
+			return nil, err
+		}
+		fetch = limit * filterOverfetch
+	}
+
+	cands, err := s.Recommend(ctx, sp, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	cands, report := f.Apply(fam, cands)
+	cands = rank(cands, limit)
+	explain(ctx, sp, cands)
+
+	return &Result{Strategy: s.Name(), Tracks: cands, Filtered: report}, nil
+}
+
+// RecommendFromSeeds returns spotify's recommendations for the seeds and
+// attributes the user picked, in the order spotify returned them, without
+// the ones the user is already familiar with.
+func RecommendFromSeeds(ctx context.Context, sp Spotify, r spotify.RecommendationRequest, f Filter) (*Result, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	limit := r.Limit
+	if limit < 1 {
+		limit = DefaultLimit
+	}
+
+	// the request is copied so the caller's limit isn't changed
+	fetch := r
+	fam := &Familiarity{}
+	if f.Enabled() {
+		var err error
+		fam, err = GetFamiliarity(ctx, sp)
+		if err != nil {
+			return nil, err
+		}
+
+		fetch.Limit = limit * filterOverfetch
+		if fetch.Limit > spotify.RecommendationMaxLimit {
+			fetch.Limit = spotify.RecommendationMaxLimit
+		}
+	}
+
+	recs, err := sp.Recommendations(ctx, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := []Seed{}
+	for _, id := range r.SeedArtists {
+		seeds = append(seeds, Seed{Type: SeedTypeArtist, ID: id})
+	}
+	for _, id := range r.SeedTracks {
+		seeds = append(seeds, Seed{Type: SeedTypeTrack, ID: id})
+	}
+	for _, g := range r.SeedGenres {
+		seeds = append(seeds, Seed{Type: SeedTypeGenre, Name: g})
+	}
+
+	cands := []Candidate{}
+	for i, t := range recs.Tracks {
+		e := newExplanation()
+		e.Seeds = seeds
+		cands = append(cands, Candidate{
+			Track:       t,
+			Score:       1 - float64(i)/float64(len(recs.Tracks)),
+			Reason:      "recommended by spotify for the seeds you picked",
+			Strategy:    StrategySeeded,
+			Explanation: e,
+		})
+	}
+
+	cands, report := f.Apply(fam, cands)
+	cands = rank(cands, limit)
+	explain(ctx, sp, cands)
+
+	return &Result{Strategy: StrategySeeded, Tracks: cands, Filtered: report}, nil
+}
+
+// ----
+// Members
+// ----
+
+// SpotifyTracks returns the spotify track for each of the candidates
+func (r *Result) SpotifyTracks() spotify.Tracks {
+	ret := spotify.Tracks{}
+	for _, i := range r.Tracks {
+		ret = append(ret, i.Track)
+	}
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+// rank removes duplicate tracks, keeping the best score for each, sorts the
+// candidates best first and trims them to the limit.
+func rank(cands []Candidate, limit int) []Candidate {
+	best := map[string]int{}
+	ret := []Candidate{}
+	for _, c := range cands {
+		if i, ok := best[c.ID]; ok {
+			if c.Score > ret[i].Score {
+				ret[i] = c
+			}
+			continue
+		}
+
+		best[c.ID] = len(ret)
+		ret = append(ret, c)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret
+}
@@ -0,0 +1,222 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSpotify answers every request from memory so the strategies can be
+// tested offline
+type fakeSpotify struct {
+	topArtists map[spotify.TimeFrame]spotify.Artists
+	topTracks  map[spotify.TimeFrame]spotify.Tracks
+	saved      spotify.Tracks
+	related    map[string]spotify.Artists
+	artistTop  map[string]spotify.Tracks
+	features   map[string]spotify.AudioFeature
+	recs       spotify.Tracks
+	search     map[string]spotify.Tracks
+	err        error
+	// featuresErr is only returned for audio features
+	featuresErr error
+
+	req     spotify.RecommendationRequest
+	queries []string
+}
+
+func (f *fakeSpotify) TopArtists(ctx context.Context, tf spotify.TimeFrame) (*spotify.Artists, error) {
+	ret := f.topArtists[tf]
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) TopTracks(ctx context.Context, tf spotify.TimeFrame) (*spotify.Tracks, error) {
+	ret := f.topTracks[tf]
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) SavedTracks(ctx context.Context) (*spotify.Tracks, error) {
+	ret := f.saved
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) RelatedArtists(ctx context.Context, id string) (*spotify.Artists, error) {
+	ret := f.related[id]
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) ArtistTopTracks(ctx context.Context, id string) (*spotify.Tracks, error) {
+	ret := f.artistTop[id]
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) AudioFeatures(ctx context.Context, ids []string) (*spotify.AudioFeatures, error) {
+	ret := spotify.AudioFeatures{}
+	for _, id := range ids {
+		if af, ok := f.features[id]; ok {
+			ret = append(ret, af)
+		}
+	}
+	if f.featuresErr != nil {
+		return nil, f.featuresErr
+	}
+	return &ret, f.err
+}
+
+func (f *fakeSpotify) Recommendations(ctx context.Context, r spotify.RecommendationRequest) (*spotify.Recommendation, error) {
+	f.req = r
+	return &spotify.Recommendation{Tracks: f.recs}, f.err
+}
+
+func (f *fakeSpotify) Search(ctx context.Context, query string, opts spotify.SearchOptions) (*spotify.SearchResults, error) {
+	f.queries = append(f.queries, query)
+	return &spotify.SearchResults{Tracks: &spotify.TrackResults{Items: f.search[query]}}, f.err
+}
+
+func track(id string, artist string) spotify.Track {
+	return spotify.Track{ID: id, Name: "track " + id, Artists: []spotify.Artist{{ID: artist, Name: "artist " + artist}}}
+}
+
+func TestGet(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		s, err := Get("")
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultStrategy, s.Name())
+	})
+
+	t.Run("Known", func(t *testing.T) {
+		for _, name := range Names() {
+			s, err := Get(name)
+			assert.Nil(t, err)
+			assert.Equal(t, name, s.Name())
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := Get("cowbell")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, []string{StrategyFeatures, StrategyGenre, StrategyRelated}, Names())
+}
+
+func TestRecommend(t *testing.T) {
+	sp := &fakeSpotify{
+		topArtists: map[spotify.TimeFrame]spotify.Artists{spotify.TFShort: {{ID: "a", Name: "blink-182"}}},
+		related:    map[string]spotify.Artists{"a": {{ID: "b"}}},
+		artistTop:  map[string]spotify.Tracks{"b": {track("1", "b"), track("2", "b"), track("3", "b")}},
+	}
+
+	t.Run("HappyPath", func(t *testing.T) {
+		res, err := Recommend(context.Background(), sp, "", 1, Filter{})
+		assert.Nil(t, err)
+		assert.Equal(t, StrategyRelated, res.Strategy)
+		assert.Equal(t, 1, len(res.Tracks))
+		assert.Equal(t, spotify.Tracks{track("1", "b")}, res.SpotifyTracks())
+		assert.Equal(t, 0, res.Filtered.Filtered)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		sp.saved = spotify.Tracks{track("1", "b")}
+		defer func() { sp.saved = nil }()
+
+		res, err := Recommend(context.Background(), sp, "", 1, Filter{Novelty: DefaultNovelty})
+		assert.Nil(t, err)
+		assert.Equal(t, spotify.Tracks{track("2", "b")}, res.SpotifyTracks())
+		assert.Equal(t, 1, res.Filtered.Filtered)
+		assert.Equal(t, map[string]int{FilterSavedTrack: 1}, res.Filtered.Reasons)
+	})
+
+	t.Run("InvalidNovelty", func(t *testing.T) {
+		_, err := Recommend(context.Background(), sp, "", 1, Filter{Novelty: 2})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("UnknownStrategy", func(t *testing.T) {
+		_, err := Recommend(context.Background(), sp, "cowbell", 1, Filter{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("SpotifyError", func(t *testing.T) {
+		_, err := Recommend(context.Background(), &fakeSpotify{err: errors.New("test error")}, StrategyRelated, 1, Filter{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRecommendFromSeeds(t *testing.T) {
+	sp := &fakeSpotify{recs: spotify.Tracks{track("1", "a"), track("2", "b")}}
+	r := spotify.RecommendationRequest{
+		SeedArtists: []string{"a"},
+		SeedGenres:  []string{"punk"},
+		Max:         map[string]float64{"energy": 0.4},
+	}
+
+	res, err := RecommendFromSeeds(context.Background(), sp, r, Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, r, sp.req)
+	assert.Equal(t, StrategySeeded, res.Strategy)
+	assert.Equal(t, 2, len(res.Tracks))
+	assert.Equal(t, 1.0, res.Tracks[0].Score)
+	assert.Equal(t, []Seed{{Type: SeedTypeArtist, ID: "a"}, {Type: SeedTypeGenre, Name: "punk"}}, res.Tracks[0].Explanation.Seeds)
+
+	t.Run("Filtered", func(t *testing.T) {
+		sp := &fakeSpotify{
+			recs:  spotify.Tracks{track("1", "a"), track("2", "b"), track("3", "c")},
+			saved: spotify.Tracks{track("2", "b")},
+		}
+		r := spotify.RecommendationRequest{SeedGenres: []string{"punk"}, Limit: 2}
+
+		res, err := RecommendFromSeeds(context.Background(), sp, r, Filter{Novelty: DefaultNovelty})
+		assert.Nil(t, err)
+
+		// more are asked for so there's enough left over after filtering
+		assert.Equal(t, 2*filterOverfetch, sp.req.Limit)
+		assert.Equal(t, 2, r.Limit)
+		assert.Equal(t, []string{"1", "3"}, []string{res.Tracks[0].ID, res.Tracks[1].ID})
+		assert.Equal(t, 3, res.Filtered.Considered)
+		assert.Equal(t, map[string]int{FilterSavedTrack: 1}, res.Filtered.Reasons)
+
+		r.Limit = spotify.RecommendationMaxLimit
+		_, err = RecommendFromSeeds(context.Background(), sp, r, Filter{Novelty: DefaultNovelty})
+		assert.Nil(t, err)
+		assert.Equal(t, spotify.RecommendationMaxLimit, sp.req.Limit)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := RecommendFromSeeds(context.Background(), sp, spotify.RecommendationRequest{}, Filter{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ExplainFails", func(t *testing.T) {
+		sp := &fakeSpotify{
+			recs:        spotify.Tracks{track("1", "a")},
+			topTracks:   map[spotify.TimeFrame]spotify.Tracks{spotify.TFShort: {track("t1", "a")}},
+			featuresErr: errors.New("test error"),
+		}
+
+		res, err := RecommendFromSeeds(context.Background(), sp, r, Filter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(res.Tracks))
+		assert.Nil(t, res.Tracks[0].Explanation.FeatureCloseness)
+	})
+}
+
+func TestRank(t *testing.T) {
+	cands := []Candidate{
+		{Track: spotify.Track{ID: "1"}, Score: 0.2},
+		{Track: spotify.Track{ID: "2"}, Score: 0.5},
+		{Track: spotify.Track{ID: "1"}, Score: 0.9},
+		{Track: spotify.Track{ID: "3"}, Score: 0.1},
+	}
+
+	ret := rank(cands, 2)
+	assert.Equal(t, 2, len(ret))
+	assert.Equal(t, "1", ret[0].ID)
+	assert.Equal(t, 0.9, ret[0].Score)
+	assert.Equal(t, "2", ret[1].ID)
+}
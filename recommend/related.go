@@ -0,0 +1,230 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+const (
+	StrategyRelated = "related"
+
+	// minRelatedArtists is the fewest of the most connected related artists
+	// that have their tracks recommended. More are used when the limit
+	// needs them.
+	minRelatedArtists = 10
+	// relatedTracksPerArtist is how many top tracks are taken from each
+	relatedTracksPerArtist = 2
+	// relatedReasonSeeds is how many seed artists are named in a reason
+	relatedReasonSeeds = 2
+)
+
+// RelatedArtists walks the related artist graph out from the user's top
+// artists in every time range. The artists that are related to the most of
+// the user's top artists, and aren't already one of them, have their top
+// tracks recommended.
+type RelatedArtists struct{}
+
+// Recommendation holds the related artists spotify returned for one of the
+// user's top artists
+type Recommendation struct {
+	Seed        string
+	SeedID      string
+	SeedResults *spotify.Artists
+}
+
+// Recommendations is a collection of Recommendation
+type Recommendations []Recommendation
+
+// ----
+// Members
+// ----
+
+func (r *RelatedArtists) Name() string {
+	return StrategyRelated
+}
+
+func (r *RelatedArtists) Recommend(ctx context.Context, sp Spotify, limit int) ([]Candidate, error) {
+	seeds, err := topArtists(ctx, sp)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := Recommendations{}
+	for _, i := range seeds {
+		res, err := sp.RelatedArtists(ctx, i.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		recs = append(recs, Recommendation{Seed: i.Name, SeedID: i.ID, SeedResults: res})
+	}
+
+	known := map[string]spotify.Artist{}
+	for _, i := range seeds {
+		known[i.ID] = i
+	}
+
+	// the user already knows their top artists, so only the artists they're
+	// connected to are worth recommending
+	counts := recs.GetSeeds()
+	related := []string{}
+	for _, id := range recs.order() {
+		if _, ok := known[id]; !ok {
+			related = append(related, id)
+		}
+	}
+
+	sort.SliceStable(related, func(i, j int) bool {
+		return (*counts)[related[i]] > (*counts)[related[j]]
+	})
+	// the limit already includes the overfetch when the results are going
+	// to be filtered, so enough artists are used to fill all of it
+	want := (limit + relatedTracksPerArtist - 1) / relatedTracksPerArtist
+	if want < minRelatedArtists {
+		want = minRelatedArtists
+	}
+	if len(related) > want {
+		related = related[:want]
+	}
+
+	ret := []Candidate{}
+	for _, id := range related {
+		trax, err := sp.ArtistTopTracks(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		connections := recs.Containing(id)
+		names := []string{}
+		for _, r := range connections {
+			names = append(names, r.Seed)
+		}
+
+		for i, t := range *trax {
+			if i >= relatedTracksPerArtist {
+				break
+			}
+
+			ret = append(ret, Candidate{
+				Track:       t,
+				Score:       float64(len(connections)) / float64(len(seeds)) * (1 - float64(i)/float64(relatedTracksPerArtist*2)),
+				Reason:      relatedReason(names),
+				Strategy:    StrategyRelated,
+				Explanation: connections.explain(id, known),
+			})
+		}
+	}
+
+	return rank(ret, limit), nil
+}
+
+// GetSeeds counts how many times each artist appears, either as one of the
+// user's top artists or as one of their related artists.
+func (r *Recommendations) GetSeeds() *map[string]int {
+	ids := map[string]int{}
+	for _, i := range *r {
+		ids[i.SeedID]++
+
+		// go through each reccomendation for each top artist
+		for _, j := range *i.SeedResults {
+			ids[j.ID]++
+		}
+	}
+
+	return &ids
+}
+
+// Containing returns the recommendations the given artist was related to
+func (r *Recommendations) Containing(id string) Recommendations {
+	ret := Recommendations{}
+	for _, i := range *r {
+		for _, j := range *i.SeedResults {
+			if j.ID == id {
+				ret = append(ret, i)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+// order returns every related artist id in the order they were first seen,
+// so ties are broken the same way every time
+func (r *Recommendations) order() []string {
+	found := map[string]bool{}
+	ret := []string{}
+	for _, i := range *r {
+		for _, j := range *i.SeedResults {
+			if !found[j.ID] {
+				found[j.ID] = true
+				ret = append(ret, j.ID)
+			}
+		}
+	}
+	return ret
+}
+
+// explain builds the trail from each of the user's top artists to the
+// related artist, along with the genres they have in common
+func (r Recommendations) explain(id string, seeds map[string]spotify.Artist) *Explanation {
+	ret := newExplanation()
+	seedGenres := []string{}
+	related := spotify.Artist{}
+	for _, i := range r {
+		from := Seed{Type: SeedTypeArtist, ID: i.SeedID, Name: i.Seed}
+		ret.Seeds = append(ret.Seeds, from)
+		seedGenres = append(seedGenres, seeds[i.SeedID].Genres...)
+
+		for _, j := range *i.SeedResults {
+			if j.ID != id {
+				continue
+			}
+
+			related = j
+			ret.Hops = append(ret.Hops, Hop{From: from, To: Seed{Type: SeedTypeArtist, ID: j.ID, Name: j.Name}})
+			break
+		}
+	}
+
+	ret.SharedGenres = sharedGenres(related.Genres, seedGenres)
+	return ret
+}
+
+// topArtists returns the user's top artists from every time range, without
+// any duplicates
+func topArtists(ctx context.Context, sp Spotify) (spotify.Artists, error) {
+	found := map[string]bool{}
+	ret := spotify.Artists{}
+	for _, tf := range []spotify.TimeFrame{spotify.TFShort, spotify.TFMedium, spotify.TFLong} {
+		artists, err := sp.TopArtists(ctx, tf)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range *artists {
+			if !found[a.ID] {
+				found[a.ID] = true
+				ret = append(ret, a)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func relatedReason(seeds []string) string {
+	if len(seeds) <= relatedReasonSeeds {
+		return fmt.Sprint("related to ", strings.Join(seeds, " and "), ", from your top artists")
+	}
+
+	return fmt.Sprint("related to ", strings.Join(seeds[:relatedReasonSeeds], ", "), " and ",
+		len(seeds)-relatedReasonSeeds, " more of your top artists")
+}
@@ -0,0 +1,94 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelatedArtistsRecommend(t *testing.T) {
+	sp := &fakeSpotify{
+		topArtists: map[spotify.TimeFrame]spotify.Artists{
+			spotify.TFShort:  {{ID: "a", Name: "blink-182", Genres: []string{"pop punk"}}, {ID: "b", Name: "Green Day", Genres: []string{"punk"}}},
+			spotify.TFMedium: {{ID: "a", Name: "blink-182"}, {ID: "c", Name: "Sum 41"}},
+		},
+		related: map[string]spotify.Artists{
+			// b is one of the user's top artists, so it shouldn't be recommended
+			"a": {{ID: "b"}, {ID: "x", Name: "The Offspring", Genres: []string{"punk", "skate punk", "pop punk"}}, {ID: "y"}},
+			"b": {{ID: "x", Name: "The Offspring", Genres: []string{"punk", "skate punk", "pop punk"}}},
+			"c": {{ID: "x", Name: "The Offspring", Genres: []string{"punk", "skate punk", "pop punk"}}, {ID: "z"}},
+		},
+		artistTop: map[string]spotify.Tracks{
+			"b": {track("b1", "b")},
+			"x": {track("x1", "x"), track("x2", "x"), track("x3", "x")},
+			"y": {track("y1", "y")},
+			"z": {track("z1", "z")},
+		},
+	}
+
+	ret, err := (&RelatedArtists{}).Recommend(context.Background(), sp, 10)
+	assert.Nil(t, err)
+
+	ids := []string{}
+	for _, c := range ret {
+		ids = append(ids, c.ID)
+		assert.Equal(t, StrategyRelated, c.Strategy)
+	}
+	assert.Equal(t, []string{"x1", "x2", "y1", "z1"}, ids)
+
+	assert.Equal(t, 1.0, ret[0].Score)
+	assert.Equal(t, "related to blink-182, Green Day and 1 more of your top artists", ret[0].Reason)
+	assert.Equal(t, "related to blink-182, from your top artists", ret[2].Reason)
+
+	e := ret[0].Explanation
+	assert.Equal(t, []Seed{
+		{Type: SeedTypeArtist, ID: "a", Name: "blink-182"},
+		{Type: SeedTypeArtist, ID: "b", Name: "Green Day"},
+		{Type: SeedTypeArtist, ID: "c", Name: "Sum 41"},
+	}, e.Seeds)
+	assert.Equal(t, 3, len(e.Hops))
+	assert.Equal(t, Hop{
+		From: Seed{Type: SeedTypeArtist, ID: "a", Name: "blink-182"},
+		To:   Seed{Type: SeedTypeArtist, ID: "x", Name: "The Offspring"},
+	}, e.Hops[0])
+	assert.Equal(t, []string{"punk", "pop punk"}, e.SharedGenres)
+
+	t.Run("Limit", func(t *testing.T) {
+		sp := &fakeSpotify{
+			topArtists: map[spotify.TimeFrame]spotify.Artists{spotify.TFShort: {{ID: "a", Name: "blink-182"}}},
+			related:    map[string]spotify.Artists{},
+			artistTop:  map[string]spotify.Tracks{},
+		}
+		related := spotify.Artists{}
+		for i := 0; i < 40; i++ {
+			id := fmt.Sprint("r", i)
+			related = append(related, spotify.Artist{ID: id})
+			sp.artistTop[id] = spotify.Tracks{track(id+"-1", id), track(id+"-2", id)}
+		}
+		sp.related["a"] = related
+
+		// there are enough related artists to fill a limit bigger than the
+		// minimum
+		ret, err := (&RelatedArtists{}).Recommend(context.Background(), sp, 50)
+		assert.Nil(t, err)
+		assert.Equal(t, 50, len(ret))
+
+		ret, err = (&RelatedArtists{}).Recommend(context.Background(), sp, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(ret))
+	})
+}
+
+func TestRecommendationsGetSeeds(t *testing.T) {
+	recs := Recommendations{
+		{Seed: "blink-182", SeedID: "a", SeedResults: &spotify.Artists{{ID: "b"}, {ID: "x"}}},
+		{Seed: "Green Day", SeedID: "b", SeedResults: &spotify.Artists{{ID: "x"}}},
+	}
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "x": 2}, *recs.GetSeeds())
+	assert.Equal(t, recs, recs.Containing("x"))
+	assert.Equal(t, Recommendations{}, recs.Containing("nope"))
+}
@@ -0,0 +1,61 @@
+package recommend
+
+import (
+	"context"
+
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/mike-webster/spotify-views/spotify"
+)
+
+// Spotify is the part of the spotify api the strategies rely on. It lets
+// the strategies be tested without making any requests.
+type Spotify interface {
+	TopArtists(ctx context.Context, tf spotify.TimeFrame) (*spotify.Artists, error)
+	TopTracks(ctx context.Context, tf spotify.TimeFrame) (*spotify.Tracks, error)
+	SavedTracks(ctx context.Context) (*spotify.Tracks, error)
+	RelatedArtists(ctx context.Context, id string) (*spotify.Artists, error)
+	ArtistTopTracks(ctx context.Context, id string) (*spotify.Tracks, error)
+	AudioFeatures(ctx context.Context, ids []string) (*spotify.AudioFeatures, error)
+	Recommendations(ctx context.Context, r spotify.RecommendationRequest) (*spotify.Recommendation, error)
+	Search(ctx context.Context, query string, opts spotify.SearchOptions) (*spotify.SearchResults, error)
+}
+
+// Live uses the spotify api for the user in the context
+type Live struct{}
+
+// ----
+// Members
+// ----
+
+func (l *Live) TopArtists(ctx context.Context, tf spotify.TimeFrame) (*spotify.Artists, error) {
+	return spotify.GetTopArtists(context.WithValue(ctx, keys.ContextSpotifyTimeRange, tf.Value()))
+}
+
+func (l *Live) TopTracks(ctx context.Context, tf spotify.TimeFrame) (*spotify.Tracks, error) {
+	return spotify.GetTopTracks(ctx, tf)
+}
+
+func (l *Live) SavedTracks(ctx context.Context) (*spotify.Tracks, error) {
+	return spotify.GetSavedTracks(ctx)
+}
+
+func (l *Live) RelatedArtists(ctx context.Context, id string) (*spotify.Artists, error) {
+	a := spotify.Artist{ID: id}
+	return a.GetRelatedArtists(ctx)
+}
+
+func (l *Live) ArtistTopTracks(ctx context.Context, id string) (*spotify.Tracks, error) {
+	return spotify.GetTopTracksForArtist(ctx, id)
+}
+
+func (l *Live) AudioFeatures(ctx context.Context, ids []string) (*spotify.AudioFeatures, error) {
+	return spotify.GetAudioFeatures(ctx, ids)
+}
+
+func (l *Live) Recommendations(ctx context.Context, r spotify.RecommendationRequest) (*spotify.Recommendation, error) {
+	return spotify.GetRecommendations(ctx, r)
+}
+
+func (l *Live) Search(ctx context.Context, query string, opts spotify.SearchOptions) (*spotify.SearchResults, error) {
+	return spotify.Search(ctx, query, opts)
+}
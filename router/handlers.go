@@ -1,40 +1,95 @@
 package router
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"image/png"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mike-webster/spotify-views/genius"
 	"github.com/mike-webster/spotify-views/keys"
 	"github.com/mike-webster/spotify-views/logging"
+	"github.com/mike-webster/spotify-views/recommend"
 	sortablemap "github.com/mike-webster/spotify-views/sortablemap"
 	spotify "github.com/mike-webster/spotify-views/spotify"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	queryStringCode                 = "code"
-	queryStringError                = "error"
-	queryStringTimeRange            = "time_range"
-	cookieKeyToken                  = "svauth"
-	cookieKeyID                     = "svid"
-	cookieKeyRefresh                = "svref"
-	keyArtistInfo            string = "artist-cache"
-	topTracksLimit           int32  = 25
-	topGenresTopTracksLimit  int32  = 50
-	wordCloudTopTracksLimit  int32  = 50
-	spotifyPlayerHeightShort int32  = 80
-	spotifyPlayerHeightTall  int32  = 380
-	spotifyPlayerWidth       int32  = 300
+	queryStringCode                = "code"
+	queryStringError               = "error"
+	queryStringTimeRange           = "time_range"
+	queryStringLimit               = "limit"
+	queryStringOffset              = "offset"
+	queryStringSource              = "source"
+	queryStringPlaylistID          = "playlist_id"
+	queryStringValence             = "valence"
+	queryStringEnergy              = "energy"
+	queryStringBPMTolerance        = "bpm_tolerance"
+	queryStringHalfDouble          = "half_double"
+	queryStringMinBPM              = "min_bpm"
+	queryStringMaxBPM              = "max_bpm"
+	queryStringStartBPM            = "start_bpm"
+	queryStringPeakBPM             = "peak_bpm"
+	queryStringMinEnergy           = "min_energy"
+	queryStringDuration            = "duration"
+	queryStringK                   = "k"
+	queryStringSeed                = "seed"
+	queryStringWeightPrefix        = "weight_"
+	weightGenres                   = "genres"
+	similarTracksLimit             = 20
+	queryStringThreshold           = "threshold"
+	queryStringStrategy            = "strategy"
+	queryStringNovelty             = "novelty"
+	queryStringMarket              = "market"
+	queryStringSeedArtists         = "seed_artists"
+	queryStringSeedTracks          = "seed_tracks"
+	queryStringSeedGenres          = "seed_genres"
+	queryStringDepth               = "depth"
+	queryStringFormat              = "format"
+	queryStringLevel               = "level"
+	queryStringMode                = "mode"
+	queryStringKind                = "kind"
+	queryStringSize                = "size"
+	queryStringPalette             = "palette"
+	queryStringFont                = "font"
+	queryStringMask                = "mask"
+	queryStringType                = "type"
+	queryStringQuery               = "q"
+	queryStringSingles             = "include_singles"
+	queryStringSort                = "sort"
+	cookieKeyToken                 = "svauth"
+	cookieKeyID                    = "svid"
+	cookieKeyRefresh               = "svref"
+	topTracksLimit           int32 = 25
+	topAlbumsLimit                 = 20
+	moodExamplesLimit              = 5
+	topGenresTopTracksLimit  int32 = 50
+	wordCloudTopTracksLimit  int32 = 50
+	spotifyPlayerHeightShort int32 = 80
+	spotifyPlayerHeightTall  int32 = 380
+	spotifyPlayerWidth       int32 = 300
+	playlistSourceRecs             = "recommendations"
+	playlistSourceTop              = "top"
+	playlistSourceTempo            = "tempo"
+	playlistSourceMood             = "mood"
+	playlistSourceCluster          = "cluster"
+	tempoModeRamp                  = "ramp"
+	trackSourceTop                 = "top"
+	trackSourceSaved               = "saved"
+	trackSourcePlaylist            = "playlist"
 
 	ddlOpts = map[string]string{
 		"Recent":         "short_term",
@@ -298,55 +353,115 @@ func handlerTopArtists(c *gin.Context) {
 	return
 }
 
+// handlerUserLibraryTempo builds a workout from the user's saved tracks.
+// By default every track within the bpm range is returned, and the "ramp"
+// mode builds a warm-up, peak and cool-down curve instead.
 func handlerUserLibraryTempo(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	floats := map[string]float64{}
+	for _, k := range []string{queryStringMinBPM, queryStringMaxBPM, queryStringStartBPM, queryStringPeakBPM, queryStringMinEnergy, queryStringDuration} {
+		v := c.Query(k)
+		if len(v) < 1 {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid ", k)
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		floats[k] = f
+	}
+
+	halfDouble := c.Query(queryStringHalfDouble) == "true"
+	// the duration is provided in minutes
+	duration := int64(floats[queryStringDuration] * 60 * 1000)
+
+	ramp := spotify.TempoRamp{
+		StartBPM:       float32(floats[queryStringStartBPM]),
+		PeakBPM:        float32(floats[queryStringPeakBPM]),
+		HalfDouble:     halfDouble,
+		MinEnergy:      float32(floats[queryStringMinEnergy]),
+		TargetDuration: duration,
+	}
+	filter := spotify.TempoFilter{
+		MinBPM:         float32(floats[queryStringMinBPM]),
+		MaxBPM:         float32(floats[queryStringMaxBPM]),
+		HalfDouble:     halfDouble,
+		MinEnergy:      float32(floats[queryStringMinEnergy]),
+		TargetDuration: duration,
+	}
+
+	isRamp := c.Query(queryStringMode) == tempoModeRamp
+	err := filter.Validate()
+	if isRamp {
+		err = ramp.Validate()
+	}
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid tempo options")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
 	t, err := spotify.GetSavedTracks(c)
 	if err != nil {
-		logging.GetLogger(c).WithError(err).Error()
-		c.Status(500)
+		handleSpotifyError(c, err, "couldnt retrieve saved tracks from spotify")
 		return
 	}
 
 	af, err := spotify.GetAudioFeatures(c, t.IDs())
 	if err != nil {
-		c.Status(500)
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
 		return
 	}
-	laf := *af
+
+	var trax spotify.TempoTracks
+	if isRamp {
+		trax = ramp.Build(*t, *af)
+	} else {
+		trax = filter.Filter(*t, *af)
+		// the tracks are picked in the order they're saved in the library
+		// when building to a duration, then sorted fastest first unless
+		// asked otherwise. A ramp keeps the order of its curve.
+		dir := c.Query(queryStringSort)
+		sort.SliceStable(trax, func(i, j int) bool {
+			if dir == "asc" {
+				return trax[i].Tempo < trax[j].Tempo
+			}
+			return trax[i].Tempo > trax[j].Tempo
+		})
+	}
 
 	type item struct {
-		Artist string
-		Title  string
-		Tempo  float32
+		ID       string
+		Artist   string
+		Title    string
+		Tempo    float32
+		Energy   float32
+		Duration int64
 	}
 
 	type viewBag struct {
-		Items []item
+		Items    []item
+		Duration int64
 	}
 
-	m := map[string]float32{}
-	for i := 0; i < len(*t); i++ {
-		tr := (*t)[i]
-		for j := 0; j < len(*af); j++ {
-			ta := laf[j]
-			if tr.ID == ta.ID {
-				m[fmt.Sprint(tr.Artists[0].Name, ":", tr.Name)] = ta.Tempo
-				break
-			}
+	vb := viewBag{Items: []item{}, Duration: trax.Duration()}
+	for _, i := range trax {
+		artist := ""
+		if len(i.Track.Artists) > 0 {
+			artist = i.Track.Artists[0].Name
 		}
-	}
-	sm := sortablemap.GetSortableFloatMap(m)
-	dir := c.Query("sort")
-	if dir != "asc" {
-		sort.Sort(sort.Reverse(sm))
-	} else {
-		sort.Sort(sm)
-	}
-
-	vb := viewBag{}
-	for _, i := range sm {
-		artist := strings.Split(i.Key, ":")[0]
-		title := strings.Split(i.Key, ":")[1]
-		vb.Items = append(vb.Items, item{Artist: artist, Title: title, Tempo: i.Value})
+		vb.Items = append(vb.Items, item{
+			ID:       i.Track.ID,
+			Artist:   artist,
+			Title:    i.Track.Name,
+			Tempo:    i.Tempo,
+			Energy:   i.Energy,
+			Duration: i.Duration,
+		})
 	}
 
 	c.JSON(200, vb)
@@ -366,15 +481,22 @@ func handlerTopArtistsGenres(c *gin.Context) {
 		return
 	}
 
-	genres := artists.GetGenres(c)
-
-	sort.Sort(sort.Reverse(genres))
-	c.JSON(200, *genres)
-	return
+	respondWithGenres(c, *artists)
 }
 
 func handlerCombinedGenres(c *gin.Context) {
 	logger := logging.GetLogger(c)
+	switch c.DefaultQuery(queryStringMode, spotify.GenreModeCount) {
+	case spotify.GenreModeCount:
+	case spotify.GenreModeWeighted:
+		respondWithWeightedGenres(c)
+		return
+	default:
+		logger.WithField("event", "invalid_form").Error(fmt.Sprint("unknown genre mode: ", c.Query(queryStringMode)))
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
 	tr := c.Query(queryStringTimeRange)
 	if len(tr) > 0 {
 		mv := ddlOpts[tr]
@@ -388,8 +510,6 @@ func handlerCombinedGenres(c *gin.Context) {
 		return
 	}
 
-	genres := artists.GetGenres(c)
-
 	trax, err := spotify.GetTopTracks(c, spotify.GetTimeFrame(ddlOpts[tr]))
 	if err != nil {
 		if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
@@ -403,7 +523,7 @@ func handlerCombinedGenres(c *gin.Context) {
 		return
 	}
 
-	genres2, err := trax.GetGenres(c)
+	trackArtists, err := trax.GetArtists(c)
 	if err != nil {
 		if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
 			// TODO: try to refresh token and repeat request
@@ -416,20 +536,8 @@ func handlerCombinedGenres(c *gin.Context) {
 		return
 	}
 
-	for _, g := range *genres2 {
-		i := genres.Contains(g.Key)
-		if i < 0 {
-			// doesn't exist yet
-			(*genres) = append((*genres), g)
-			continue
-		}
-
-		(*genres)[i].Value += g.Value
-	}
-
-	sort.Sort(sort.Reverse(genres))
-	c.JSON(200, *genres)
-	return
+	// an artist in both lists counts for both
+	respondWithGenres(c, append(*artists, *trackArtists...))
 }
 
 func handlerTopTracksGenres(c *gin.Context) {
@@ -454,7 +562,7 @@ func handlerTopTracksGenres(c *gin.Context) {
 		return
 	}
 
-	genres, err := trax.GetGenres(c)
+	artists, err := trax.GetArtists(c)
 	if err != nil {
 		if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
 			// TODO: try to refresh token and repeat request
@@ -467,9 +575,7 @@ func handlerTopTracksGenres(c *gin.Context) {
 		return
 	}
 
-	sort.Sort(sort.Reverse(genres))
-	c.JSON(200, *genres)
-	return
+	respondWithGenres(c, *artists)
 }
 
 func handlerWordCloud(c *gin.Context) {
@@ -505,7 +611,7 @@ func handlerWordCloudData(c *gin.Context) {
 		})
 	}
 
-	wordCounts, err := genius.GetLyricCountForSong(c, searches)
+	wordCounts, err := genius.GetLyricCountForSong(requestContext{c}, searches)
 	if err != nil {
 		logger.WithError(err).Error("couldnt retrieve word counts")
 		c.Status(500)
@@ -529,6 +635,73 @@ func handlerWordCloudData(c *gin.Context) {
 	c.JSON(200, vb)
 }
 
+// handlerWordCloudImage draws the user's lyric or genre cloud and streams it
+// back as a png or svg. Renders are cached by the tracks they're drawn from
+// and their options, so the lyrics aren't retrieved again for a cloud
+// that's already been drawn.
+func handlerWordCloudImage(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	opts, err := parseCloudOptions(c)
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid word cloud options")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	trax, ok := getTracksForSource(c, trackSourceTop)
+	if !ok {
+		return
+	}
+
+	hash, err := cloudCacheKey(*trax, opts)
+	if err != nil {
+		logger.WithError(err).Error("couldnt hash word cloud")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "image/png"
+	if opts.Format == cloudFormatSVG {
+		contentType = "image/svg+xml"
+	}
+
+	etag := fmt.Sprintf(`"%s"`, hash)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age=3600")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if img, ok := getCachedCloud(c, hash); ok {
+		logger.WithField("event", "cache_hit").Debug("word cloud from cache")
+		c.Data(200, contentType, img)
+		return
+	}
+
+	words, ok := getCloudWords(c, opts.Kind, *trax)
+	if !ok {
+		return
+	}
+
+	if len(words) < 1 {
+		logger.Warn("no words for word cloud")
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	img, err := renderWordCloud(words, opts)
+	if err != nil {
+		logger.WithError(err).Error("couldnt render word cloud")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	cacheCloud(c, hash, img)
+	c.Data(200, contentType, img)
+}
+
 func handlerLogin(c *gin.Context) {
 	returl := keys.GetContextValue(c, keys.ContextSpotifyReturnURL)
 	if returl == nil {
@@ -560,359 +733,1485 @@ func handlerHome(c *gin.Context) {
 }
 
 func handlerRecommendations(c *gin.Context) {
-	recs, err := getRecommendations(c)
+	req, err := parseRecommendationRequest(c)
 	if err != nil {
-		logging.GetLogger(c).WithField("event", "failed_recs").Error(err)
-		c.Status(http.StatusInternalServerError)
+		logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid recommendation request")
+		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	c.JSON(200, *recs)
-}
-
-func handlerTest(c *gin.Context) {
-	tr := c.Query(queryStringTimeRange)
-	if len(tr) > 0 {
-		mv := ddlOpts[tr]
-		c.Set(string(keys.ContextSpotifyTimeRange), mv)
-	}
-
-	trax, err := spotify.GetTopTracks(c, spotify.GetTimeFrame(ddlOpts[tr]))
-	if err != nil {
-		if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
-			// TODO: try to refresh token and repeat request
-			c.Redirect(http.StatusTemporaryRedirect, PathHome)
+	filter := recommend.Filter{Novelty: recommend.DefaultNovelty}
+	if v := c.Query(queryStringNovelty); len(v) > 0 {
+		filter.Novelty, err = strconv.ParseFloat(v, 64)
+		if err == nil {
+			err = filter.Validate()
+		}
+		if err != nil {
+			logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid novelty")
+			c.Status(http.StatusBadRequest)
 			return
 		}
-
-		logging.GetLogger(c).WithError(err).Error("couldnt retrieve top tracks from spotify")
-		c.Status(500)
-		return
-	}
-
-	type Result struct {
-		Key        string
-		Value      string
-		Background string
-		Width      int
-		Height     int
-	}
-
-	type tempBag struct {
-		Category string
-		Type     string
-		Opts     []string
-		Results  []Result
 	}
 
-	r := []Result{}
-	for _, i := range *trax {
-		r = append(r, Result{
-			Key:        i.FindArtist(),
-			Value:      i.Name,
-			Background: i.FindImage().URL,
-			Height:     i.FindImage().Height,
-			Width:      i.FindImage().Width,
-		})
-	}
-	data := tempBag{
-		Category: "Tracks",
-		Type:     "",
-		Opts:     []string{"Recent", "In Between", "Going Way Back"},
-		Results:  r,
-	}
-
-	c.HTML(200, "newtops.tmpl", data)
-}
+	// seeds picked by the user (from search) and tuned attributes go
+	// straight to spotify instead of through our strategies
+	if req.Seeds() > 0 || req.Tuned() {
+		if req.Seeds() < 1 {
+			// e.g. "like my top artists but calmer"
+			ctx := context.WithValue(c, keys.ContextSpotifyTimeRange, spotify.TFShort.Value())
+			artists, err := spotify.GetTopArtists(ctx)
+			if err != nil {
+				handleSpotifyError(c, err, "couldnt retrieve top artists for recommendation seeds")
+				return
+			}
 
-// getLevel1Recs retrieves the user's top artists and their related artists
-func getLevel1Recs(ctx context.Context, seeds *spotify.Artists) (*map[string]int, context.Context) {
-	artists := map[string]int{}
-	artistCache := map[string]string{}
+			req.SeedArtists = artists.IDs()
+			if len(req.SeedArtists) > spotify.RecommendationSeedLimit {
+				req.SeedArtists = req.SeedArtists[:spotify.RecommendationSeedLimit]
+			}
+		}
 
-	for _, i := range *seeds {
-		iName := strings.ToLower(i.Name)
-		// if this artist isn't in the return object already, initialize it at
-		if _, ok := artists[iName]; !ok {
-			artists[iName] = 1
-			// I don't know why we're continuing here and I'm guessing this is causing a bug
-			continue
+		if err := req.Validate(); err != nil {
+			logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid recommendation request")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		artists[iName]++
 
-		res, err := i.GetRelatedArtists(ctx)
+		res, err := recommend.RecommendFromSeeds(c, &recommend.Live{}, req, filter)
 		if err != nil {
-			return nil, ctx
+			logging.GetLogger(c).WithField("event", "failed_recs").Error(err)
+			c.Status(http.StatusInternalServerError)
+			return
 		}
 
-		for _, j := range *res {
-			iName := strings.ToLower(j.Name)
-			if _, ok := artists[iName]; !ok {
-				artists[iName] = 1
-				artistCache[iName] = j.ID
-				continue
-			}
-			artists[iName]++
-		}
+		c.JSON(200, res)
+		return
 	}
 
-	return &artists, context.WithValue(ctx, keyArtistInfo, &artistCache)
-}
-
-func removeUsersKnownArtists(ctx context.Context, lib *map[string]int, recs *map[string]int) *[]string {
-
-	sa := sortablemap.GetSortableMap(*recs)
-	sort.Sort(sort.Reverse(sa))
-
-	lm := sortablemap.GetSortableMap(*lib)
-	sort.Sort(sort.Reverse(lm))
-
-	whatsLeft := []string{}
-
-	llib := *lib
-
-	for k, v := range llib {
-		logging.GetLogger(ctx).WithFields(map[string]interface{}{
-			"key":   k,
-			"value": v,
-		}).Info("checking")
+	strategy, err := recommend.Get(c.Query(queryStringStrategy))
+	if err != nil {
+		logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("unknown recommendation strategy")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "strategies": recommend.Names()})
+		return
 	}
 
-	logging.GetLogger(ctx).WithFields(map[string]interface{}{
-		"lib length":  len(llib),
-		"recs length": len(*recs),
-	}).Info("checking data")
-	for k := range *recs {
-		if _, ok := llib[strings.ToLower(k)]; !ok {
-			logging.GetLogger(ctx).WithField("artist", k).Info("not in lib")
-			whatsLeft = append(whatsLeft, strings.ToLower(k))
-		} else {
-			logging.GetLogger(ctx).WithField("artist", k).Info("excluding artist from lib from recs")
-		}
+	res, err := recommend.Recommend(c, &recommend.Live{}, strategy.Name(), req.Limit, filter)
+	if err != nil {
+		logging.GetLogger(c).WithField("event", "failed_recs").Error(err)
+		c.Status(http.StatusInternalServerError)
+		return
 	}
 
-	return &whatsLeft
+	c.JSON(200, res)
 }
 
-func getLevel2Recs(ctx context.Context, recs *spotify.Artists, orderedRecs *map[string]int) *map[string]int {
-	// recs: the user's top spotify artists
-	// orderedRecs: all of the user's recommendations and how many times they were present in the process
-
-	// this method is foobar. I can't retrieve any additional info from spotify
-	// with the way I've been passing around this information.  I need the ids for
-	// api calls, and all I have is names.
-
-	// get the 5 most recommended names
-	rrArtists := map[string]int{}
-	sorted := sortablemap.GetSortableMap(*orderedRecs)
-	sort.Sort(sort.Reverse(sorted))
-	chunk := sorted.Take(5)
-	ids := []string{}
+// playlistRequest holds the options for turning the output of one of our
+// analyses into a spotify playlist.
+type playlistRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Public      bool    `json:"public"`
+	Source      string  `json:"source"`
+	TimeRange   string  `json:"time_range"`
+	Limit       int     `json:"limit"`
+	MinTempo    float32 `json:"min_tempo"`
+	MaxTempo    float32 `json:"max_tempo"`
+	Mood        string  `json:"mood"`
+	// Strategy is the recommendation strategy to use, see the recommend
+	// package for the options
+	Strategy string `json:"strategy"`
+	// Novelty controls how familiar the recommendations can be, it defaults
+	// to recommend.DefaultNovelty
+	Novelty *float64 `json:"novelty"`
+	// MoodThresholds overrides the default valence and energy thresholds
+	MoodThresholds *spotify.MoodThresholds `json:"mood_thresholds"`
+	// Cluster is the id of the cluster to use, along with the options that
+	// produced it
+	Cluster        int                    `json:"cluster"`
+	ClusterOptions spotify.ClusterOptions `json:"cluster_options"`
+	// Cover is an optional base64 encoded jpeg to use as the playlist image
+	Cover string `json:"cover"`
+}
 
-	// getting the name/id artist map out of context
-	cache := *ctx.Value(keyArtistInfo).(*map[string]string)
+func handlerCreatePlaylist(c *gin.Context) {
+	logger := logging.GetLogger(c)
 
-	// iterate through the top 5 recommended
-	for _, i := range chunk {
-		found := false
-		iName := ""
+	req := playlistRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("couldnt parse playlist request")
+		c.Status(http.StatusBadRequest)
+		return
+	}
 
-		// iterate through the user's top artists
-		for _, j := range *recs {
-			iName = strings.ToLower(j.Name)
+	if err := req.Validate(); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid playlist request")
+		c.Status(http.StatusBadRequest)
+		return
+	}
 
-			// if the current top artists is in the top 5 most recommended
-			if i.Key == iName {
-				// add this artist id to the seeds we're going the get recommendations for
-				ids = append(ids, j.ID)
-				found = true
-				break
-			}
+	trax, err := getPlaylistSourceTracks(c, &req)
+	if err != nil {
+		if errors.Is(err, errClusterNotFound) {
+			logger.WithField("cluster", req.Cluster).Info("cluster not found for playlist")
+			c.Status(http.StatusNotFound)
+			return
 		}
 
-		// if we didn't find the current recommendation in the user's top 10 artists
-		if !found {
-			logging.GetLogger(nil).Error(fmt.Sprint("couldnt find artist id; ", iName))
-			if v, ok := cache[iName]; ok {
-				// check the cache for the information for the artist in the recommendation
-				logging.GetLogger(nil).Info("found artist in cache")
-				// add this artist id to the seeds we're going the get recommendations for
-				ids = append(ids, v)
-			}
-		}
+		handleSpotifyError(c, err, "couldnt retrieve tracks for playlist")
+		return
 	}
 
-	res, err := spotify.GetRecommendations(ctx, map[string][]string{spotify.KeySeedArtists: ids})
-	if err != nil {
-		panic(err)
+	if len(*trax) < 1 {
+		logger.WithField("source", req.Source).Info("no tracks found for playlist")
+		c.Status(http.StatusUnprocessableEntity)
+		return
 	}
 
-	for _, j := range res.Tracks {
-		for _, k := range j.Artists {
-			iName := strings.ToLower(k.Name)
-			if _, ok := rrArtists[iName]; !ok {
-				rrArtists[iName] = 1
-				continue
-			}
-
-			rrArtists[iName]++
-		}
-
+	playlist, err := savePlaylist(c, req.Name, req.Description, req.Public, *trax)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt save playlist")
+		return
 	}
 
-	// fold in the original recs
-
-	for k := range *orderedRecs {
-		iName := strings.ToLower(k)
-		if _, ok := rrArtists[iName]; !ok {
-			rrArtists[iName] = 1
-			continue
+	if len(req.Cover) > 0 {
+		// the playlist already exists at this point, so a bad cover image
+		// shouldn't fail the whole request
+		img, err := base64.StdEncoding.DecodeString(req.Cover)
+		if err == nil {
+			err = spotify.UploadPlaylistCover(c, playlist.ID, img)
 		}
 
-		rrArtists[iName]++
+		if err != nil {
+			logger.WithError(err).Warn("couldnt upload playlist cover")
+		}
 	}
 
-	return &rrArtists
-}
+	logger.WithFields(logrus.Fields{
+		"event":  "playlist_created",
+		"source": req.Source,
+		"tracks": len(*trax),
+	}).Info()
 
-func addToCache(cache map[string]string, artists *spotify.Artists) map[string]string {
-	ret := map[string]string{}
-	for _, i := range *artists {
-		iName := strings.ToLower(i.Name)
-		if _, ok := ret[iName]; !ok {
-			ret[iName] = i.ID
-		}
-	}
-	return ret
+	c.JSON(http.StatusCreated, playlist)
 }
 
-type Recommendation struct {
-	Seed        string
-	SeedID      string
-	SeedResults *spotify.Artists
-}
+// errClusterNotFound is returned when the requested cluster isn't one of
+// the clusters the library was grouped into
+var errClusterNotFound = errors.New("cluster not found")
 
-type Recommendations []Recommendation
+// Validate makes sure everything the source needs is provided and in
+// range, so a bad request is rejected before anything is retrieved
+func (r *playlistRequest) Validate() error {
+	if len(r.Name) < 1 {
+		return errors.New("no playlist name provided")
+	}
 
-func (r *Recommendations) GetSeeds() *map[string]int {
-	ids := map[string]int{}
-	for _, i := range *r {
-		if _, ok := ids[i.SeedID]; !ok {
-			ids[i.SeedID] = 1
-		} else {
-			ids[i.SeedID]++
+	switch r.Source {
+	case playlistSourceRecs:
+		if _, err := recommend.Get(r.Strategy); err != nil {
+			return err
 		}
 
-		// go through each reccomendation for each top artist
-		for _, j := range *i.SeedResults {
-			if _, ok := ids[j.ID]; !ok {
-				ids[j.ID] = 1
-			} else {
-				ids[j.ID]++
-			}
+		if r.Novelty != nil {
+			return recommend.Filter{Novelty: *r.Novelty}.Validate()
+		}
+	case playlistSourceTop:
+	case playlistSourceTempo:
+		return spotify.TempoFilter{MinBPM: r.MinTempo, MaxBPM: r.MaxTempo}.Validate()
+	case playlistSourceMood:
+		if !spotify.IsMood(r.Mood) {
+			return errors.New(fmt.Sprint("unsupported mood: ", r.Mood))
+		}
+
+		if r.MoodThresholds != nil {
+			return r.MoodThresholds.Validate()
 		}
+	case playlistSourceCluster:
+		if r.Cluster < 0 {
+			return errors.New(fmt.Sprint("cluster must be 0 or more, got ", r.Cluster))
+		}
+
+		return r.ClusterOptions.Validate()
+	default:
+		return errors.New(fmt.Sprint("unsupported playlist source: ", r.Source))
 	}
 
-	return &ids
+	return nil
 }
 
-func getRecommendations(ctx context.Context) (*spotify.Recommendation, error) {
-	// Start by getting thier top artist from each time frame
-	ctx = context.WithValue(ctx, keys.ContextSpotifyTimeRange, "short_term")
-	topArtists1, err := spotify.GetTopArtists(ctx)
+// savePlaylist creates a new playlist for the user and adds the tracks to
+// it in the order given.
+func savePlaylist(ctx context.Context, name string, description string, public bool, trax spotify.Tracks) (*spotify.Playlist, error) {
+	u, err := spotify.GetUser(ctx)
 	if err != nil {
-		logging.GetLogger(ctx).WithField("event", "rec_art_err_1").WithError(err).Error("couldnt get top artists")
 		return nil, err
 	}
 
-	ctx = context.WithValue(ctx, keys.ContextSpotifyTimeRange, "medium_term")
-	topArtists2, err := spotify.GetTopArtists(ctx)
+	playlist, err := spotify.CreatePlaylist(ctx, u.ID, name, description, public)
 	if err != nil {
-		logging.GetLogger(ctx).WithField("event", "rec_art_err_2").WithError(err).Error("couldnt get top artists")
 		return nil, err
 	}
 
-	ctx = context.WithValue(ctx, keys.ContextSpotifyTimeRange, "long_term")
-	topArtists3, err := spotify.GetTopArtists(ctx)
+	snapshot, err := spotify.AddTracksToPlaylist(ctx, playlist.ID, trax.URIs())
 	if err != nil {
-		logging.GetLogger(ctx).WithField("event", "rec_art_err_3").WithError(err).Error("couldnt get top artists")
 		return nil, err
 	}
+	playlist.SnapshotID = snapshot
+	playlist.Tracks.Total = len(trax)
 
-	// consolidate into one slice
-	sumArtists := append(spotify.Artists{}, *topArtists1...)
-	sumArtists = append(sumArtists, *topArtists2...)
-	sumArtists = append(sumArtists, *topArtists3...)
+	return playlist, nil
+}
 
-	logging.GetLogger(ctx).WithField("top_artist_count", len(sumArtists)).Info()
+// getPlaylistSourceTracks will retrieve the tracks for the analysis the user
+// wants to turn into a playlist. The request should already be validated.
+func getPlaylistSourceTracks(ctx context.Context, req *playlistRequest) (*spotify.Tracks, error) {
+	ret := spotify.Tracks{}
 
-	// iterate through list and find unique artists
-	// - if it's a new artist
-	// - - store it in the arr
-	// - - get related artists from spotify
-	// - - iterate through results
-	// - - - if it's a new artist
-	// - - - - store it in the arr
+	switch req.Source {
+	case playlistSourceRecs:
+		filter := recommend.Filter{Novelty: recommend.DefaultNovelty}
+		if req.Novelty != nil {
+			filter.Novelty = *req.Novelty
+		}
 
-	recs := Recommendations{}
-	// we're iterating through each of the user's top artists to
-	// get their related artists
-	for _, i := range sumArtists {
-		res, err := i.GetRelatedArtists(ctx)
+		res, err := recommend.Recommend(ctx, &recommend.Live{}, req.Strategy, req.Limit, filter)
 		if err != nil {
 			return nil, err
 		}
 
-		seriously := spotify.Artists{}
+		ret = append(ret, res.SpotifyTracks()...)
+	case playlistSourceTop:
+		trax, err := spotify.GetTopTracks(ctx, parseTimeRange(req.TimeRange))
+		if err != nil {
+			return nil, err
+		}
 
-		for _, j := range *res {
-			seriously = append(seriously, j)
+		ret = append(ret, *trax...)
+	case playlistSourceTempo:
+		trax, err := spotify.GetSavedTracks(ctx)
+		if err != nil {
+			return nil, err
 		}
 
-		recs = append(recs, Recommendation{
-			Seed:        i.Name,
-			SeedID:      i.ID,
-			SeedResults: &seriously,
-		})
+		af, err := spotify.GetAudioFeatures(ctx, trax.IDs())
+		if err != nil {
+			return nil, err
+		}
+
+		filter := spotify.TempoFilter{MinBPM: req.MinTempo, MaxBPM: req.MaxTempo}
+		tempos := filter.Filter(*trax, *af)
+		ret = append(ret, tempos.Tracks()...)
+	case playlistSourceMood:
+		thresholds := spotify.DefaultMoodThresholds
+		if req.MoodThresholds != nil {
+			thresholds = *req.MoodThresholds
+		}
+
+		trax, err := spotify.GetSavedTracks(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		af, err := spotify.GetAudioFeatures(ctx, trax.IDs())
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, spotify.FilterByMood(*trax, *af, thresholds, req.Mood)...)
+	case playlistSourceCluster:
+		clusters, err := getLibraryClusters(ctx, req.ClusterOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Cluster >= len(clusters) {
+			return nil, errClusterNotFound
+		}
+
+		ret = append(ret, clusters[req.Cluster].Tracks...)
+	default:
+		return nil, errors.New(fmt.Sprint("unsupported playlist source: ", req.Source))
+	}
+
+	if req.Limit > 0 && len(ret) > req.Limit {
+		ret = ret[:req.Limit]
 	}
 
-	logging.GetLogger(ctx).WithFields(logrus.Fields{
-		"recs_1": len(*recs[0].SeedResults),
-		"recs_2": len(*recs[1].SeedResults),
-		"recs_3": len(*recs[2].SeedResults),
-	}).Info("recommendation counts")
+	return &ret, nil
+}
 
-	seeds := recs.GetSeeds()
-	sortedSeeds := sortablemap.GetSortableMap(*seeds)
-	sort.Sort(sort.Reverse(sortedSeeds))
-	topSeeds := sortedSeeds.Take(5)
-	seedIDs := []string{}
+// getLibraryClusters groups the user's saved tracks into clusters of tracks
+// that sound alike.
+func getLibraryClusters(ctx context.Context, opts spotify.ClusterOptions) ([]spotify.Cluster, error) {
+	trax, err := spotify.GetSavedTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, i := range topSeeds {
-		seedIDs = append(seedIDs, i.Key)
+	af, err := spotify.GetAudioFeatures(ctx, trax.IDs())
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := spotify.GetRecommendations(ctx, map[string][]string{spotify.KeySeedArtists: seedIDs})
+	genres, err := trax.GetTrackGenres(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, nil
+	return spotify.ClusterTracks(*trax, *af, genres, opts), nil
 }
 
-func getArtistCountFromLib(lib *spotify.Tracks) *map[string]int {
-	ret := map[string]int{}
-	for _, i := range *lib {
-		for _, j := range i.Artists {
-			iName := strings.ToLower(j.Name)
-			if _, ok := ret[iName]; !ok {
-				ret[iName] = 1
-				continue
-			}
+func handlerUserPlaylists(c *gin.Context) {
+	playlists, err := spotify.GetUserPlaylists(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve playlists from spotify")
+		return
+	}
 
-			ret[iName]++
-		}
+	c.JSON(200, *playlists)
+}
+
+func handlerPlaylistAnalysis(c *gin.Context) {
+	logger := logging.GetLogger(c)
+	id := c.Param("id")
+
+	trax, err := spotify.GetPlaylistTracks(c, id)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve playlist tracks from spotify")
+		return
+	}
+
+	if len(*trax) < 1 {
+		logger.WithField("playlist", id).Info("no tracks found for playlist")
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	genres, err := trax.GetGenres(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve genres for playlist")
+		return
+	}
+	sort.Sort(sort.Reverse(genres))
+
+	af, err := spotify.GetAudioFeatures(c, trax.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features for playlist")
+		return
+	}
+
+	playlist := spotify.Playlist{ID: id}
+	dupes := spotify.FindDuplicateTracks(
+		spotify.Playlists{playlist},
+		map[string]spotify.Tracks{id: *trax},
+	)
+
+	type analysis struct {
+		ID         string                   `json:"id"`
+		TrackCount int                      `json:"track_count"`
+		Genres     sortablemap.Map          `json:"genres"`
+		Profile    spotify.AudioProfile     `json:"profile"`
+		Duplicates []spotify.DuplicateTrack `json:"duplicates"`
+	}
+
+	c.JSON(200, analysis{
+		ID:         id,
+		TrackCount: len(*trax),
+		Genres:     *genres,
+		Profile:    *af.Profile(),
+		Duplicates: dupes,
+	})
+}
+
+func handlerPlaylistDuplicates(c *gin.Context) {
+	playlists, err := spotify.GetUserPlaylists(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve playlists from spotify")
+		return
+	}
+
+	tracks := map[string]spotify.Tracks{}
+	for _, i := range *playlists {
+		trax, err := spotify.GetPlaylistTracks(c, i.ID)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve playlist tracks from spotify")
+			return
+		}
+
+		tracks[i.ID] = *trax
+	}
+
+	c.JSON(200, spotify.FindDuplicateTracks(*playlists, tracks))
+}
+
+func handlerSearch(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	opts := spotify.SearchOptions{Market: c.Query(queryStringMarket)}
+	if t := c.Query(queryStringType); len(t) > 0 {
+		opts.Types = strings.Split(t, ",")
+	}
+
+	var err error
+	if l := c.Query(queryStringLimit); len(l) > 0 {
+		opts.Limit, err = strconv.Atoi(l)
+		if err != nil {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid search limit")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if o := c.Query(queryStringOffset); len(o) > 0 {
+		opts.Offset, err = strconv.Atoi(o)
+		if err != nil {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid search offset")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	q := c.Query(queryStringQuery)
+	if len(strings.TrimSpace(q)) < 1 {
+		logger.WithField("event", "invalid_form").Error("no search query provided")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid search options")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	res, err := spotify.Search(c, q, opts)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt search spotify")
+		return
+	}
+
+	c.JSON(200, *res)
+}
+
+func handlerTopAlbums(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	limit := topAlbumsLimit
+	if l := c.Query(queryStringLimit); len(l) > 0 {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid album limit")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	top, err := spotify.GetTopTracks(c, parseTimeRange(c.Query(queryStringTimeRange)))
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+		return
+	}
+
+	saved, err := spotify.GetSavedTracks(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve saved tracks from spotify")
+		return
+	}
+
+	ranked := spotify.RankAlbums(*top, *saved, c.Query(queryStringSingles) == "true")
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// the albums attached to tracks are simplified, so swap in the full
+	// album to get the label, popularity, etc.
+	ids := []string{}
+	for _, i := range ranked {
+		ids = append(ids, i.Album.ID)
+	}
+
+	albums, err := spotify.GetAlbums(c, ids)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve albums from spotify")
+		return
+	}
+
+	// spotify leaves out albums it can't find, so they're matched by id
+	// rather than position
+	full := map[string]spotify.Album{}
+	for _, a := range *albums {
+		// we don't need the full track list for each album
+		a.Tracks = nil
+		full[a.ID] = a
+	}
+
+	for i := range ranked {
+		if a, ok := full[ranked[i].Album.ID]; ok {
+			ranked[i].Album = a
+		}
+	}
+
+	c.JSON(200, ranked)
+}
+
+func handlerEras(c *gin.Context) {
+	trax, ok := getTracksForSource(c, trackSourceTop)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, trax.GetEras(time.Now()))
+}
+
+func handlerProfile(c *gin.Context) {
+	trax, ok := getTracksForSource(c, trackSourceTop)
+	if !ok {
+		return
+	}
+
+	af, err := spotify.GetAudioFeatures(c, trax.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+		return
+	}
+
+	c.JSON(200, af.Profile())
+}
+
+func handlerMoods(c *gin.Context) {
+	thresholds, err := parseMoodThresholds(c)
+	if err != nil {
+		logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid mood thresholds")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	moods := map[string]*spotify.MoodDistribution{}
+	for _, tf := range []spotify.TimeFrame{spotify.TFLong, spotify.TFMedium, spotify.TFShort} {
+		trax, err := spotify.GetTopTracks(c, tf)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+			return
+		}
+
+		af, err := spotify.GetAudioFeatures(c, trax.IDs())
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+			return
+		}
+
+		moods[tf.Value()] = spotify.ClassifyMoods(*trax, *af, thresholds, moodExamplesLimit)
+	}
+
+	type moodResponse struct {
+		Thresholds spotify.MoodThresholds               `json:"thresholds"`
+		TimeRanges map[string]*spotify.MoodDistribution `json:"time_ranges"`
+		// Shift is the change in each mood from long term to short term
+		Shift map[string]float64 `json:"shift"`
+	}
+
+	c.JSON(200, moodResponse{
+		Thresholds: thresholds,
+		TimeRanges: moods,
+		Shift:      moods[spotify.TFShort.Value()].Shift(moods[spotify.TFLong.Value()]),
+	})
+}
+
+// handlerMix orders the selected tracks for harmonic mixing. A POST will
+// also save the ordered tracks as a new playlist.
+func handlerMix(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	opts := spotify.MixOptions{HalfDouble: c.Query(queryStringHalfDouble) == "true"}
+	if tol := c.Query(queryStringBPMTolerance); len(tol) > 0 {
+		f, err := strconv.ParseFloat(tol, 64)
+		if err != nil {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid bpm tolerance")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		opts.BPMTolerance = f
+	}
+
+	if err := opts.Validate(); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid mix options")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	req := playlistRequest{}
+	save := c.Request.Method == http.MethodPost
+	if save {
+		err := c.ShouldBindJSON(&req)
+		if err != nil || len(req.Name) < 1 {
+			logger.WithField("event", "invalid_form").WithError(err).Error("couldnt parse mix playlist request")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	trax, ok := getTracksForSource(c, trackSourceTop)
+	if !ok {
+		return
+	}
+
+	af, err := spotify.GetAudioFeatures(c, trax.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+		return
+	}
+
+	mix := spotify.SequenceMix(*trax, *af, opts)
+
+	type mixResponse struct {
+		Options  spotify.MixOptions `json:"options"`
+		Tracks   []spotify.MixTrack `json:"tracks"`
+		Playlist *spotify.Playlist  `json:"playlist,omitempty"`
+	}
+
+	ret := mixResponse{Options: opts, Tracks: mix}
+	if !save {
+		c.JSON(200, ret)
+		return
+	}
+
+	ordered := spotify.Tracks{}
+	for _, i := range mix {
+		ordered = append(ordered, i.Track)
+	}
+
+	ret.Playlist, err = savePlaylist(c, req.Name, req.Description, req.Public, ordered)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt save mix playlist")
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+func handlerClusters(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	opts := spotify.ClusterOptions{}
+	var err error
+	if k := c.Query(queryStringK); len(k) > 0 {
+		opts.K, err = strconv.Atoi(k)
+	}
+	if seed := c.Query(queryStringSeed); len(seed) > 0 && err == nil {
+		opts.Seed, err = strconv.ParseInt(seed, 10, 64)
+	}
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid cluster options")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	clusters, err := getLibraryClusters(c, opts)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt cluster library")
+		return
+	}
+
+	type clusterResponse struct {
+		Options  spotify.ClusterOptions `json:"options"`
+		Clusters []spotify.Cluster      `json:"clusters"`
+	}
+
+	c.JSON(200, clusterResponse{Options: opts, Clusters: clusters})
+}
+
+// handlerSimilarTracks finds the tracks in the user's library, or their top
+// tracks, that sound the most like the given track.
+func handlerSimilarTracks(c *gin.Context) {
+	logger := logging.GetLogger(c)
+	id := c.Param("id")
+
+	weights, err := parseSimilarityWeights(c)
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid similarity weights")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	limit := similarTracksLimit
+	if l := c.Query(queryStringLimit); len(l) > 0 {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid similar tracks limit")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	target, err := spotify.GetTrack(c, id)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve track from spotify")
+		return
+	}
+
+	trax, ok := getTracksForSource(c, trackSourceSaved)
+	if !ok {
+		return
+	}
+
+	all := append(spotify.Tracks{*target}, *trax...)
+	af, err := spotify.GetAudioFeatures(c, all.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+		return
+	}
+
+	features, ok := af.ByID()[target.ID]
+	if !ok {
+		logger.WithField("track", id).Info("no audio features found for track")
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	genres, err := all.GetTrackGenres(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve genres from spotify")
+		return
+	}
+
+	st := spotify.SimilarityTarget{Track: *target, Features: features, Genres: genres[target.ID]}
+	c.JSON(200, spotify.FindSimilarTracks(st, *trax, *af, genres, weights, limit))
+}
+
+// handlerOutliers finds the tracks and artists in the user's top lists that
+// stand out from everything else they listen to.
+func handlerOutliers(c *gin.Context) {
+	threshold := spotify.DefaultOutlierThreshold
+	if t := c.Query(queryStringThreshold); len(t) > 0 {
+		var err error
+		threshold, err = strconv.ParseFloat(t, 64)
+		if err != nil || threshold <= 0 {
+			logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid outlier threshold")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	tf := parseTimeRange(c.Query(queryStringTimeRange))
+	c.Set(string(keys.ContextSpotifyTimeRange), tf.Value())
+
+	trax, err := spotify.GetTopTracks(c, tf)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+		return
+	}
+
+	af, err := spotify.GetAudioFeatures(c, trax.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+		return
+	}
+
+	artists, err := spotify.GetTopArtists(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve top artists from spotify")
+		return
+	}
+
+	type outlierResponse struct {
+		Threshold float64           `json:"threshold"`
+		Tracks    []spotify.Outlier `json:"tracks"`
+		Artists   []spotify.Outlier `json:"artists"`
+	}
+
+	c.JSON(200, outlierResponse{
+		Threshold: threshold,
+		Tracks:    spotify.FindTrackOutliers(*trax, *af, threshold),
+		Artists:   spotify.FindArtistOutliers(*artists, threshold),
+	})
+}
+
+// handlerObscurity scores how mainstream the user's taste is for each time
+// range, or just the one requested, and compares it to other users.
+func handlerObscurity(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	tfs := []spotify.TimeFrame{spotify.TFShort, spotify.TFMedium, spotify.TFLong}
+	if tr := c.Query(queryStringTimeRange); len(tr) > 0 {
+		tfs = []spotify.TimeFrame{parseTimeRange(tr)}
+	}
+
+	// the percentile is a nice to have, so a missing user or database
+	// shouldn't fail the request
+	userID := ""
+	if u, err := spotify.GetUser(c); err == nil {
+		userID = u.ID
+	} else {
+		logger.WithError(err).Warn("couldnt retrieve user for obscurity snapshot")
+	}
+
+	ret := []*spotify.Obscurity{}
+	for _, tf := range tfs {
+		c.Set(string(keys.ContextSpotifyTimeRange), tf.Value())
+
+		trax, err := spotify.GetTopTracks(c, tf)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+			return
+		}
+
+		artists, err := spotify.GetTopArtists(c)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top artists from spotify")
+			return
+		}
+
+		o := spotify.ScoreObscurity(tf, *trax, *artists)
+		if len(userID) > 0 {
+			o.Percentile, err = spotify.GetObscurityPercentile(c, userID, o)
+			if err == nil {
+				err = spotify.SaveObscurity(c, userID, o)
+			}
+
+			if err != nil {
+				logger.WithError(err).Warn("couldnt compare obscurity to other users")
+			}
+		}
+
+		ret = append(ret, o)
+	}
+
+	c.JSON(200, ret)
+}
+
+func handlerTest(c *gin.Context) {
+	tr := c.Query(queryStringTimeRange)
+	if len(tr) > 0 {
+		mv := ddlOpts[tr]
+		c.Set(string(keys.ContextSpotifyTimeRange), mv)
+	}
+
+	trax, err := spotify.GetTopTracks(c, spotify.GetTimeFrame(ddlOpts[tr]))
+	if err != nil {
+		if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
+			// TODO: try to refresh token and repeat request
+			c.Redirect(http.StatusTemporaryRedirect, PathHome)
+			return
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve top tracks from spotify")
+		c.Status(500)
+		return
+	}
+
+	type Result struct {
+		Key        string
+		Value      string
+		Background string
+		Width      int
+		Height     int
+	}
+
+	type tempBag struct {
+		Category string
+		Type     string
+		Opts     []string
+		Results  []Result
+	}
+
+	r := []Result{}
+	for _, i := range *trax {
+		r = append(r, Result{
+			Key:        i.FindArtist(),
+			Value:      i.Name,
+			Background: i.FindImage().URL,
+			Height:     i.FindImage().Height,
+			Width:      i.FindImage().Width,
+		})
+	}
+	data := tempBag{
+		Category: "Tracks",
+		Type:     "",
+		Opts:     []string{"Recent", "In Between", "Going Way Back"},
+		Results:  r,
+	}
+
+	c.HTML(200, "newtops.tmpl", data)
+}
+
+// artistGraphs is shared between requests so related artists fetched for one
+// user don't need to be fetched again for the next.
+var artistGraphs = spotify.NewGraphBuilder()
+
+func handlerArtistGraph(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	opts := spotify.GraphOptions{}
+	for k, v := range map[string]*int{queryStringDepth: &opts.Depth, queryStringLimit: &opts.NodeLimit} {
+		if s := c.Query(k); len(s) > 0 {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				logger.WithField("event", "invalid_form").WithError(err).Error("invalid artist graph options")
+				c.Status(http.StatusBadRequest)
+				return
+			}
+			*v = i
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid artist graph options")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery(queryStringFormat, spotify.GraphFormatJSON)
+	switch format {
+	case spotify.GraphFormatJSON, spotify.GraphFormatDOT, spotify.GraphFormatGraphML:
+	default:
+		logger.WithField("event", "invalid_form").Error(fmt.Sprint("unknown graph format: ", format))
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	seed, err := spotify.GetArtist(c, c.Param("id"))
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve artist from spotify")
+		return
+	}
+
+	graph, err := artistGraphs.Build(c, spotify.Artists{*seed}, opts)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt build artist graph")
+		return
+	}
+
+	switch format {
+	case spotify.GraphFormatDOT:
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.dot"`, seed.ID))
+		c.Data(200, "text/vnd.graphviz; charset=utf-8", []byte(graph.DOT()))
+	case spotify.GraphFormatGraphML:
+		ml, err := graph.GraphML()
+		if err != nil {
+			logger.WithError(err).Error("couldnt render artist graph")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.graphml"`, seed.ID))
+		c.Data(200, "application/graphml+xml; charset=utf-8", []byte(ml))
+	default:
+		c.JSON(200, graph)
+	}
+}
+
+// consentRequest is how a user agrees to have their taste stored and
+// compared with another user's, or to join a blend
+type consentRequest struct {
+	Consent bool `json:"consent"`
+}
+
+const (
+	pairingConsent = "consent is required to compare tastes"
+	// blendConsent tells the user their token is kept, and for how long
+	blendConsent = "consent is required to keep access to your spotify account, so the blend can be " +
+		"refreshed while you're away, until you leave your last blend or it's closed"
+)
+
+// bindConsent makes sure the user has consented. If they haven't the
+// response is handled with the reason consent is needed and false is
+// returned.
+func bindConsent(c *gin.Context, reason string) bool {
+	req := consentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("couldnt parse consent")
+		c.Status(http.StatusBadRequest)
+		return false
+	}
+
+	if !req.Consent {
+		logging.GetLogger(c).WithField("event", "invalid_form").Error(reason)
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return false
+	}
+
+	return true
+}
+
+// handlerCreateInvite stores the inviter's taste and creates the invite
+// code another user can accept to compare tastes with them.
+func handlerCreateInvite(c *gin.Context) {
+	if !bindConsent(c, pairingConsent) {
+		return
+	}
+
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return
+	}
+
+	if !saveTasteSnapshot(c, u.ID) {
+		return
+	}
+
+	p, err := spotify.CreatePairing(c, u.ID)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt create pairing")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// handlerInvite shows the state of the invite, so the invitee can see what
+// they're accepting
+func handlerInvite(c *gin.Context) {
+	p, _, ok := getPairing(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, p)
+}
+
+// handlerAcceptInvite stores the invitee's taste and pairs them with the
+// inviter, then responds with their compatibility.
+func handlerAcceptInvite(c *gin.Context) {
+	if !bindConsent(c, pairingConsent) {
+		return
+	}
+
+	p, u, ok := getPairing(c)
+	if !ok {
+		return
+	}
+
+	if p.Status != spotify.PairingStatusPending || p.InviterID == u.ID {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	if !saveTasteSnapshot(c, u.ID) {
+		return
+	}
+
+	if err := spotify.AcceptPairing(c, p, u.ID); err != nil {
+		if errors.Is(err, spotify.ErrPairingNotPending) {
+			c.Status(http.StatusConflict)
+			return
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt accept pairing")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithCompatibility(c, p, u.ID)
+}
+
+// handlerCompatibility compares the users in an accepted pairing. The
+// current user's taste is refreshed first; their partner's is whatever was
+// last stored.
+func handlerCompatibility(c *gin.Context) {
+	p, u, ok := getPairing(c)
+	if !ok {
+		return
+	}
+
+	if !p.Includes(u.ID) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	if p.Status != spotify.PairingStatusAccepted {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	if !saveTasteSnapshot(c, u.ID) {
+		return
+	}
+
+	respondWithCompatibility(c, p, u.ID)
+}
+
+// handlerRevokeInvite withdraws the user's consent for the pairing
+func handlerRevokeInvite(c *gin.Context) {
+	p, u, ok := getPairing(c)
+	if !ok {
+		return
+	}
+
+	if !p.Includes(u.ID) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	if err := spotify.RevokePairing(c, p); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt revoke pairing")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// blendRequest holds the options for a new blend along with the creator's
+// consent to their token being stored until they leave their last blend
+type blendRequest struct {
+	Consent bool `json:"consent"`
+	spotify.BlendOptions
+}
+
+// handlerCreateBlend starts a blend session with the user as its first
+// member. The playlist is built by the scheduler, or when a member asks for
+// it to be refreshed.
+func handlerCreateBlend(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	req := blendRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("couldnt parse blend request")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if !req.Consent {
+		logger.WithField("event", "invalid_form").Error(blendConsent)
+		c.JSON(http.StatusBadRequest, gin.H{"error": blendConsent})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid blend options")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return
+	}
+
+	if !saveRefreshToken(c, u.ID) {
+		return
+	}
+
+	b, err := spotify.CreateBlend(c, u.ID, req.BlendOptions)
+	if err != nil {
+		logger.WithError(err).Error("couldnt create blend")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, b)
+}
+
+// handlerBlend shows the blend and who's in it
+func handlerBlend(c *gin.Context) {
+	b, _, ok := getBlend(c)
+	if !ok {
+		return
+	}
+
+	members, err := spotify.GetBlendMembers(c, b)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve blend members")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, gin.H{"blend": b, "members": members})
+}
+
+// handlerJoinBlend adds the user to the blend once they've consented
+func handlerJoinBlend(c *gin.Context) {
+	if !bindConsent(c, blendConsent) {
+		return
+	}
+
+	b, u, ok := getBlend(c)
+	if !ok {
+		return
+	}
+
+	if b.Status != spotify.BlendStatusActive {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	if !saveRefreshToken(c, u.ID) {
+		return
+	}
+
+	if err := spotify.JoinBlend(c, b, u.ID); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt join blend")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// handlerLeaveBlend removes the user from the blend, deleting their token if
+// it was their last one. The creator owns the playlist, so they close the
+// blend instead.
+func handlerLeaveBlend(c *gin.Context) {
+	b, u, ok := getBlend(c)
+	if !ok {
+		return
+	}
+
+	if b.CreatorID == u.ID {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	if err := spotify.LeaveBlend(c, b, u.ID); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt leave blend")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// handlerCloseBlend stops the creator's blend from being refreshed
+func handlerCloseBlend(c *gin.Context) {
+	b, u, ok := getBlend(c)
+	if !ok {
+		return
+	}
+
+	if b.CreatorID != u.ID {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	if err := spotify.CloseBlend(c, b); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt close blend")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// handlerRefreshBlend rebuilds the blend's playlist right away, as long as
+// it hasn't been refreshed, or failed to be, too recently
+func handlerRefreshBlend(c *gin.Context) {
+	b, u, ok := getBlend(c)
+	if !ok {
+		return
+	}
+
+	members, err := spotify.GetBlendMembers(c, b)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve blend members")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	isMember := false
+	for _, m := range members {
+		isMember = isMember || m == u.ID
+	}
+	if !isMember {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	if b.Status != spotify.BlendStatusActive {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	res, err := spotify.RequestBlendRefresh(c, b)
+	if err != nil {
+		cooldown := spotify.ErrBlendCooldown{}
+		if errors.As(err, &cooldown) {
+			c.Header("Retry-After", fmt.Sprint(int(cooldown.Wait.Seconds())))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "blend was refreshed recently"})
+			return
+		}
+
+		handleSpotifyError(c, err, "couldnt refresh blend")
+		return
+	}
+
+	c.JSON(200, res)
+}
+
+const (
+	shareTracksLimit = 10
+	shareGenresLimit = 10
+	shareWordsLimit  = 30
+)
+
+// shareRequest holds what a new share should include. WordCloud is opt in
+// since the lyrics take a while to retrieve.
+type shareRequest struct {
+	TimeRange      string `json:"time_range"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+	WordCloud      bool   `json:"word_cloud"`
+}
+
+// handlerCreateShare freezes the user's current results under a new slug
+// that can be viewed without logging in.
+func handlerCreateShare(c *gin.Context) {
+	logger := logging.GetLogger(c)
+
+	req := shareRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("couldnt parse share request")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	expiry, err := spotify.ShareExpiry(req.ExpiresInHours)
+	if err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid share expiry")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return
+	}
+
+	tf := parseTimeRange(req.TimeRange)
+	trax, err := spotify.GetTopTracks(c, tf)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+		return
+	}
+
+	artists, err := spotify.GetTopArtists(context.WithValue(c, keys.ContextSpotifyTimeRange, tf.Value()))
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve top artists from spotify")
+		return
+	}
+
+	genres := artists.GetGenres(c)
+	sort.Sort(sort.Reverse(*genres))
+	obscurity := spotify.ScoreObscurity(tf, *trax, *artists)
+
+	snapshot := spotify.ShareSnapshot{
+		TimeRange: tf.Value(),
+		TopTracks: spotify.NewShareItems(*trax),
+		TopGenres: genres.Take(shareGenresLimit),
+		WordCloud: sortablemap.Map{},
+		Obscurity: &obscurity.Score,
+	}
+	if len(snapshot.TopTracks) > shareTracksLimit {
+		snapshot.TopTracks = snapshot.TopTracks[:shareTracksLimit]
+	}
+
+	// the rest of the share is still worth having without the word cloud
+	if req.WordCloud {
+		searches := []genius.LyricSearch{}
+		for _, t := range *trax {
+			searches = append(searches, genius.LyricSearch{Artist: t.FindArtist(), Track: t.Name})
+		}
+
+		words, err := genius.GetLyricCountForSong(requestContext{c}, searches)
+		if err != nil {
+			logger.WithError(err).Warn("couldnt retrieve word counts for share")
+		} else {
+			sm := sortablemap.GetSortableMap(words)
+			sort.Sort(sort.Reverse(sm))
+			snapshot.WordCloud = sm.Take(shareWordsLimit)
+		}
+	}
+
+	share, err := spotify.CreateShare(c, u.ID, snapshot, expiry)
+	if err != nil {
+		logger.WithError(err).Error("couldnt create share")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// handlerUserShares lists the user's shares that can still be viewed
+func handlerUserShares(c *gin.Context) {
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return
+	}
+
+	shares, err := spotify.GetUserShares(c, u.ID)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve shares")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, shares)
+}
+
+// handlerRevokeShare stops one of the user's shares from being viewed
+func handlerRevokeShare(c *gin.Context) {
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return
+	}
+
+	if err := spotify.RevokeShare(c, u.ID, c.Param("slug")); err != nil {
+		if errors.Is(err, spotify.ErrShareNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt revoke share")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// handlerShare shows a share to anyone with the slug
+func handlerShare(c *gin.Context) {
+	share, ok := getShare(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, share)
+}
+
+// handlerShareCard renders a share as a png
+func handlerShareCard(c *gin.Context) {
+	share, ok := getShare(c)
+	if !ok {
+		return
+	}
+
+	img, err := renderShareCard(share)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt render share card")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt encode share card")
+		c.Status(http.StatusInternalServerError)
+		return
 	}
 
-	return &ret
+	// kept short so a revoked share doesn't live on in caches
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(200, "image/png", buf.Bytes())
 }
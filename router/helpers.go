@@ -2,22 +2,42 @@ package router
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"image/color"
-	"image/png"
+	"image"
 	"net/http"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fogleman/gg"
 	"github.com/gin-gonic/gin"
 	"github.com/mike-webster/spotify-views/env"
+	"github.com/mike-webster/spotify-views/genius"
 	"github.com/mike-webster/spotify-views/keys"
 	"github.com/mike-webster/spotify-views/logging"
+	"github.com/mike-webster/spotify-views/sortablemap"
 	"github.com/mike-webster/spotify-views/spotify"
-	"github.com/psykhi/wordclouds"
+	"github.com/mike-webster/spotify-views/wordcloud"
 	"github.com/sirupsen/logrus"
 )
 
+// requestContext is a gin context that's done when its request is. Gin
+// contexts aren't cancelled on their own, so anything that should stop when
+// the user goes away needs this instead.
+type requestContext struct {
+	*gin.Context
+}
+
+func (c requestContext) Deadline() (time.Time, bool) { return c.Request.Context().Deadline() }
+func (c requestContext) Done() <-chan struct{}       { return c.Request.Context().Done() }
+func (c requestContext) Err() error                  { return c.Request.Context().Err() }
+
 func setCookie(c *gin.Context, key string, val string, secure bool, httpOnly bool) {
 	host := "localhost"
 	if os.Getenv("GO_ENV") == "production" {
@@ -26,60 +46,850 @@ func setCookie(c *gin.Context, key string, val string, secure bool, httpOnly boo
 	c.SetCookie(key, val, 3600, "/", host, secure, httpOnly)
 }
 
-func generateWordCloud(ctx context.Context, filename string, wordCounts map[string]int) error {
-	colors := []color.RGBA{
-		//{0x17, 0xA5, 0x54, 0xff},
-		{0x1E, 0xD7, 0x60, 0xFF},
+// parseTimeRange accepts either the display value used by the client or
+// the value spotify expects and returns the matching TimeFrame.
+func parseTimeRange(tr string) spotify.TimeFrame {
+	if mv, ok := ddlOpts[tr]; ok {
+		return spotify.GetTimeFrame(mv)
+	}
+
+	return spotify.GetTimeFrame(tr)
+}
+
+// handleSpotifyError will send the user home when their token has expired,
+// otherwise the error is logged and a 500 is returned.
+func handleSpotifyError(c *gin.Context, err error, msg string) {
+	if reflect.TypeOf(err) == reflect.TypeOf(spotify.ErrTokenExpired("")) {
+		// TODO: try to refresh token and repeat request
+		c.Redirect(http.StatusTemporaryRedirect, PathHome)
+		return
+	}
+
+	logging.GetLogger(c).WithError(err).Error(msg)
+	c.Status(500)
+}
+
+// getTracksForSource retrieves the tracks an analysis should run against,
+// based on the source query string: the user's top tracks, their saved
+// tracks, or the tracks in one of their playlists. If no source is given
+// the default is used. If the tracks can't be retrieved the response is
+// handled and false is returned.
+func getTracksForSource(c *gin.Context, def string) (*spotify.Tracks, bool) {
+	var trax *spotify.Tracks
+	var err error
+
+	switch c.DefaultQuery(queryStringSource, def) {
+	case trackSourceTop:
+		trax, err = spotify.GetTopTracks(c, parseTimeRange(c.Query(queryStringTimeRange)))
+	case trackSourceSaved:
+		trax, err = spotify.GetSavedTracks(c)
+	case trackSourcePlaylist:
+		id := c.Query(queryStringPlaylistID)
+		if len(id) < 1 {
+			logging.GetLogger(c).WithField("event", "invalid_form").Error("playlist source requires a playlist id")
+			c.Status(http.StatusBadRequest)
+			return nil, false
+		}
+
+		trax, err = spotify.GetPlaylistTracks(c, id)
+	default:
+		logging.GetLogger(c).WithField("event", "invalid_form").Error("unknown track source: ", c.Query(queryStringSource))
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve tracks from spotify")
+		return nil, false
+	}
+
+	return trax, true
+}
+
+// parseMoodThresholds reads the optional valence and energy thresholds from
+// the query string, falling back to the defaults.
+func parseMoodThresholds(c *gin.Context) (spotify.MoodThresholds, error) {
+	ret := spotify.DefaultMoodThresholds
+	if v := c.Query(queryStringValence); len(v) > 0 {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return ret, err
+		}
+		ret.Valence = float32(f)
+	}
+
+	if e := c.Query(queryStringEnergy); len(e) > 0 {
+		f, err := strconv.ParseFloat(e, 32)
+		if err != nil {
+			return ret, err
+		}
+		ret.Energy = float32(f)
+	}
+
+	return ret, ret.Validate()
+}
+
+// parseSimilarityWeights reads any weights from the query string, e.g.
+// weight_energy=2, on top of the default weights.
+func parseSimilarityWeights(c *gin.Context) (spotify.SimilarityWeights, error) {
+	ret := spotify.DefaultSimilarityWeights()
+	for _, f := range append([]string{weightGenres}, spotify.ProfileFeatures...) {
+		v := c.Query(fmt.Sprint(queryStringWeightPrefix, f))
+		if len(v) < 1 {
+			continue
+		}
+
+		w, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return ret, err
+		}
+
+		if f == weightGenres {
+			ret.Genres = w
+			continue
+		}
+		ret.Features[f] = w
+	}
+
+	return ret, ret.Validate()
+}
+
+// respondWithGenres writes how many of the artists have each genre, unless
+// a level was requested. Then the counts for that level are returned along
+// with the whole hierarchy.
+func respondWithGenres(c *gin.Context, artists spotify.Artists) {
+	level := c.Query(queryStringLevel)
+	if len(level) < 1 {
+		genres := artists.GetGenres(c)
+		sort.Sort(sort.Reverse(*genres))
+		c.JSON(200, genres)
+		return
+	}
+
+	h := spotify.RollUpGenres(artists)
+	counts, err := h.Level(level)
+	if err != nil {
+		logging.GetLogger(c).WithField("event", "invalid_form").WithError(err).Error("invalid genre level")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	type genresResponse struct {
+		Level     string                 `json:"level"`
+		Genres    sortablemap.Map        `json:"genres"`
+		Hierarchy spotify.GenreHierarchy `json:"hierarchy"`
+	}
+
+	c.JSON(200, genresResponse{Level: level, Genres: counts, Hierarchy: h})
+}
+
+// respondWithWeightedGenres scores the genres of the user's top artists and
+// the artists on their top tracks, by rank and by time range. Every time
+// range is used unless one was requested. Time range weights can be set
+// with e.g. weight_short_term=2.
+func respondWithWeightedGenres(c *gin.Context) {
+	logger := logging.GetLogger(c)
+	weights := spotify.DefaultGenreWeights()
+	for tr := range weights.TimeRanges {
+		v := c.Query(fmt.Sprint(queryStringWeightPrefix, tr))
+		if len(v) < 1 {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			logger.WithField("event", "invalid_form").WithError(err).Error("invalid genre weight")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		weights.TimeRanges[tr] = f
+	}
+
+	if err := weights.Validate(); err != nil {
+		logger.WithField("event", "invalid_form").WithError(err).Error("invalid genre weights")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	tfs := []spotify.TimeFrame{spotify.TFShort, spotify.TFMedium, spotify.TFLong}
+	if tr := c.Query(queryStringTimeRange); len(tr) > 0 {
+		tfs = []spotify.TimeFrame{parseTimeRange(tr)}
+	}
+
+	sources := []spotify.GenreSource{}
+	for _, tf := range tfs {
+		artists, err := spotify.GetTopArtists(context.WithValue(c, keys.ContextSpotifyTimeRange, tf.Value()))
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top artists from spotify")
+			return
+		}
+
+		trax, err := spotify.GetTopTracks(c, tf)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+			return
+		}
+
+		// the artists on tracks don't come with their genres
+		trackArtists, err := spotify.GetArtists(c, trax.ArtistIDs())
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve artists from spotify")
+			return
+		}
+
+		sources = append(sources,
+			spotify.GenreSource{TimeRange: tf.Value(), Artists: *artists},
+			spotify.GenreSource{TimeRange: tf.Value(), Artists: spotify.TrackArtists(*trax, *trackArtists)},
+		)
+	}
+
+	c.JSON(200, spotify.WeighGenres(sources, weights))
+}
+
+// saveTasteSnapshot stores the user's current top artists, tracks and audio
+// profile from every time range so they can be compared with other users.
+// If it can't be saved the response is handled and false is returned.
+func saveTasteSnapshot(c *gin.Context, userID string) bool {
+	artists := spotify.Artists{}
+	trax := spotify.Tracks{}
+	for _, tf := range []spotify.TimeFrame{spotify.TFShort, spotify.TFMedium, spotify.TFLong} {
+		a, err := spotify.GetTopArtists(context.WithValue(c, keys.ContextSpotifyTimeRange, tf.Value()))
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top artists from spotify")
+			return false
+		}
+		artists = append(artists, *a...)
+
+		t, err := spotify.GetTopTracks(c, tf)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve top tracks from spotify")
+			return false
+		}
+		trax = append(trax, *t...)
+	}
+
+	af, err := spotify.GetAudioFeatures(c, trax.IDs())
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve audio features from spotify")
+		return false
+	}
+
+	snapshot := spotify.NewTasteSnapshot(userID, artists, trax, *af)
+	if err := spotify.SaveTasteSnapshot(c, snapshot); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt save taste snapshot")
+		c.Status(http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// getPairing looks up the pairing for the invite code in the path along
+// with the current user. If either can't be found the response is handled
+// and false is returned.
+func getPairing(c *gin.Context) (*spotify.Pairing, *spotify.User, bool) {
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return nil, nil, false
+	}
+
+	p, err := spotify.GetPairing(c, c.Param("code"))
+	if err != nil {
+		if errors.Is(err, spotify.ErrPairingNotFound) {
+			c.Status(http.StatusNotFound)
+			return nil, nil, false
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve pairing")
+		c.Status(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return p, u, true
+}
+
+// saveRefreshToken stores the user's refresh token so their blends can be
+// refreshed while they're away, until they leave their last blend. If it
+// can't be saved the response is handled and false is returned.
+func saveRefreshToken(c *gin.Context, userID string) bool {
+	refresh := keys.GetContextValue(c, keys.ContextSpotifyRefreshToken)
+	if refresh == nil {
+		logging.GetLogger(c).Error("no refresh token to save")
+		c.Status(http.StatusUnauthorized)
+		return false
+	}
+
+	if err := spotify.SaveRefreshToken(c, userID, fmt.Sprint(refresh)); err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt save refresh token")
+		c.Status(http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// getBlend looks up the blend in the path along with the current user. If
+// either can't be found the response is handled and false is returned.
+func getBlend(c *gin.Context) (*spotify.Blend, *spotify.User, bool) {
+	u, err := spotify.GetUser(c)
+	if err != nil {
+		handleSpotifyError(c, err, "couldnt retrieve user from spotify")
+		return nil, nil, false
+	}
+
+	b, err := spotify.GetBlend(c, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, spotify.ErrBlendNotFound) {
+			c.Status(http.StatusNotFound)
+			return nil, nil, false
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve blend")
+		c.Status(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return b, u, true
+}
+
+// getShare looks up the share for the slug in the path. If it can't be
+// found the response is handled and false is returned.
+func getShare(c *gin.Context) (*spotify.Share, bool) {
+	share, err := spotify.GetShare(c, c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, spotify.ErrShareNotFound) {
+			c.Status(http.StatusNotFound)
+			return nil, false
+		}
+
+		logging.GetLogger(c).WithError(err).Error("couldnt retrieve share")
+		c.Status(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return share, true
+}
+
+// blendSchedulerInterval is how often the scheduler looks for blends that
+// are due to be refreshed
+var blendSchedulerInterval = 15 * time.Minute
+
+// runBlendScheduler refreshes every blend that's due, checking again each
+// interval until the context is done. It runs outside of any request, so it
+// sets up its own secrets and dependencies.
+func runBlendScheduler(ctx context.Context) {
+	logger := logging.GetLogger(ctx).WithField("event", "blend_scheduler")
+
+	secrets, err := env.ParseSecrets(ctx)
+	if err != nil {
+		logger.WithError(err).Error("couldnt parse secrets, blends wont be refreshed")
+		return
+	}
+
+	for k, v := range map[keys.ContextKey]string{
+		keys.ContextSpotifyClientID:     secrets.ClientID,
+		keys.ContextSpotifyClientSecret: secrets.ClientSecret,
+		keys.ContextDatabase:            secrets.DBName,
+		keys.ContextDbHost:              secrets.DBHost,
+		keys.ContextDbUser:              secrets.DBUser,
+		keys.ContextDbPass:              secrets.DBPass,
+	} {
+		ctx = context.WithValue(ctx, k, v)
+	}
+
+	ticker := time.NewTicker(blendSchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		deps := &spotify.Dependencies{Client: &http.Client{}, DB: getDB(ctx)}
+		if deps.DB != nil {
+			refreshDueBlends(context.WithValue(ctx, keys.ContextDependencies, deps))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshDueBlends refreshes the blends one at a time, so one member's
+// rate limit isn't shared across several blends at once
+func refreshDueBlends(ctx context.Context) {
+	logger := logging.GetLogger(ctx).WithField("event", "blend_scheduler")
+
+	blends, err := spotify.GetDueBlends(ctx)
+	if err != nil {
+		logger.WithError(err).Error("couldnt retrieve blends to refresh")
+		return
+	}
+
+	for i := range blends {
+		if _, err := spotify.RefreshBlend(ctx, &blends[i]); err != nil {
+			logger.WithField("blend", blends[i].ID).WithError(err).Error("couldnt refresh blend")
+		}
+	}
+}
+
+// respondWithCompatibility compares the user's stored taste with their
+// partner's in the pairing
+func respondWithCompatibility(c *gin.Context, p *spotify.Pairing, userID string) {
+	logger := logging.GetLogger(c).WithField("code", p.Code)
+
+	you, err := spotify.GetTasteSnapshot(c, userID)
+	if err == nil {
+		var them *spotify.TasteSnapshot
+		them, err = spotify.GetTasteSnapshot(c, p.Partner(userID))
+		if err == nil {
+			c.JSON(200, gin.H{"pairing": p, "compatibility": spotify.CompareTastes(you, them)})
+			return
+		}
+	}
+
+	logger.WithError(err).Error("couldnt retrieve taste snapshots")
+	c.Status(http.StatusInternalServerError)
+}
+
+// parseRecommendationRequest reads the seeds, e.g. seed_artists=1,2, and any
+// tunable attributes, e.g. max_energy=0.4, from the query string. It isn't
+// validated since the seeds may still need to be filled in.
+func parseRecommendationRequest(c *gin.Context) (spotify.RecommendationRequest, error) {
+	ret := spotify.RecommendationRequest{
+		Market: c.Query(queryStringMarket),
+		Min:    map[string]float64{},
+		Max:    map[string]float64{},
+		Target: map[string]float64{},
+	}
+
+	for k, v := range map[string]*[]string{
+		queryStringSeedArtists: &ret.SeedArtists,
+		queryStringSeedTracks:  &ret.SeedTracks,
+		queryStringSeedGenres:  &ret.SeedGenres,
+	} {
+		if s := c.Query(k); len(s) > 0 {
+			*v = strings.Split(s, ",")
+		}
+	}
+
+	if l := c.Query(queryStringLimit); len(l) > 0 {
+		limit, err := strconv.Atoi(l)
+		if err != nil {
+			return ret, err
+		}
+
+		if limit < 1 || limit > spotify.RecommendationMaxLimit {
+			return ret, errors.New(fmt.Sprint("limit must be between 1 and ", spotify.RecommendationMaxLimit, ", got ", limit))
+		}
+		ret.Limit = limit
+	}
+
+	for name := range spotify.RecommendationAttributes {
+		for prefix, vals := range map[string]map[string]float64{"min_": ret.Min, "max_": ret.Max, "target_": ret.Target} {
+			v := c.Query(fmt.Sprint(prefix, name))
+			if len(v) < 1 {
+				continue
+			}
+
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return ret, err
+			}
+			vals[name] = f
+		}
+	}
+
+	return ret, nil
+}
+
+const (
+	fontLight   = "web/fonts/Ubuntu-L.ttf"
+	fontHeading = "web/fonts/PatuaOne-Regular.ttf"
+
+	colorSpotifyBlack = "#191414"
+	colorSpotifyGreen = "#1ED760"
+	colorWhite        = "#FFFFFF"
+
+	// the share card is the size link previews expect
+	shareCardWidth  = 1200
+	shareCardHeight = 630
+	shareCardItems  = 5
+)
+
+// shareTimeRanges describes each time range on the share card
+var shareTimeRanges = map[string]string{
+	spotify.TFShort.Value():  "last 4 weeks",
+	spotify.TFMedium.Value(): "last 6 months",
+	spotify.TFLong.Value():   "all time",
+}
+
+// renderShareCard draws the share as an image sized for link previews,
+// with the same fonts as the word cloud.
+func renderShareCard(s *spotify.Share) (image.Image, error) {
+	dc := gg.NewContext(shareCardWidth, shareCardHeight)
+	dc.SetHexColor(colorSpotifyBlack)
+	dc.Clear()
+
+	if err := dc.LoadFontFace(fontHeading, 56); err != nil {
+		return nil, err
+	}
+	dc.SetHexColor(colorSpotifyGreen)
+	title := "My taste"
+	if tr, ok := shareTimeRanges[s.Snapshot.TimeRange]; ok {
+		title = fmt.Sprint(title, ", ", tr)
+	}
+	dc.DrawString(title, 60, 100)
+
+	if s.Snapshot.Obscurity != nil {
+		dc.DrawStringAnchored(fmt.Sprintf("%.0f%% obscure", *s.Snapshot.Obscurity), shareCardWidth-60, 100, 1, 0)
+	}
+
+	if err := dc.LoadFontFace(fontHeading, 32); err != nil {
+		return nil, err
+	}
+	dc.DrawString("Top tracks", 60, 180)
+	dc.DrawString("Top genres", 60, 450)
+
+	if err := dc.LoadFontFace(fontLight, 26); err != nil {
+		return nil, err
+	}
+	dc.SetHexColor(colorWhite)
+	for i, t := range s.Snapshot.TopTracks {
+		if i >= shareCardItems {
+			break
+		}
+		line := truncateForCard(dc, fmt.Sprint(i+1, ". ", t.Name, " - ", t.Artist), 560)
+		dc.DrawString(line, 60, float64(225+i*40))
+	}
+
+	genres := []string{}
+	for i, g := range s.Snapshot.TopGenres {
+		if i >= shareCardItems {
+			break
+		}
+		genres = append(genres, g.Key)
+	}
+	dc.DrawStringWrapped(strings.Join(genres, " · "), 60, 470, 0, 0, 560, 1.5, gg.AlignLeft)
+
+	if len(s.Snapshot.WordCloud) > 0 {
+		cloud, err := wordcloud.New(s.Snapshot.WordCloud, wordcloud.Options{
+			Width:       440,
+			Height:      400,
+			FontFile:    fontLight,
+			Background:  colorWhite,
+			Colors:      []string{colorSpotifyGreen},
+			MaxFontSize: 80,
+			MinFontSize: 12,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		img, err := cloud.Image()
+		if err != nil {
+			return nil, err
+		}
+
+		dc.SetHexColor(colorWhite)
+		dc.DrawRoundedRectangle(680, 150, 460, 420, 16)
+		dc.Fill()
+		dc.DrawImage(img, 690, 160)
+	}
+
+	return dc.Image(), nil
+}
+
+// truncateForCard shortens the text with an ellipsis until it fits in the
+// width
+func truncateForCard(dc *gg.Context, text string, width float64) string {
+	if w, _ := dc.MeasureString(text); w <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for i := len(runes) - 1; i > 0; i-- {
+		t := fmt.Sprint(string(runes[:i]), "…")
+		if w, _ := dc.MeasureString(t); w <= width {
+			return t
+		}
+	}
+
+	return ""
+}
+
+const (
+	cloudKindLyrics = "lyrics"
+	cloudKindGenres = "genres"
+	cloudFormatPNG  = "png"
+	cloudFormatSVG  = "svg"
+
+	defaultCloudSize = 1024
+	minCloudSize     = 256
+	maxCloudSize     = 2048
+	cloudWordsLimit  = 100
+	cloudCachePrefix = "wordcloud-"
+	cloudCacheTTL    = time.Hour
+	// cloudCacheEntries limits the renders kept in memory, when there
+	// isn't a shared cache, to a few hundred megabytes at the biggest size
+	cloudCacheEntries = 100
+)
+
+// cloudCache holds the renders when the dependencies don't have a cache.
+// It isn't shared, so each server draws a cloud once.
+var cloudCache = &wordcloud.Cache{TTL: cloudCacheTTL, MaxEntries: cloudCacheEntries}
+
+// cloudPalette is the background of a word cloud and the colors the words
+// take turns being drawn in, biggest first
+type cloudPalette struct {
+	Background string
+	Words      []string
+}
+
+var cloudPalettes = map[string]cloudPalette{
+	"spotify": {colorSpotifyBlack, []string{colorSpotifyGreen, colorWhite, "#B3B3B3"}},
+	"light":   {colorWhite, []string{colorSpotifyGreen, colorSpotifyBlack, "#535353"}},
+	"sunset":  {"#2B193D", []string{"#FF8C42", "#FF3C38", "#FFF275", "#F2A7C3"}},
+	"ocean":   {"#0B132B", []string{"#5BC0BE", "#6FFFE9", "#C5D8E8", "#FFFFFF"}},
+}
+
+// cloudFont is a font a word cloud can be drawn in. Family and Weight are
+// what an svg asks the browser for, since the file isn't embedded.
+type cloudFont struct {
+	File   string
+	Family string
+	Weight int
+}
+
+var cloudFonts = map[string]cloudFont{
+	"light":   {fontLight, "Ubuntu, sans-serif", 300},
+	"heading": {fontHeading, "'Patua One', serif", 400},
+}
+
+// cloudOptions are how a word cloud image should be drawn
+type cloudOptions struct {
+	Kind    string `json:"kind"`
+	Format  string `json:"format"`
+	Size    int    `json:"size"`
+	Palette string `json:"palette"`
+	Font    string `json:"font"`
+	Mask    string `json:"mask"`
+}
+
+// parseCloudOptions reads the word cloud options from the query string,
+// falling back to the defaults.
+func parseCloudOptions(c *gin.Context) (cloudOptions, error) {
+	ret := cloudOptions{
+		Kind:    c.DefaultQuery(queryStringKind, cloudKindLyrics),
+		Format:  c.DefaultQuery(queryStringFormat, cloudFormatPNG),
+		Size:    defaultCloudSize,
+		Palette: c.DefaultQuery(queryStringPalette, "spotify"),
+		Font:    c.DefaultQuery(queryStringFont, "light"),
+		Mask:    c.DefaultQuery(queryStringMask, wordcloud.MaskNone),
 	}
 
-	rgbaColors := []color.Color{}
-	for _, i := range colors {
-		rgbaColors = append(rgbaColors, i)
+	if s := c.Query(queryStringSize); len(s) > 0 {
+		size, err := strconv.Atoi(s)
+		if err != nil {
+			return ret, err
+		}
+		ret.Size = size
 	}
 
-	w := wordclouds.NewWordcloud(
-		wordCounts,
-		wordclouds.FontFile("web/fonts/Ubuntu-L.ttf"),
-		wordclouds.Height(2048),
-		wordclouds.Width(2048),
-		wordclouds.Debug(),
-		wordclouds.FontMaxSize(300),
-		wordclouds.FontMinSize(30),
-		wordclouds.Colors(rgbaColors),
-		wordclouds.RandomPlacement(false),
-		wordclouds.MaskBoxes([]*wordclouds.Box{}),
-	)
+	return ret, ret.Validate()
+}
+
+// Validate makes sure each option is one that can be drawn
+func (o cloudOptions) Validate() error {
+	switch o.Kind {
+	case cloudKindLyrics, cloudKindGenres:
+	default:
+		return errors.New(fmt.Sprint("unknown word cloud kind: ", o.Kind))
+	}
+
+	switch o.Format {
+	case cloudFormatPNG, cloudFormatSVG:
+	default:
+		return errors.New(fmt.Sprint("unknown word cloud format: ", o.Format))
+	}
+
+	switch o.Mask {
+	case wordcloud.MaskNone, wordcloud.MaskCircle, wordcloud.MaskDiamond:
+	default:
+		return errors.New(fmt.Sprint("unknown word cloud mask: ", o.Mask))
+	}
+
+	if o.Size < minCloudSize || o.Size > maxCloudSize {
+		return errors.New(fmt.Sprint("size must be between ", minCloudSize, " and ", maxCloudSize, ", got ", o.Size))
+	}
+
+	if _, ok := cloudPalettes[o.Palette]; !ok {
+		return errors.New(fmt.Sprint("unknown word cloud palette: ", o.Palette))
+	}
+
+	if _, ok := cloudFonts[o.Font]; !ok {
+		return errors.New(fmt.Sprint("unknown word cloud font: ", o.Font))
+	}
+
+	return nil
+}
 
-	img := w.Draw()
-	directory := "web/clouds/"
-	of, err := os.Create(fmt.Sprint(directory, filename))
+// cloudCacheKey identifies a render by the tracks its words come from and
+// how it's drawn, so it can be found before the words are retrieved
+func cloudCacheKey(trax spotify.Tracks, opts cloudOptions) (string, error) {
+	b, err := json.Marshal(struct {
+		Tracks  []string     `json:"tracks"`
+		Options cloudOptions `json:"options"`
+	}{trax.IDs(), opts})
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// getCloudWords counts the words for the kind of cloud, from the biggest
+// down. If the words can't be retrieved the response is handled and false
+// is returned.
+func getCloudWords(c *gin.Context, kind string, trax spotify.Tracks) (sortablemap.Map, bool) {
+	var words sortablemap.Map
+	switch kind {
+	case cloudKindGenres:
+		genres, err := trax.GetGenres(c)
+		if err != nil {
+			handleSpotifyError(c, err, "couldnt retrieve genres from spotify")
+			return nil, false
+		}
+		words = *genres
+	default:
+		if len(trax) > int(wordCloudTopTracksLimit) {
+			trax = trax[:wordCloudTopTracksLimit]
+		}
+
+		searches := []genius.LyricSearch{}
+		for _, t := range trax {
+			searches = append(searches, genius.LyricSearch{Artist: t.FindArtist(), Track: t.Name})
+		}
+
+		counts, err := genius.GetLyricCountForSong(requestContext{c}, searches)
+		if err != nil {
+			logging.GetLogger(c).WithError(err).Error("couldnt retrieve word counts")
+			c.Status(http.StatusInternalServerError)
+			return nil, false
+		}
+		words = sortablemap.GetSortableMap(counts)
 	}
 
-	err = png.Encode(of, img)
+	// ties are broken by the word so the same counts always make the same
+	// cloud
+	sort.SliceStable(words, func(i, j int) bool {
+		if words[i].Value != words[j].Value {
+			return words[i].Value > words[j].Value
+		}
+		return words[i].Key < words[j].Key
+	})
+
+	return words.Take(cloudWordsLimit), true
+}
+
+// renderWordCloud draws the words in the requested format
+func renderWordCloud(words sortablemap.Map, opts cloudOptions) ([]byte, error) {
+	f := cloudFonts[opts.Font]
+	palette := cloudPalettes[opts.Palette]
+	cloud, err := wordcloud.New(words, wordcloud.Options{
+		Width:      opts.Size,
+		Height:     opts.Size,
+		FontFile:   f.File,
+		FontFamily: f.Family,
+		FontWeight: f.Weight,
+		Background: palette.Background,
+		Colors:     palette.Words,
+		Mask:       opts.Mask,
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if opts.Format == cloudFormatSVG {
+		return []byte(cloud.SVG()), nil
+	}
+
+	return cloud.PNG()
+}
+
+// getCachedCloud returns the render from the shared cache if there is one,
+// otherwise from memory
+func getCachedCloud(c *gin.Context, hash string) ([]byte, bool) {
+	deps := spotify.GetDependencies(c)
+	if deps == nil || deps.Cache == nil {
+		return cloudCache.Get(hash)
+	}
+
+	val, err := deps.Cache.Get(c, fmt.Sprint(cloudCachePrefix, hash))
+	if err != nil || len(val) < 1 {
+		return nil, false
+	}
+
+	return []byte(val), true
+}
+
+// cacheCloud stores the render in the shared cache if there is one,
+// otherwise in memory
+func cacheCloud(c *gin.Context, hash string, render []byte) {
+	deps := spotify.GetDependencies(c)
+	if deps == nil || deps.Cache == nil {
+		cloudCache.Set(hash, render)
+		return
+	}
+
+	if err := deps.Cache.Set(c, fmt.Sprint(cloudCachePrefix, hash), string(render)); err != nil {
+		logging.GetLogger(c).WithError(err).Warn("couldnt cache word cloud")
 	}
-	return of.Close()
 }
 
 var (
-	PathSpotifyOauth     = "/spotify/oauth"
-	PathSpotifyCodeSwap  = "/token"
-	PathSpotifyReturn    = "/oauthreturn"
-	PathTopTracks        = "/tracks/top"
-	PathTopArtists       = "/artists/top"
-	PathTopArtistGenres  = "/artists/genres"
-	PathTopTracksGenres  = "/tracks/genres"
-	PathCombinedGenres   = "/genres"
-	PathLogin            = "/login"
-	PathHome             = "/"
-	PathWordCloud        = "/wordcloud"
-	PathWordCloudData    = "/wordcloud/data"
-	PathUserLibraryTempo = "/library/tempo"
-	PathRecommendations  = "/tracks/recommendations"
-	PathTest             = "/test"
+	PathSpotifyOauth       = "/spotify/oauth"
+	PathSpotifyCodeSwap    = "/token"
+	PathSpotifyReturn      = "/oauthreturn"
+	PathTopTracks          = "/tracks/top"
+	PathTopArtists         = "/artists/top"
+	PathTopArtistGenres    = "/artists/genres"
+	PathTopTracksGenres    = "/tracks/genres"
+	PathCombinedGenres     = "/genres"
+	PathLogin              = "/login"
+	PathHome               = "/"
+	PathWordCloud          = "/wordcloud"
+	PathWordCloudData      = "/wordcloud/data"
+	PathWordCloudImage     = "/wordcloud/image"
+	PathUserLibraryTempo   = "/library/tempo"
+	PathRecommendations    = "/tracks/recommendations"
+	PathPlaylists          = "/playlists"
+	PathPlaylistDuplicates = "/playlists/duplicates"
+	PathPlaylistAnalysis   = "/playlists/:id/analysis"
+	PathSearch             = "/search"
+	PathTopAlbums          = "/albums/top"
+	PathEras               = "/eras"
+	PathProfile            = "/profile"
+	PathMoods              = "/moods"
+	PathMix                = "/mix"
+	PathClusters           = "/clusters"
+	PathSimilarTracks      = "/tracks/:id/similar"
+	PathOutliers           = "/outliers"
+	PathObscurity          = "/obscurity"
+	PathArtistGraph        = "/artists/:id/graph"
+	PathInvites            = "/compatibility/invites"
+	PathInvite             = "/compatibility/invites/:code"
+	PathInviteAccept       = "/compatibility/invites/:code/accept"
+	PathCompatibility      = "/compatibility/invites/:code/report"
+	PathBlends             = "/blends"
+	PathBlend              = "/blends/:id"
+	PathBlendMembers       = "/blends/:id/members"
+	PathBlendRefresh       = "/blends/:id/refresh"
+	PathShares             = "/shares"
+	PathUserShare          = "/shares/:slug"
+	PathShare              = "/share/:slug"
+	PathShareCard          = "/share/:slug/card.png"
+	PathTest               = "/test"
 )
 
 func Run(ctx context.Context) {
@@ -128,6 +938,10 @@ func runServer(ctx context.Context) {
 	r.GET(PathSpotifyOauth, handlerOauth) // step 2 - code swap
 	r.GET(PathLogin, handlerLogin)        // step 1 - user permission
 
+	// shares are public, anyone with the slug can see them
+	r.GET(PathShare, handlerShare)
+	r.GET(PathShareCard, handlerShareCard)
+
 	if os.Getenv("GO_ENV") != "production" {
 		r.GET(PathTest, authenticate, handlerTest)
 	}
@@ -215,6 +1029,38 @@ func runServer(ctx context.Context) {
 		// api.GET(PathTopTracksGenres, authenticate, handlerTopTracksGenres)
 		api.GET(PathCombinedGenres, authenticate, handlerCombinedGenres)
 		api.GET(PathWordCloudData, authenticate, handlerWordCloudData)
+		api.GET(PathWordCloudImage, authenticate, handlerWordCloudImage)
+		api.GET(PathPlaylists, authenticate, handlerUserPlaylists)
+		api.POST(PathPlaylists, authenticate, handlerCreatePlaylist)
+		api.GET(PathPlaylistDuplicates, authenticate, handlerPlaylistDuplicates)
+		api.GET(PathPlaylistAnalysis, authenticate, handlerPlaylistAnalysis)
+		api.GET(PathSearch, authenticate, handlerSearch)
+		api.GET(PathTopAlbums, authenticate, handlerTopAlbums)
+		api.GET(PathEras, authenticate, handlerEras)
+		api.GET(PathProfile, authenticate, handlerProfile)
+		api.GET(PathMoods, authenticate, handlerMoods)
+		api.GET(PathMix, authenticate, handlerMix)
+		api.GET(PathUserLibraryTempo, authenticate, handlerUserLibraryTempo)
+		api.GET(PathClusters, authenticate, handlerClusters)
+		api.GET(PathSimilarTracks, authenticate, handlerSimilarTracks)
+		api.GET(PathOutliers, authenticate, handlerOutliers)
+		api.GET(PathObscurity, authenticate, handlerObscurity)
+		api.GET(PathArtistGraph, authenticate, handlerArtistGraph)
+		api.POST(PathInvites, authenticate, handlerCreateInvite)
+		api.GET(PathInvite, authenticate, handlerInvite)
+		api.DELETE(PathInvite, authenticate, handlerRevokeInvite)
+		api.POST(PathInviteAccept, authenticate, handlerAcceptInvite)
+		api.GET(PathCompatibility, authenticate, handlerCompatibility)
+		api.POST(PathBlends, authenticate, handlerCreateBlend)
+		api.GET(PathBlend, authenticate, handlerBlend)
+		api.DELETE(PathBlend, authenticate, handlerCloseBlend)
+		api.POST(PathBlendMembers, authenticate, handlerJoinBlend)
+		api.DELETE(PathBlendMembers, authenticate, handlerLeaveBlend)
+		api.POST(PathBlendRefresh, authenticate, handlerRefreshBlend)
+		api.GET(PathShares, authenticate, handlerUserShares)
+		api.POST(PathShares, authenticate, handlerCreateShare)
+		api.DELETE(PathUserShare, authenticate, handlerRevokeShare)
+		api.POST(PathMix, authenticate, handlerMix)
 	}
 
 	env, err := env.ParseEnv()
@@ -222,6 +1068,8 @@ func runServer(ctx context.Context) {
 		panic(err)
 	}
 
+	go runBlendScheduler(ctx)
+
 	r.Run(fmt.Sprint(":", env.Port))
 }
 
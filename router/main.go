@@ -15,6 +15,11 @@ var (
 		"user-top-read",
 		"user-read-email",
 		"user-library-read",
+		"playlist-read-private",
+		"playlist-read-collaborative",
+		"playlist-modify-public",
+		"playlist-modify-private",
+		"ugc-image-upload",
 		// "user-read-playback-position",
 		// "user-read-recently-played",
 	}
@@ -1,12 +1,15 @@
 package router
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
@@ -95,8 +98,31 @@ func authenticate(c *gin.Context) {
 	c.Next()
 }
 
-func setDependencies(c *gin.Context) {
-	//var db *sqlx.DB
+var (
+	dbLock        sync.Mutex
+	db            data.DB
+	dbLastAttempt time.Time
+	// dbRetryInterval keeps requests from waiting on a database that's
+	// down every single time
+	dbRetryInterval = time.Minute
+)
+
+// getDB returns the shared database connection, connecting the first time
+// it's needed. If the connection fails it will be retried after the retry
+// interval, and nil is returned in the meantime.
+func getDB(c context.Context) data.DB {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+
+	if db != nil {
+		return db
+	}
+
+	if time.Since(dbLastAttempt) < dbRetryInterval {
+		return nil
+	}
+	dbLastAttempt = time.Now()
+
 	host := keys.GetContextValue(c, keys.ContextDbHost)
 	user := keys.GetContextValue(c, keys.ContextDbUser)
 	pass := keys.GetContextValue(c, keys.ContextDbPass)
@@ -105,17 +131,24 @@ func setDependencies(c *gin.Context) {
 	if host == nil || user == nil || pass == nil || dbname == nil {
 		report := fmt.Sprintf("%v:%v:%v:%v", host == nil, user == nil, pass == nil, dbname == nil)
 		logging.GetLogger(c).Warn(fmt.Sprint("missing connection string info: ", report))
+		return nil
+	}
+
+	conStr := fmt.Sprintf(`%s:%s@tcp(%s)/%s`, user, pass, host, dbname)
+	live, err := data.GetLiveDB(conStr)
+	if err != nil {
+		logging.GetLogger(c).WithError(err).Error("couldnt connect to database")
+		return nil
 	}
 
-	// conStr := fmt.Sprintf(`%s:%s@tcp(%s)/%s`, user, pass, host, dbname)
-	// db, err := data.GetLiveDB(conStr)
-	// if err != nil {
-	// 	logging.GetLogger(c).WithError(err).Error("couldnt connect to database")
-	// }
+	db = live
+	return db
+}
 
+func setDependencies(c *gin.Context) {
 	deps := spotify.Dependencies{
 		Client: &http.Client{},
-		DB:     nil,
+		DB:     getDB(c),
 	}
 
 	if os.Getenv("GO_ENV") == "development" {
@@ -204,7 +237,7 @@ func CORSMiddleware(c *gin.Context) {
 
 	c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 	c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-	c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+	c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 	if c.Request.Method == "OPTIONS" {
 		c.AbortWithStatus(204)
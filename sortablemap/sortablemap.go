@@ -67,13 +67,13 @@ func (m Map) Value(k string) (int, error) {
 	return 0, errors.New("404")
 }
 
-// Item represents an element in a SortableMap
+// FloatItem represents an element in a FloatMap
 type FloatItem struct {
 	Key   string
 	Value float32
 }
 
-// Map is a sortable map
+// FloatMap is a sortable map of float scores
 type FloatMap []FloatItem
 
 func (m FloatMap) Len() int           { return len(m) }
@@ -122,3 +122,36 @@ func (m FloatMap) Take(limit int) FloatMap {
 	}
 	return ret
 }
+
+// Value returns the score for the key
+func (m FloatMap) Value(k string) (float32, error) {
+	for _, i := range m {
+		if i.Key == k {
+			return i.Value, nil
+		}
+	}
+	return 0, errors.New("404")
+}
+
+// Total returns the sum of every score
+func (m FloatMap) Total() float32 {
+	ret := float32(0)
+	for _, i := range m {
+		ret += i.Value
+	}
+	return ret
+}
+
+// Percentages returns each score as a percentage of the total
+func (m FloatMap) Percentages() FloatMap {
+	ret := FloatMap{}
+	total := m.Total()
+	for _, i := range m {
+		v := float32(0)
+		if total != 0 {
+			v = i.Value / total * 100
+		}
+		ret = append(ret, FloatItem{Key: i.Key, Value: v})
+	}
+	return ret
+}
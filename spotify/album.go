@@ -1,14 +1,296 @@
 package spotify
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/keys"
+)
+
+const (
+	albumsPageLimit      = 20
+	savedAlbumsPageLimit = 50
+	AlbumTypeAlbum       = "album"
+	AlbumTypeSingle      = "single"
+	AlbumTypeCompilation = "compilation"
+)
+
+// Album represents a spotify album
 type Album struct {
-	Name   string  `json:"name"`
-	Images []Image `json:"images"`
+	Links                map[string]string `json:"external_urls"`
+	ID                   string            `json:"id"`
+	URI                  string            `json:"uri"`
+	Name                 string            `json:"name"`
+	AlbumType            string            `json:"album_type"`
+	Artists              []Artist          `json:"artists"`
+	Images               []Image           `json:"images"`
+	Label                string            `json:"label"`
+	Popularity           int32             `json:"popularity"`
+	ReleaseDate          string            `json:"release_date"`
+	ReleaseDatePrecision string            `json:"release_date_precision"`
+	TotalTracks          int               `json:"total_tracks"`
+	// Tracks is only populated when retrieving the full album
+	Tracks *AlbumTracks `json:"tracks,omitempty"`
+}
+
+// AlbumTracks holds the track list for an album
+type AlbumTracks struct {
+	Items Tracks `json:"items"`
+	Total int    `json:"total"`
+}
+
+// Albums is a collection of spotify Albums
+type Albums []Album
+
+// RankedAlbum is an album with a score describing how much the user listens
+// to it.
+type RankedAlbum struct {
+	Album       Album   `json:"album"`
+	Score       float64 `json:"score"`
+	TopTracks   int     `json:"top_tracks"`
+	SavedTracks int     `json:"saved_tracks"`
+}
+
+// ----
+// API
+// ----
+
+// GetAlbum will retrieve the full album for the given id
+func GetAlbum(ctx context.Context, id string) (*Album, error) {
+	req, err := parseRequestForGetAlbum(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponseForGetAlbum(body)
+}
+
+// GetAlbums will retrieve the full albums for the given ids. Spotify only
+// accepts 20 ids per request, so larger sets are requested in batches.
+func GetAlbums(ctx context.Context, ids []string) (*Albums, error) {
+	ret := Albums{}
+	for i := 0; i < len(ids); i += albumsPageLimit {
+		begin, ending := chunkRange(i, len(ids), albumsPageLimit)
+		req, err := parseRequestForGetAlbums(ctx, ids[begin:ending])
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		as, err := parseResponseForGetAlbums(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *as...)
+	}
+
+	return &ret, nil
 }
 
+// GetSavedAlbums will retrieve every album in the user's library
+func GetSavedAlbums(ctx context.Context) (*Albums, error) {
+	url := fmt.Sprint("https://api.spotify.com/v1/me/albums?offset=0&limit=", savedAlbumsPageLimit)
+	ret := Albums{}
+	for len(url) > 0 {
+		req, err := parseRequestForGetSavedAlbums(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		as, next, err := parseResponseForGetSavedAlbums(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *as...)
+		url = next
+	}
+
+	return &ret, nil
+}
+
+// ----
+// Members
+// ----
+
 func (a *Album) Loc() string {
 	if len(a.Images) > 0 {
 		return a.Images[0].URL
 	}
 
 	return ""
-}
\ No newline at end of file
+}
+
+// IDs returns the ID for each of the albums in the collection of Albums
+func (a *Albums) IDs() []string {
+	ret := []string{}
+	for _, i := range *a {
+		ret = append(ret, i.ID)
+	}
+	return ret
+}
+
+// RankAlbums derives the user's top albums from their top tracks and their
+// saved tracks. Top tracks are worth more the higher they're ranked, and
+// every saved track adds a little more weight to its album. Singles are
+// skipped unless includeSingles is set.
+func RankAlbums(top Tracks, saved Tracks, includeSingles bool) []RankedAlbum {
+	found := map[string]*RankedAlbum{}
+	order := []string{}
+	add := func(t Track) *RankedAlbum {
+		if len(t.Album.ID) < 1 {
+			return nil
+		}
+
+		if !includeSingles && strings.ToLower(t.Album.AlbumType) == AlbumTypeSingle {
+			return nil
+		}
+
+		if _, ok := found[t.Album.ID]; !ok {
+			found[t.Album.ID] = &RankedAlbum{Album: t.Album}
+			order = append(order, t.Album.ID)
+		}
+
+		return found[t.Album.ID]
+	}
+
+	for i, t := range top {
+		if ra := add(t); ra != nil {
+			ra.TopTracks++
+			ra.Score += 1 + float64(len(top)-i)/float64(len(top))
+		}
+	}
+
+	for _, t := range saved {
+		if ra := add(t); ra != nil {
+			ra.SavedTracks++
+			ra.Score += 0.5
+		}
+	}
+
+	ret := []RankedAlbum{}
+	for _, k := range order {
+		ret = append(ret, *found[k])
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+func parseRequestForGetAlbum(ctx context.Context, id string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprint("https://api.spotify.com/v1/albums/", id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseResponseForGetAlbum(body *[]byte) (*Album, error) {
+	var ret Album
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func parseRequestForGetAlbums(ctx context.Context, ids []string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	url := fmt.Sprint("https://api.spotify.com/v1/albums?ids=", strings.Join(ids, ","))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseResponseForGetAlbums(body *[]byte) (*Albums, error) {
+	type tempResp struct {
+		Items Albums `json:"albums"`
+	}
+
+	var ret tempResp
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret.Items, nil
+}
+
+func parseRequestForGetSavedAlbums(ctx context.Context, url string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseResponseForGetSavedAlbums(body *[]byte) (*Albums, string, error) {
+	type tempResp struct {
+		Items []struct {
+			Album Album `json:"album"`
+		} `json:"items"`
+		Next string `json:"next"`
+	}
+
+	var ret tempResp
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	as := Albums{}
+	for _, i := range ret.Items {
+		as = append(as, i.Album)
+	}
+
+	return &as, ret.Next, nil
+}
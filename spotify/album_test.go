@@ -1,10 +1,14 @@
 package spotify
 
 import (
-	"testing"
+	"context"
 	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/keys"
 	"github.com/stretchr/testify/assert"
-  )
+)
 
 func TestLog(t *testing.T) {
 	t.Run("EmptyImages", func(t *testing.T) {
@@ -13,7 +17,227 @@ func TestLog(t *testing.T) {
 	})
 	t.Run("MultipleImages", func(t *testing.T) {
 		url := "testurl1"
-		a := Album{Images: []Image{Image{URL:url}, Image{URL: fmt.Sprint(url, "aa")}}}
+		a := Album{Images: []Image{Image{URL: url}, Image{URL: fmt.Sprint(url, "aa")}}}
 		assert.Equal(t, a.Loc(), url)
 	})
-}
\ No newline at end of file
+}
+
+func TestGetAlbum(t *testing.T) {
+	t.Run("TestParseRequestForGetAlbum", func(t *testing.T) {
+		ctx := context.Background()
+		t.Run("no token", func(t *testing.T) {
+			_, err := parseRequestForGetAlbum(ctx, "id")
+			assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+		})
+
+		token := "tok"
+		ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, token)
+
+		t.Run("token gets stored in header", func(t *testing.T) {
+			req, err := parseRequestForGetAlbum(ctx, "id")
+			assert.Nil(t, err)
+			assert.Equal(t, req.Header.Get("Authorization"), fmt.Sprint("Bearer ", token))
+			assert.Equal(t, "/v1/albums/id", req.URL.Path)
+		})
+	})
+
+	t.Run("TestParseResponseForGetAlbum", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(getAlbumPayload)
+
+			a, err := parseResponseForGetAlbum(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, "Enema Of The State", a.Name)
+			assert.Equal(t, "1999-06-01", a.ReleaseDate)
+			assert.Equal(t, "day", a.ReleaseDatePrecision)
+			assert.Equal(t, AlbumTypeAlbum, a.AlbumType)
+			assert.Equal(t, "Geffen", a.Label)
+			assert.Equal(t, int32(78), a.Popularity)
+			assert.Equal(t, 12, a.TotalTracks)
+			assert.Equal(t, "blink-182", a.Artists[0].Name)
+			assert.Equal(t, 2, len(a.Tracks.Items))
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseResponseForGetAlbum(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 200, "{}")
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetAlbum(ctx, "test")
+			assert.Equal(t, nil, err)
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetAlbum(ctx, "test")
+			assert.NotEqual(t, nil, err)
+		})
+	})
+}
+
+func TestGetAlbums(t *testing.T) {
+	t.Run("TestParseRequestForGetAlbums", func(t *testing.T) {
+		_, err := parseRequestForGetAlbums(context.Background(), []string{"1"})
+		assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+	})
+
+	t.Run("TestParseResponseForGetAlbums", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(fmt.Sprint(`{"albums":[`, getAlbumPayload, `]}`))
+			as, err := parseResponseForGetAlbums(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(*as))
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseResponseForGetAlbums(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		ids := []string{}
+		for i := 0; i < albumsPageLimit+5; i++ {
+			ids = append(ids, fmt.Sprint(i))
+		}
+
+		ctx, client := getPagedTestDependencies(context.Background(),
+			`{"albums":[{"id":"1"}]}`,
+			`{"albums":[{"id":"2"}]}`,
+		)
+
+		as, err := GetAlbums(ctx, ids)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, as.IDs())
+		assert.Equal(t, 2, len(client.Requests))
+		assert.Equal(t, "20,21,22,23,24", client.Requests[1].URL.Query().Get("ids"))
+	})
+}
+
+func TestGetSavedAlbums(t *testing.T) {
+	t.Run("TestParseResponseForGetSavedAlbums", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(`{"items":[{"added_at":"2021-04-08T21:17:18Z","album":{"id":"1"}}],"next":"http://next"}`)
+			as, next, err := parseResponseForGetSavedAlbums(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"1"}, as.IDs())
+			assert.Equal(t, "http://next", next)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, _, err := parseResponseForGetSavedAlbums(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(context.Background(),
+			`{"items":[{"album":{"id":"1"}}],"next":"https://api.spotify.com/v1/me/albums?offset=50&limit=50"}`,
+			`{"items":[{"album":{"id":"2"}}]}`,
+		)
+
+		as, err := GetSavedAlbums(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, as.IDs())
+		assert.Equal(t, 2, len(client.Requests))
+	})
+}
+
+func TestRankAlbums(t *testing.T) {
+	enema := Album{ID: "enema", AlbumType: AlbumTypeAlbum}
+	dude := Album{ID: "dude", AlbumType: AlbumTypeAlbum}
+	single := Album{ID: "single", AlbumType: AlbumTypeSingle}
+
+	top := Tracks{
+		{ID: "1", Album: single},
+		{ID: "2", Album: dude},
+		{ID: "3", Album: enema},
+		{ID: "4", Album: enema},
+	}
+	saved := Tracks{
+		{ID: "5", Album: dude},
+		{ID: "6", Album: Album{}},
+	}
+
+	t.Run("SkipsSingles", func(t *testing.T) {
+		ranked := RankAlbums(top, saved, false)
+		assert.Equal(t, 2, len(ranked))
+		assert.Equal(t, "enema", ranked[0].Album.ID)
+		assert.Equal(t, 2, ranked[0].TopTracks)
+		assert.Equal(t, 1, ranked[1].TopTracks)
+		assert.Equal(t, 1, ranked[1].SavedTracks)
+	})
+
+	t.Run("IncludesSingles", func(t *testing.T) {
+		ranked := RankAlbums(top, saved, true)
+		assert.Equal(t, 3, len(ranked))
+	})
+}
+
+var (
+	getAlbumPayload = `{
+		"album_type": "album",
+		"artists": [
+		  {
+			"external_urls": {
+			  "spotify": "https://open.spotify.com/artist/6FBDaR13swtiWwGhX1WQsP"
+			},
+			"id": "6FBDaR13swtiWwGhX1WQsP",
+			"name": "blink-182",
+			"type": "artist",
+			"uri": "spotify:artist:6FBDaR13swtiWwGhX1WQsP"
+		  }
+		],
+		"external_urls": {
+		  "spotify": "https://open.spotify.com/album/4NNv8gOEW2MnSBdWwB7HSp"
+		},
+		"genres": [],
+		"id": "4NNv8gOEW2MnSBdWwB7HSp",
+		"images": [
+		  {
+			"height": 640,
+			"url": "https://i.scdn.co/image/ab67616d0000b2736da502e35a7a3e48de2b0f74",
+			"width": 640
+		  }
+		],
+		"label": "Geffen",
+		"name": "Enema Of The State",
+		"popularity": 78,
+		"release_date": "1999-06-01",
+		"release_date_precision": "day",
+		"total_tracks": 12,
+		"tracks": {
+		  "href": "https://api.spotify.com/v1/albums/4NNv8gOEW2MnSBdWwB7HSp/tracks?offset=0&limit=50",
+		  "items": [
+			{
+			  "id": "2l8XizMNbRq9ssbFCpI4gU",
+			  "name": "Dumpweed",
+			  "uri": "spotify:track:2l8XizMNbRq9ssbFCpI4gU"
+			},
+			{
+			  "id": "6eWgTzjQHYs7UiLgjP5JMs",
+			  "name": "Don't Leave Me",
+			  "uri": "spotify:track:6eWgTzjQHYs7UiLgjP5JMs"
+			}
+		  ],
+		  "limit": 50,
+		  "next": null,
+		  "offset": 0,
+		  "previous": null,
+		  "total": 12
+		},
+		"type": "album",
+		"uri": "spotify:album:4NNv8gOEW2MnSBdWwB7HSp"
+	  }`
+)
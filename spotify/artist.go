@@ -11,6 +11,10 @@ import (
 	"github.com/mike-webster/spotify-views/sortablemap"
 )
 
+const (
+	artistsPageLimit = 50
+)
+
 // Artist represents a spotify Artist
 type Artist struct {
 	Links      map[string]string `json:"external_urls"`
@@ -20,6 +24,12 @@ type Artist struct {
 	URI        string            `json:"uri"`
 	ID         string            `json:"ID"`
 	Images     []Image           `json:"images"`
+	Followers  Followers         `json:"followers"`
+}
+
+// Followers holds the follower count for an artist
+type Followers struct {
+	Total int64 `json:"total"`
 }
 
 // Artists is a collection of spotify Artist
@@ -43,15 +53,31 @@ func GetArtist(ctx context.Context, id string) (*Artist, error) {
 	return parseResponseForGetArtist(body)
 }
 
+// GetArtists will retrieve the artists for the given ids. Spotify only
+// accepts 50 ids per request, so larger sets are requested in batches.
 func GetArtists(ctx context.Context, ids []string) (*Artists, error) {
-	req, err := parseRequestForGetArtists(ctx, ids)
+	ret := Artists{}
+	for i := 0; i < len(ids); i += artistsPageLimit {
+		begin, ending := chunkRange(i, len(ids), artistsPageLimit)
+		req, err := parseRequestForGetArtists(ctx, ids[begin:ending])
+		if err != nil {
+			return nil, err
+		}
 
-	body, err := makeRequest(ctx, req)
-	if err != nil {
-		return nil, err
+		body, err := makeRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		as, err := parseResponseForGetArtists(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *as...)
 	}
 
-	return parseResponseForGetArtists(body)
+	return &ret, nil
 }
 
 func GetTopArtists(ctx context.Context) (*Artists, error) {
@@ -254,17 +280,7 @@ func parseRequestForRelatedArtists(ctx context.Context, id string) (*http.Reques
 
 func parseResponseForRelatedArtists(body *[]byte) (*Artists, error) {
 	type tRes struct {
-		Artists []struct {
-			Followers struct {
-				Total int64 `json:"total"`
-			} `json:"followers"`
-			Genres     []string `json:"genres"`
-			Link       string   `json:"href"`
-			ID         string   `json:"id"`
-			Name       string   `json:"name"`
-			Popularity int32    `json:"popularity"`
-			Type       string   `json:"type"`
-		} `json:"artists"`
+		Artists Artists `json:"artists"`
 	}
 
 	rsp := tRes{}
@@ -273,15 +289,9 @@ func parseResponseForRelatedArtists(body *[]byte) (*Artists, error) {
 		return nil, err
 	}
 
-	ret := Artists{}
-	for _, i := range rsp.Artists {
-		ret = append(ret, Artist{
-			Genres:     i.Genres,
-			ID:         i.ID,
-			Name:       i.Name,
-			Popularity: i.Popularity,
-		})
+	if rsp.Artists == nil {
+		rsp.Artists = Artists{}
 	}
 
-	return &ret, nil
+	return &rsp.Artists, nil
 }
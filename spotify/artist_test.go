@@ -219,6 +219,24 @@ func TestGetArtists(t *testing.T) {
 			_, err := GetArtists(ctx, ids)
 			assert.NotEqual(t, nil, err)
 		})
+
+		t.Run("Batches", func(t *testing.T) {
+			many := []string{}
+			for i := 0; i < artistsPageLimit+1; i++ {
+				many = append(many, fmt.Sprint(i))
+			}
+
+			ctx, client := getPagedTestDependencies(context.Background(),
+				`{"artists":[{"id":"1"}]}`,
+				`{"artists":[{"id":"2"}]}`,
+			)
+
+			as, err := GetArtists(ctx, many)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(*as))
+			assert.Equal(t, 2, len(client.Requests))
+			assert.Equal(t, "50", client.Requests[1].URL.Query().Get("ids"))
+		})
 	})
 }
 
@@ -313,6 +331,13 @@ func TestGetRelatedArtists(t *testing.T) {
 			as, err := parseResponseForRelatedArtists(&bytes)
 			assert.Nil(t, err)
 			assert.True(t, len(*as) == 20, len(*as))
+
+			a := (*as)[0]
+			assert.Equal(t, "0gLjJuczGWhqKVMmVpIT52", a.ID)
+			assert.Equal(t, "spotify:artist:0gLjJuczGWhqKVMmVpIT52", a.URI)
+			assert.Equal(t, int64(219531), a.Followers.Total)
+			assert.Equal(t, 3, len(a.Images))
+			assert.Equal(t, "https://open.spotify.com/artist/0gLjJuczGWhqKVMmVpIT52", a.Links["spotify"])
 		})
 
 		t.Run("bad body", func(t *testing.T) {
@@ -58,6 +58,16 @@ func GetAudioFeatures(ctx context.Context, ids []string) (*AudioFeatures, error)
 // Members
 // ----
 
+// ByID returns the audio features keyed by the id of the track they
+// describe.
+func (a *AudioFeatures) ByID() map[string]AudioFeature {
+	ret := map[string]AudioFeature{}
+	for _, i := range *a {
+		ret[i.ID] = i
+	}
+	return ret
+}
+
 // ----
 // Helpers
 // ----
@@ -77,7 +87,7 @@ func chunkRangeAudioFeatures(start int, ids *[]string) (int, int) {
 		return 0, audioFeaturesPageLimit
 	}
 
-	return start, start + audioFeaturesPageLimit
+	return chunkRange(start, len(*ids), audioFeaturesPageLimit)
 }
 
 func getAudioFeatures(ctx context.Context, ids []string) (*AudioFeatures, error) {
@@ -94,6 +94,24 @@ func TestChunkRangeAudioFeatures(t *testing.T) {
 		assert.Equal(t, audioFeaturesPageLimit+1, beginning)
 		assert.Equal(t, beginning+audioFeaturesPageLimit, ending)
 	})
+
+	t.Run("WhenTheLastPageIsPartial", func(t *testing.T) {
+		ids := []string{}
+		for i := 0; i < 150; i++ {
+			ids = append(ids, fmt.Sprint(i))
+		}
+
+		beginning, ending := chunkRangeAudioFeatures(audioFeaturesPageLimit, &ids)
+		assert.Equal(t, audioFeaturesPageLimit, beginning)
+		assert.Equal(t, len(ids), ending)
+	})
+}
+
+func TestAudioFeaturesByID(t *testing.T) {
+	af := AudioFeatures{{ID: "1", Tempo: 120}, {ID: "2", Tempo: 90}}
+	m := af.ByID()
+	assert.Equal(t, 2, len(m))
+	assert.Equal(t, float32(90), m["2"].Tempo)
 }
 
 var (
@@ -0,0 +1,648 @@
+package spotify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mike-webster/spotify-views/encrypt"
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/mike-webster/spotify-views/logging"
+)
+
+const (
+	BlendStatusActive = "active"
+	BlendStatusClosed = "closed"
+	// BlendStatusFailed is a blend that stopped being refreshed because it
+	// failed too many times in a row
+	BlendStatusFailed = "failed"
+
+	DefaultBlendSize         = 50
+	MaxBlendSize             = 100
+	MaxBlendRecommendations  = 50
+	DefaultBlendRefreshHours = 24
+	// MinBlendRefreshHours keeps a blend from using up its members' rate
+	// limits
+	MinBlendRefreshHours = 6
+	// MaxBlendRefreshFailures is how many refreshes in a row can fail
+	// before the blend is given up on. The scheduler waits an hour longer
+	// after each failure, so a blend gets about ten hours to recover.
+	MaxBlendRefreshFailures = 5
+
+	// blendSharedTrackBonus and blendSharedArtistBonus are how much a track
+	// is boosted when every other member also has it, or its artist, in
+	// their top tracks
+	blendSharedTrackBonus  = 1
+	blendSharedArtistBonus = 0.5
+)
+
+var (
+	ErrBlendNotFound = errors.New("blend not found")
+	ErrBlendClosed   = errors.New("blend is closed")
+	ErrBlendEmpty    = errors.New("no blend members have any tracks")
+)
+
+// ErrBlendCooldown is returned when a member asks for a blend to be
+// refreshed too soon after its last refresh or failed attempt
+type ErrBlendCooldown struct {
+	// Wait is how long until the blend can be refreshed again
+	Wait time.Duration
+}
+
+// BlendOptions controls what goes into a blend and how often it's rebuilt
+type BlendOptions struct {
+	Name string `json:"name"`
+	// Size is how many of the members' tracks are in the playlist
+	Size int `json:"size"`
+	// Recommendations is how many tracks, recommended for the group as a
+	// whole, are added on top of the members' tracks
+	Recommendations int `json:"recommendations"`
+	RefreshHours    int `json:"refresh_hours"`
+}
+
+// Blend is a group playlist built from the top tracks of every member. The
+// playlist belongs to the user who created it.
+type Blend struct {
+	ID              string `db:"id" json:"id"`
+	CreatorID       string `db:"creator_id" json:"creator_id"`
+	Name            string `db:"name" json:"name"`
+	Size            int    `db:"size" json:"size"`
+	Recommendations int    `db:"recommendations" json:"recommendations"`
+	RefreshHours    int    `db:"refresh_hours" json:"refresh_hours"`
+	PlaylistID      string `db:"playlist_id" json:"playlist_id"`
+	Status          string `db:"status" json:"status"`
+}
+
+// BlendMember is a member's top tracks, best first
+type BlendMember struct {
+	UserID string
+	Tracks Tracks
+}
+
+// BlendTrack is a track in a blend along with the members that have it in
+// their top tracks
+type BlendTrack struct {
+	Track
+	Members []string `json:"members"`
+	Score   float64  `json:"score"`
+}
+
+// BlendResult is what was written to a blend's playlist
+type BlendResult struct {
+	Blend       *Blend       `json:"blend"`
+	Tracks      []BlendTrack `json:"tracks"`
+	Recommended Tracks       `json:"recommended"`
+}
+
+type blendMemberToken struct {
+	UserID  string `db:"spotify_id"`
+	Refresh string `db:"refresh"`
+}
+
+// ----
+// API
+// ----
+
+// CreateBlend starts a blend with the creator as its first member
+func CreateBlend(ctx context.Context, creatorID string, opts BlendOptions) (*Blend, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := Blend{
+		ID:              id.String(),
+		CreatorID:       creatorID,
+		Name:            opts.Name,
+		Size:            opts.Size,
+		Recommendations: opts.Recommendations,
+		RefreshHours:    opts.RefreshHours,
+		Status:          BlendStatusActive,
+	}
+
+	_, err = deps.DB.Exec(ctx, `INSERT INTO blends (id, creator_id, name, size, recommendations, refresh_hours, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		ret.ID, ret.CreatorID, ret.Name, ret.Size, ret.Recommendations, ret.RefreshHours, ret.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, JoinBlend(ctx, &ret, creatorID)
+}
+
+// GetBlend returns the blend with the given id
+func GetBlend(ctx context.Context, id string) (*Blend, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	rows := []Blend{}
+	err := deps.DB.Select(ctx, &rows, `SELECT id, creator_id, name, size, recommendations, refresh_hours,
+		playlist_id, status FROM blends WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, ErrBlendNotFound
+	}
+
+	return &rows[0], nil
+}
+
+// GetDueBlends returns the active blends that haven't been refreshed within
+// their refresh interval
+func GetDueBlends(ctx context.Context) ([]Blend, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	ret := []Blend{}
+	err := deps.DB.Select(ctx, &ret, `SELECT id, creator_id, name, size, recommendations, refresh_hours,
+		playlist_id, status FROM blends WHERE status = ?
+		AND (refreshed_at IS NULL OR refreshed_at < NOW() - INTERVAL refresh_hours HOUR)
+		AND (failed_at IS NULL OR failed_at < NOW() - INTERVAL failed_refreshes HOUR)`, BlendStatusActive)
+	return ret, err
+}
+
+// BlendRefreshCooldown returns how long until the blend can be refreshed
+// again. Blends can't be refreshed more often than MinBlendRefreshHours, no
+// matter who asks, and a failed attempt counts the same as a refresh.
+func BlendRefreshCooldown(ctx context.Context, b *Blend) (time.Duration, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return 0, errors.New(ErrMissingDeps)
+	}
+
+	// a refresh clears failed_at, so when it's set it's the last attempt
+	seconds := []int{}
+	err := deps.DB.Select(ctx, &seconds, `SELECT GREATEST(0,
+		TIMESTAMPDIFF(SECOND, NOW(), COALESCE(failed_at, refreshed_at) + INTERVAL ? HOUR))
+		FROM blends WHERE id = ? AND COALESCE(failed_at, refreshed_at) IS NOT NULL`, MinBlendRefreshHours, b.ID)
+	if err != nil || len(seconds) < 1 {
+		return 0, err
+	}
+
+	return time.Duration(seconds[0]) * time.Second, nil
+}
+
+// JoinBlend adds the user to the blend. Joining twice does nothing.
+func JoinBlend(ctx context.Context, b *Blend, userID string) error {
+	if b.Status != BlendStatusActive {
+		return ErrBlendClosed
+	}
+
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `INSERT IGNORE INTO blend_members (blend_id, spotify_id) VALUES (?, ?)`, b.ID, userID)
+	return err
+}
+
+// LeaveBlend removes the user from the blend, their tracks will be gone
+// from the playlist the next time it's refreshed. Their refresh token is
+// deleted once they aren't in any active blends.
+func LeaveBlend(ctx context.Context, b *Blend, userID string) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `DELETE FROM blend_members WHERE blend_id = ? AND spotify_id = ?`, b.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.DB.Exec(ctx, `DELETE FROM tokens WHERE spotify_id = ? AND NOT EXISTS (
+		SELECT 1 FROM blend_members m JOIN blends b ON b.id = m.blend_id
+		WHERE m.spotify_id = tokens.spotify_id AND b.status = ?)`, userID, BlendStatusActive)
+	return err
+}
+
+// CloseBlend stops the blend from being refreshed. The playlist is left as
+// it is, and the members' refresh tokens are deleted unless they're in
+// another active blend.
+func CloseBlend(ctx context.Context, b *Blend) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `UPDATE blends SET status = ? WHERE id = ?`, BlendStatusClosed, b.ID)
+	if err != nil {
+		return err
+	}
+
+	b.Status = BlendStatusClosed
+	return deleteBlendTokens(ctx, b)
+}
+
+// GetBlendMembers returns the ids of everyone in the blend, in the order
+// they joined
+func GetBlendMembers(ctx context.Context, b *Blend) ([]string, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	ret := []string{}
+	err := deps.DB.Select(ctx, &ret,
+		`SELECT spotify_id FROM blend_members WHERE blend_id = ? ORDER BY joined_at, spotify_id`, b.ID)
+	return ret, err
+}
+
+// RefreshBlend rebuilds the blend from its members' current top tracks and
+// writes it to the creator's playlist, creating the playlist the first
+// time. It uses the stored refresh tokens, so none of the members need to
+// be logged in. Members whose tracks can't be retrieved are left out, but
+// if the blend still can't be refreshed it's marked as failed after
+// MaxBlendRefreshFailures attempts in a row.
+func RefreshBlend(ctx context.Context, b *Blend) (*BlendResult, error) {
+	if b.Status != BlendStatusActive {
+		return nil, ErrBlendClosed
+	}
+
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	ret, err := refreshBlend(ctx, deps, b)
+	if err != nil {
+		if ferr := failBlendRefresh(ctx, deps, b); ferr != nil {
+			logging.GetLogger(ctx).WithField("blend", b.ID).WithError(ferr).Error("couldnt record blend failure")
+		}
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// RequestBlendRefresh refreshes the blend when a member asks for it, as long
+// as it isn't cooling down from its last refresh or failed attempt.
+func RequestBlendRefresh(ctx context.Context, b *Blend) (*BlendResult, error) {
+	wait, err := BlendRefreshCooldown(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if wait > 0 {
+		return nil, ErrBlendCooldown{Wait: wait}
+	}
+
+	return RefreshBlend(ctx, b)
+}
+
+// BlendTracks interleaves the members' top tracks. Tracks are boosted when
+// other members share them or their artist, and the members take turns
+// picking their best remaining track so everyone is represented.
+func BlendTracks(members []BlendMember, size int) []BlendTrack {
+	owners := map[string][]string{}
+	artistOwners := map[string]map[string]bool{}
+	for _, m := range members {
+		for _, t := range m.Tracks {
+			if !containsString(owners[t.ID], m.UserID) {
+				owners[t.ID] = append(owners[t.ID], m.UserID)
+			}
+
+			if a := mainArtistID(t); len(a) > 0 {
+				if _, ok := artistOwners[a]; !ok {
+					artistOwners[a] = map[string]bool{}
+				}
+				artistOwners[a][m.UserID] = true
+			}
+		}
+	}
+
+	others := float64(len(members) - 1)
+	picks := make([][]BlendTrack, len(members))
+	for i, m := range members {
+		for j, t := range m.Tracks {
+			score := float64(len(m.Tracks)-j) / float64(len(m.Tracks))
+			if others > 0 {
+				shared := float64(len(owners[t.ID])-1) / others
+				sharedArtist := float64(len(artistOwners[mainArtistID(t)])-1) / others
+				score *= 1 + blendSharedTrackBonus*shared + blendSharedArtistBonus*sharedArtist
+			}
+
+			picks[i] = append(picks[i], BlendTrack{Track: t, Members: owners[t.ID], Score: score})
+		}
+
+		sort.SliceStable(picks[i], func(a, b int) bool {
+			return picks[i][a].Score > picks[i][b].Score
+		})
+	}
+
+	ret := []BlendTrack{}
+	taken := map[string]bool{}
+	for len(ret) < size {
+		picked := false
+		for i := range picks {
+			for len(picks[i]) > 0 && taken[picks[i][0].ID] {
+				picks[i] = picks[i][1:]
+			}
+
+			if len(picks[i]) < 1 || len(ret) >= size {
+				continue
+			}
+
+			taken[picks[i][0].ID] = true
+			ret = append(ret, picks[i][0])
+			picks[i] = picks[i][1:]
+			picked = true
+		}
+
+		if !picked {
+			break
+		}
+	}
+
+	return ret
+}
+
+// BlendCentroid is the middle of the group's taste, every member's average
+// audio features averaged again so each member counts the same.
+func BlendCentroid(features []AudioFeatures) map[string]float64 {
+	ret := map[string]float64{}
+	n := 0.0
+	for _, af := range features {
+		if len(af.Analyzed()) < 1 {
+			continue
+		}
+
+		n++
+		mean := af.Mean()
+		for _, f := range ProfileFeatures {
+			// the features come from a fixed list, so this can't fail
+			v, _ := mean.Feature(f)
+			ret[f] += v
+		}
+	}
+
+	for f := range ret {
+		ret[f] /= n
+	}
+
+	return ret
+}
+
+// ----
+// Members
+// ----
+
+func (e ErrBlendCooldown) Error() string {
+	return fmt.Sprint("blend can be refreshed again in ", e.Wait)
+}
+
+// Validate makes sure the options are within range, filling in the
+// defaults for anything that wasn't provided.
+func (o *BlendOptions) Validate() error {
+	if len(o.Name) < 1 {
+		return errors.New(fmt.Sprint(ErrFieldTooShort, "Name"))
+	}
+
+	if o.Size == 0 {
+		o.Size = DefaultBlendSize
+	}
+
+	if o.Size < 1 || o.Size > MaxBlendSize {
+		return errors.New(fmt.Sprint("size must be between 1 and ", MaxBlendSize, ", got ", o.Size))
+	}
+
+	if o.Recommendations < 0 || o.Recommendations > MaxBlendRecommendations {
+		return errors.New(fmt.Sprint("recommendations must be between 0 and ", MaxBlendRecommendations, ", got ", o.Recommendations))
+	}
+
+	if o.RefreshHours == 0 {
+		o.RefreshHours = DefaultBlendRefreshHours
+	}
+
+	if o.RefreshHours < MinBlendRefreshHours {
+		return errors.New(fmt.Sprint("refresh hours must be at least ", MinBlendRefreshHours, ", got ", o.RefreshHours))
+	}
+
+	return nil
+}
+
+// ----
+// Helpers
+// ----
+
+// refreshBlend does the work for RefreshBlend, returning any error that
+// should count as a failed refresh
+func refreshBlend(ctx context.Context, deps *Dependencies, b *Blend) (*BlendResult, error) {
+	tokens := []blendMemberToken{}
+	err := deps.DB.Select(ctx, &tokens, `SELECT m.spotify_id, t.refresh FROM blend_members m
+		JOIN tokens t ON t.spotify_id = m.spotify_id WHERE m.blend_id = ? ORDER BY m.joined_at, m.spotify_id`, b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []BlendMember{}
+	features := []AudioFeatures{}
+	var creatorCtx context.Context
+	for _, t := range tokens {
+		mctx, err := memberContext(ctx, t)
+		if err != nil {
+			if t.UserID == b.CreatorID {
+				return nil, err
+			}
+
+			// a member that's revoked our access shouldn't break the blend
+			// for everyone else
+			logging.GetLogger(ctx).WithField("user", t.UserID).WithError(err).Warn("couldnt refresh blend member token")
+			continue
+		}
+
+		if t.UserID == b.CreatorID {
+			creatorCtx = mctx
+		}
+
+		logger := logging.GetLogger(ctx).WithField("user", t.UserID)
+		trax, err := GetTopTracks(mctx, TFMedium)
+		if err != nil {
+			logger.WithError(err).Warn("couldnt retrieve blend member tracks")
+			continue
+		}
+		members = append(members, BlendMember{UserID: t.UserID, Tracks: *trax})
+
+		// the member's tracks are still used, they just don't help pick
+		// the recommendations
+		if b.Recommendations > 0 {
+			af, err := GetAudioFeatures(mctx, trax.IDs())
+			if err != nil {
+				logger.WithError(err).Warn("couldnt retrieve blend member audio features")
+				continue
+			}
+			features = append(features, *af)
+		}
+	}
+
+	if creatorCtx == nil {
+		return nil, errors.New(fmt.Sprint("no token for blend creator: ", b.CreatorID))
+	}
+
+	if len(members) < 1 {
+		return nil, ErrBlendEmpty
+	}
+
+	ret := BlendResult{Blend: b, Tracks: BlendTracks(members, b.Size), Recommended: Tracks{}}
+	if b.Recommendations > 0 && len(ret.Tracks) > 0 {
+		ret.Recommended, err = blendRecommendations(creatorCtx, ret.Tracks, features, b.Recommendations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uris := []string{}
+	for _, t := range ret.Tracks {
+		uris = append(uris, t.URI)
+	}
+	uris = append(uris, ret.Recommended.URIs()...)
+
+	if len(b.PlaylistID) < 1 {
+		p, err := CreatePlaylist(creatorCtx, b.CreatorID, b.Name,
+			fmt.Sprint("A blend of ", len(members), " listeners, refreshed every ", b.RefreshHours, " hours"), false)
+		if err != nil {
+			return nil, err
+		}
+		b.PlaylistID = p.ID
+	}
+
+	if _, err := ReplacePlaylistTracks(creatorCtx, b.PlaylistID, uris); err != nil {
+		return nil, err
+	}
+
+	_, err = deps.DB.Exec(ctx, `UPDATE blends SET playlist_id = ?, refreshed_at = CURRENT_TIMESTAMP,
+		failed_refreshes = 0, failed_at = NULL WHERE id = ?`, b.PlaylistID, b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// failBlendRefresh counts a failed refresh, giving up on the blend once it's
+// failed too many times in a row
+func failBlendRefresh(ctx context.Context, deps *Dependencies, b *Blend) error {
+	_, err := deps.DB.Exec(ctx, `UPDATE blends SET failed_refreshes = failed_refreshes + 1, failed_at = CURRENT_TIMESTAMP,
+		status = IF(failed_refreshes >= ?, ?, status) WHERE id = ?`, MaxBlendRefreshFailures, BlendStatusFailed, b.ID)
+	if err != nil {
+		return err
+	}
+
+	// the tokens are only deleted if the blend was just given up on and
+	// its members aren't in any other active blends
+	return deleteBlendTokens(ctx, b)
+}
+
+// deleteBlendTokens deletes the refresh tokens of the blend's members that
+// aren't in any active blends anymore
+func deleteBlendTokens(ctx context.Context, b *Blend) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `DELETE t FROM tokens t JOIN blend_members m ON m.spotify_id = t.spotify_id
+		WHERE m.blend_id = ? AND NOT EXISTS (
+			SELECT 1 FROM blend_members o JOIN blends b ON b.id = o.blend_id
+			WHERE o.spotify_id = t.spotify_id AND b.status = ?)`, b.ID, BlendStatusActive)
+	return err
+}
+
+// memberContext gets a fresh access token for the member, returning a
+// context that makes requests as them. The refresh token is saved again if
+// spotify replaced it.
+func memberContext(ctx context.Context, t blendMemberToken) (context.Context, error) {
+	b, err := base64.StdEncoding.DecodeString(t.Refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := encrypt.Decrypt(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := Token{Refresh: string(*refresh)}
+	if _, err := tok.RefreshMe(ctx); err != nil {
+		return nil, err
+	}
+
+	if tok.Refresh != string(*refresh) {
+		if err := SaveRefreshToken(ctx, t.UserID, tok.Refresh); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, tok.Access)
+	return context.WithValue(ctx, keys.ContextSpotifyUserID, t.UserID), nil
+}
+
+// blendRecommendations asks spotify for tracks near the group's centroid,
+// seeded with the tracks the most members share
+func blendRecommendations(ctx context.Context, trax []BlendTrack, features []AudioFeatures, limit int) (Tracks, error) {
+	seeds := append([]BlendTrack{}, trax...)
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return len(seeds[i].Members) > len(seeds[j].Members)
+	})
+
+	req := RecommendationRequest{Limit: limit, Target: BlendCentroid(features)}
+	for i := 0; i < len(seeds) && i < RecommendationSeedLimit; i++ {
+		req.SeedTracks = append(req.SeedTracks, seeds[i].ID)
+	}
+
+	recs, err := GetRecommendations(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[string]bool{}
+	for _, t := range trax {
+		taken[t.ID] = true
+	}
+
+	ret := Tracks{}
+	for _, t := range recs.Tracks {
+		if !taken[t.ID] {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret, nil
+}
+
+func mainArtistID(t Track) string {
+	if len(t.Artists) < 1 {
+		return ""
+	}
+
+	return t.Artists[0].ID
+}
+
+func containsString(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
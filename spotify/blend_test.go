@@ -0,0 +1,270 @@
+package spotify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/mike-webster/spotify-views/data"
+	"github.com/mike-webster/spotify-views/encrypt"
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func blendTrack(id string, artist string) Track {
+	return Track{ID: id, URI: "spotify:track:" + id, Artists: []Artist{{ID: artist}}}
+}
+
+func blendIDs(trax []BlendTrack) []string {
+	ret := []string{}
+	for _, t := range trax {
+		ret = append(ret, t.ID)
+	}
+	return ret
+}
+
+func TestBlendTracks(t *testing.T) {
+	members := []BlendMember{
+		{UserID: "a", Tracks: Tracks{blendTrack("a1", "x"), blendTrack("a2", "x"), blendTrack("s1", "y"), blendTrack("a3", "z")}},
+		{UserID: "b", Tracks: Tracks{blendTrack("b1", "w"), blendTrack("b2", "y"), blendTrack("s1", "y")}},
+		{UserID: "c", Tracks: Tracks{blendTrack("c1", "v")}},
+	}
+
+	t.Run("TakesTurns", func(t *testing.T) {
+		ret := BlendTracks(members, 10)
+
+		// s1 is shared by a and b, so it's boosted above a2 even though a
+		// ranks it lower. c runs out of tracks, so a and b keep taking turns.
+		assert.Equal(t, []string{"a1", "b1", "c1", "s1", "b2", "a2", "a3"}, blendIDs(ret))
+		assert.Equal(t, []string{"a", "b"}, ret[3].Members)
+		assert.Equal(t, []string{"c"}, ret[2].Members)
+		assert.InDelta(t, 0.875, ret[3].Score, 0.0001)
+	})
+
+	t.Run("EveryoneIsRepresented", func(t *testing.T) {
+		ret := BlendTracks(members, 3)
+		assert.Equal(t, []string{"a1", "b1", "c1"}, blendIDs(ret))
+	})
+
+	t.Run("SingleMember", func(t *testing.T) {
+		ret := BlendTracks(members[:1], 2)
+		assert.Equal(t, []string{"a1", "a2"}, blendIDs(ret))
+		assert.Equal(t, 1.0, ret[0].Score)
+	})
+
+	t.Run("NoMembers", func(t *testing.T) {
+		assert.Equal(t, []BlendTrack{}, BlendTracks(nil, 10))
+	})
+}
+
+func TestBlendCentroid(t *testing.T) {
+	ret := BlendCentroid([]AudioFeatures{
+		{{ID: "1", Energy: 1}, {ID: "2", Energy: 0.8}, {}},
+		{{ID: "3", Energy: 0.2}},
+		{},
+		// a member without any analyzed tracks doesn't count
+		{{}},
+	})
+
+	// each member counts the same, no matter how many tracks they have
+	assert.InDelta(t, 0.55, ret[FeatureEnergy], 0.0001)
+	assert.Equal(t, len(ProfileFeatures), len(ret))
+}
+
+func TestBlendOptionsValidate(t *testing.T) {
+	o := BlendOptions{Name: "friends"}
+	assert.Nil(t, o.Validate())
+	assert.Equal(t, DefaultBlendSize, o.Size)
+	assert.Equal(t, DefaultBlendRefreshHours, o.RefreshHours)
+
+	for _, o := range []BlendOptions{
+		{},
+		{Name: "friends", Size: MaxBlendSize + 1},
+		{Name: "friends", Recommendations: -1},
+		{Name: "friends", RefreshHours: 1},
+	} {
+		assert.NotNil(t, o.Validate())
+	}
+}
+
+// testMasterKey is what the stored refresh tokens are encrypted with
+const testMasterKey = "0123456789abcdef0123456789abcdef"
+
+// encryptedToken encrypts the refresh token the way it's stored
+func encryptedToken(t *testing.T, refresh string) string {
+	ctx := context.WithValue(context.Background(), keys.ContextMasterKey, testMasterKey)
+	b, err := encrypt.Encrypt(ctx, []byte(refresh))
+	assert.Nil(t, err)
+	return base64.StdEncoding.EncodeToString(*b)
+}
+
+func TestRefreshBlend(t *testing.T) {
+	ctx, client := getPagedTestDependencies(context.WithValue(context.Background(), keys.ContextMasterKey, testMasterKey),
+		`{"access_token":"a"}`,
+		`{"items":[{"id":"a1","uri":"spotify:track:a1"},{"id":"s1","uri":"spotify:track:s1"}]}`,
+		`{"access_token":"b"}`,
+		`{"items":[{"id":"s1","uri":"spotify:track:s1"},{"id":"b1","uri":"spotify:track:b1"}]}`,
+		`{"id":"playlist"}`,
+		`{"snapshot_id":"snap"}`,
+	)
+	db := &data.TestDB{Rows: map[string]interface{}{
+		"blend": []blendMemberToken{{UserID: "a", Refresh: encryptedToken(t, "ra")}, {UserID: "b", Refresh: encryptedToken(t, "rb")}},
+	}}
+	ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+	ctx = context.WithValue(ctx, keys.ContextSpotifyClientID, "id")
+	ctx = context.WithValue(ctx, keys.ContextSpotifyClientSecret, "secret")
+
+	b := &Blend{ID: "blend", CreatorID: "a", Name: "friends", Size: 10, RefreshHours: 24, Status: BlendStatusActive}
+	ret, err := RefreshBlend(ctx, b)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"s1", "b1", "a1"}, blendIDs(ret.Tracks))
+	assert.Equal(t, Tracks{}, ret.Recommended)
+
+	assert.Equal(t, "playlist", b.PlaylistID)
+	assert.Equal(t, [][]interface{}{{"playlist", "blend"}}, db.Execs)
+
+	// the playlist is made as the creator, and each member's tracks are
+	// requested as them
+	assert.Equal(t, 6, len(client.Requests))
+	assert.Equal(t, "Bearer b", client.Requests[3].Header.Get("Authorization"))
+	assert.Equal(t, "Bearer a", client.Requests[4].Header.Get("Authorization"))
+	body, _ := ioutil.ReadAll(client.Requests[5].Body)
+	assert.Contains(t, string(body), "spotify:track:s1")
+
+	t.Run("Closed", func(t *testing.T) {
+		_, err := RefreshBlend(ctx, &Blend{Status: BlendStatusClosed})
+		assert.Equal(t, ErrBlendClosed, err)
+	})
+
+	t.Run("SkipsMembers", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(ctx,
+			`{"access_token":"a"}`,
+			`{"items":[{"id":"a1","uri":"spotify:track:a1"}]}`,
+			`{"access_token":"b"}`,
+			`not json`,
+			`{"snapshot_id":"snap"}`,
+		)
+		db := &data.TestDB{Rows: db.Rows}
+		ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+
+		// b's tracks couldn't be retrieved, so the blend is made without them
+		ret, err := RefreshBlend(ctx, &Blend{ID: "blend", CreatorID: "a", PlaylistID: "playlist", Size: 10, Status: BlendStatusActive})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a1"}, blendIDs(ret.Tracks))
+		assert.Equal(t, [][]interface{}{{"playlist", "blend"}}, db.Execs)
+	})
+
+	t.Run("RotatedToken", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(ctx,
+			`{"access_token":"a","refresh_token":"ra2"}`,
+			`{"items":[{"id":"a1","uri":"spotify:track:a1"}]}`,
+			`{"access_token":"b"}`,
+			`{"items":[]}`,
+			`{"snapshot_id":"snap"}`,
+		)
+		db := &data.TestDB{Rows: db.Rows}
+		ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+
+		// spotify replaced a's refresh token, so the new one is saved
+		_, err := RefreshBlend(ctx, &Blend{ID: "blend", CreatorID: "a", PlaylistID: "playlist", Size: 10, Status: BlendStatusActive})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(db.Execs))
+		assert.Equal(t, "a", db.Execs[0][0])
+
+		b, err := base64.StdEncoding.DecodeString(db.Execs[0][1].(string))
+		assert.Nil(t, err)
+		refresh, err := encrypt.Decrypt(ctx, b)
+		assert.Nil(t, err)
+		assert.Equal(t, "ra2", string(*refresh))
+	})
+
+	t.Run("NoMasterKey", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(context.Background())
+		db := &data.TestDB{Rows: db.Rows}
+		ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+
+		// the stored tokens can't be read, so spotify is never asked
+		_, err := RefreshBlend(ctx, &Blend{ID: "blend", CreatorID: "a", Size: 10, Status: BlendStatusActive})
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(client.Requests))
+	})
+
+	t.Run("Fails", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(ctx, `not json`)
+		db := &data.TestDB{Rows: db.Rows}
+		ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+
+		// without the creator there's nowhere to write the playlist, so the
+		// failure is counted and any tokens that aren't needed anymore are
+		// deleted
+		_, err := RefreshBlend(ctx, &Blend{ID: "blend", CreatorID: "a", Size: 10, Status: BlendStatusActive})
+		assert.NotNil(t, err)
+		assert.Equal(t, [][]interface{}{
+			{MaxBlendRefreshFailures, BlendStatusFailed, "blend"},
+			{"blend", BlendStatusActive},
+		}, db.Execs)
+	})
+}
+
+func TestBlendRefreshCooldown(t *testing.T) {
+	db := &data.TestDB{}
+	ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+	b := &Blend{ID: "blend"}
+
+	wait, err := BlendRefreshCooldown(ctx, b)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	db.Rows = map[string]interface{}{fmt.Sprint(MinBlendRefreshHours, " blend"): []int{90}}
+	wait, err = BlendRefreshCooldown(ctx, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, wait)
+
+	t.Run("AfterFailure", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(context.WithValue(context.Background(), keys.ContextMasterKey, testMasterKey), `not json`)
+		db := &data.TestDB{Rows: map[string]interface{}{
+			"blend": []blendMemberToken{{UserID: "a", Refresh: encryptedToken(t, "ra")}},
+		}}
+		ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client, DB: db})
+		ctx = context.WithValue(ctx, keys.ContextSpotifyClientID, "id")
+		ctx = context.WithValue(ctx, keys.ContextSpotifyClientSecret, "secret")
+		b := &Blend{ID: "blend", CreatorID: "a", Size: 10, Status: BlendStatusActive}
+
+		_, err := RequestBlendRefresh(ctx, b)
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, len(client.Requests))
+		assert.Equal(t, []interface{}{MaxBlendRefreshFailures, BlendStatusFailed, "blend"}, db.Execs[0])
+
+		// the failed attempt starts the cooldown, so the next request isn't
+		// sent to spotify
+		db.Rows[fmt.Sprint(MinBlendRefreshHours, " blend")] = []int{3600}
+		_, err = RequestBlendRefresh(ctx, b)
+		cooldown := ErrBlendCooldown{}
+		assert.True(t, errors.As(err, &cooldown))
+		assert.Equal(t, time.Hour, cooldown.Wait)
+		assert.Equal(t, 1, len(client.Requests))
+	})
+}
+
+func TestLeaveBlend(t *testing.T) {
+	db := &data.TestDB{}
+	ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+	b := &Blend{ID: "blend", Status: BlendStatusActive}
+
+	t.Run("Leave", func(t *testing.T) {
+		// the member's token is deleted if this was their last blend
+		assert.Nil(t, LeaveBlend(ctx, b, "user"))
+		assert.Equal(t, [][]interface{}{{"blend", "user"}, {"user", BlendStatusActive}}, db.Execs)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		db.Execs = nil
+		assert.Nil(t, CloseBlend(ctx, b))
+		assert.Equal(t, BlendStatusClosed, b.Status)
+		assert.Equal(t, [][]interface{}{{BlendStatusClosed, "blend"}, {"blend", BlendStatusActive}}, db.Execs)
+	})
+}
@@ -0,0 +1,231 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// CamelotMinor is the camelot letter for minor keys
+	CamelotMinor = "A"
+	// CamelotMajor is the camelot letter for major keys
+	CamelotMajor = "B"
+
+	MixTransitionStart    = "start"
+	MixTransitionHarmonic = "harmonic"
+	MixTransitionTempo    = "tempo_only"
+	MixTransitionClash    = "clash"
+	MixTransitionUnknown  = "unknown"
+
+	defaultMixBPMTolerance = 8
+)
+
+// CamelotKey is a key in the camelot wheel notation used by DJs. Keys that
+// share a number, or are one step apart with the same letter, mix well.
+type CamelotKey struct {
+	Number int    `json:"number"`
+	Letter string `json:"letter"`
+}
+
+// MixOptions controls how tracks are sequenced for a mix
+type MixOptions struct {
+	// BPMTolerance is the largest tempo change allowed between two tracks
+	BPMTolerance float64 `json:"bpm_tolerance"`
+	// HalfDouble allows tracks at half or double the tempo to be matched
+	HalfDouble bool `json:"half_double"`
+}
+
+// MixTrack is a track in a sequenced mix, along with how it transitions
+// from the track before it.
+type MixTrack struct {
+	Track      Track   `json:"track"`
+	Camelot    string  `json:"camelot"`
+	OpenKey    string  `json:"open_key"`
+	Tempo      float32 `json:"tempo"`
+	Transition string  `json:"transition"`
+}
+
+// ----
+// Members
+// ----
+
+// String returns the key in camelot notation, e.g. 8A
+func (c CamelotKey) String() string {
+	return fmt.Sprint(c.Number, c.Letter)
+}
+
+// OpenKey returns the key in open key notation, e.g. 1m
+func (c CamelotKey) OpenKey() string {
+	suffix := "d"
+	if c.Letter == CamelotMinor {
+		suffix = "m"
+	}
+
+	return fmt.Sprint((c.Number+4)%12+1, suffix)
+}
+
+// Distance returns how many steps around the wheel it takes to get from one
+// key to the other. Switching between major and minor counts as a step.
+func (c CamelotKey) Distance(o CamelotKey) int {
+	d := c.Number - o.Number
+	if d < 0 {
+		d = -d
+	}
+	if d > 6 {
+		d = 12 - d
+	}
+
+	if c.Letter != o.Letter {
+		d++
+	}
+
+	return d
+}
+
+// Compatible returns whether the two keys will mix without clashing
+func (c CamelotKey) Compatible(o CamelotKey) bool {
+	return c.Distance(o) <= 1
+}
+
+// Camelot returns the track's key in camelot notation. If spotify couldn't
+// detect the key false is returned.
+func (a *AudioFeature) Camelot() (CamelotKey, bool) {
+	if a.Key < 0 || a.Key > 11 {
+		return CamelotKey{}, false
+	}
+
+	if a.Mode == 1 {
+		return CamelotKey{Number: (7*a.Key%12+7)%12 + 1, Letter: CamelotMajor}, true
+	}
+
+	// minor keys share a number with their relative major
+	relative := (a.Key + 3) % 12
+	return CamelotKey{Number: (7*relative%12+7)%12 + 1, Letter: CamelotMinor}, true
+}
+
+// Validate fills in the default tolerance when one isn't provided
+func (m *MixOptions) Validate() error {
+	if m.BPMTolerance < 0 {
+		return errors.New(fmt.Sprint("bpm tolerance must be positive, got ", m.BPMTolerance))
+	}
+
+	if m.BPMTolerance == 0 {
+		m.BPMTolerance = defaultMixBPMTolerance
+	}
+
+	return nil
+}
+
+// TempoDifference returns the change in BPM between two tempos. When
+// halfDouble is set, the smallest change to half or double the tempo is
+// used instead if it's closer.
+func TempoDifference(from float32, to float32, halfDouble bool) float64 {
+	ret := math.Abs(float64(to - from))
+	if !halfDouble {
+		return ret
+	}
+
+	for _, mult := range []float64{0.5, 2} {
+		d := math.Abs(float64(to) - float64(from)*mult)
+		if d < ret {
+			ret = d
+		}
+	}
+
+	return ret
+}
+
+// SequenceMix orders the tracks so each one transitions smoothly into the
+// next. Starting from the slowest track, it repeatedly picks the remaining
+// track with the closest compatible key that's within the BPM tolerance.
+// When nothing fits, the closest track is used and the transition is
+// flagged. Tracks without audio features are added to the end.
+func SequenceMix(trax Tracks, af AudioFeatures, opts MixOptions) []MixTrack {
+	features := af.ByID()
+	ret := []MixTrack{}
+	remaining := []MixTrack{}
+	unknown := []MixTrack{}
+
+	for _, t := range trax {
+		f, ok := features[t.ID]
+		if !ok {
+			unknown = append(unknown, MixTrack{Track: t, Transition: MixTransitionUnknown})
+			continue
+		}
+
+		mt := MixTrack{Track: t, Tempo: f.Tempo}
+		if ck, ok := f.Camelot(); ok {
+			mt.Camelot = ck.String()
+			mt.OpenKey = ck.OpenKey()
+		}
+		remaining = append(remaining, mt)
+	}
+
+	if len(remaining) < 1 {
+		return append(ret, unknown...)
+	}
+
+	wheel := map[string]CamelotKey{}
+	for _, i := range af {
+		if ck, ok := i.Camelot(); ok {
+			wheel[i.ID] = ck
+		}
+	}
+
+	start := 0
+	for i, mt := range remaining {
+		if mt.Tempo < remaining[start].Tempo {
+			start = i
+		}
+	}
+
+	current := remaining[start]
+	current.Transition = MixTransitionStart
+	remaining = append(remaining[:start], remaining[start+1:]...)
+	ret = append(ret, current)
+
+	for len(remaining) > 0 {
+		best := -1
+		bestCost := math.MaxFloat64
+		bestTransition := MixTransitionClash
+		for i, mt := range remaining {
+			tempo := TempoDifference(current.Tempo, mt.Tempo, opts.HalfDouble)
+
+			// tracks without a detected key are treated as far away on the
+			// wheel so they're only used when nothing else fits
+			distance := 6
+			from, okFrom := wheel[current.Track.ID]
+			to, okTo := wheel[mt.Track.ID]
+			if okFrom && okTo {
+				distance = from.Distance(to)
+			}
+
+			transition := MixTransitionClash
+			cost := float64(distance)*opts.BPMTolerance + tempo
+			if tempo <= opts.BPMTolerance {
+				transition = MixTransitionTempo
+				if distance <= 1 {
+					transition = MixTransitionHarmonic
+				}
+			} else {
+				// anything outside of the tolerance should lose to a track
+				// that's inside it
+				cost += 100 * opts.BPMTolerance
+			}
+
+			if cost < bestCost {
+				best = i
+				bestCost = cost
+				bestTransition = transition
+			}
+		}
+
+		current = remaining[best]
+		current.Transition = bestTransition
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		ret = append(ret, current)
+	}
+
+	return append(ret, unknown...)
+}
@@ -0,0 +1,99 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCamelot(t *testing.T) {
+	cases := []struct {
+		key     int
+		mode    int
+		camelot string
+		openKey string
+	}{
+		{0, 1, "8B", "1d"},  // C major
+		{7, 1, "9B", "2d"},  // G major
+		{5, 1, "7B", "12d"}, // F major
+		{11, 1, "1B", "6d"}, // B major
+		{9, 0, "8A", "1m"},  // A minor
+		{4, 0, "9A", "2m"},  // E minor
+		{8, 0, "1A", "6m"},  // G# minor
+		{0, 0, "5A", "10m"}, // C minor
+	}
+
+	for _, c := range cases {
+		t.Run(c.camelot, func(t *testing.T) {
+			a := AudioFeature{Key: c.key, Mode: c.mode}
+			ck, ok := a.Camelot()
+			assert.True(t, ok)
+			assert.Equal(t, c.camelot, ck.String())
+			assert.Equal(t, c.openKey, ck.OpenKey())
+		})
+	}
+
+	t.Run("NoKey", func(t *testing.T) {
+		a := AudioFeature{Key: -1}
+		_, ok := a.Camelot()
+		assert.False(t, ok)
+	})
+}
+
+func TestCamelotCompatible(t *testing.T) {
+	eightA := CamelotKey{Number: 8, Letter: CamelotMinor}
+	assert.True(t, eightA.Compatible(CamelotKey{Number: 8, Letter: CamelotMajor}))
+	assert.True(t, eightA.Compatible(CamelotKey{Number: 9, Letter: CamelotMinor}))
+	assert.True(t, eightA.Compatible(CamelotKey{Number: 7, Letter: CamelotMinor}))
+	assert.False(t, eightA.Compatible(CamelotKey{Number: 9, Letter: CamelotMajor}))
+	assert.False(t, eightA.Compatible(CamelotKey{Number: 2, Letter: CamelotMinor}))
+
+	t.Run("Wraps", func(t *testing.T) {
+		twelve := CamelotKey{Number: 12, Letter: CamelotMajor}
+		assert.True(t, twelve.Compatible(CamelotKey{Number: 1, Letter: CamelotMajor}))
+	})
+}
+
+func TestTempoDifference(t *testing.T) {
+	assert.Equal(t, 10.0, TempoDifference(120, 130, false))
+	assert.Equal(t, 120.0, TempoDifference(120, 240, false))
+	assert.Equal(t, 2.0, TempoDifference(120, 242, true))
+	assert.Equal(t, 1.0, TempoDifference(120, 61, true))
+}
+
+func TestSequenceMix(t *testing.T) {
+	trax := Tracks{{ID: "fast"}, {ID: "slow"}, {ID: "clash"}, {ID: "next"}, {ID: "missing"}}
+	af := AudioFeatures{
+		{ID: "slow", Key: 0, Mode: 1, Tempo: 100},  // 8B
+		{ID: "next", Key: 7, Mode: 1, Tempo: 104},  // 9B
+		{ID: "fast", Key: 2, Mode: 1, Tempo: 108},  // 10B
+		{ID: "clash", Key: 6, Mode: 1, Tempo: 150}, // 2B
+	}
+
+	opts := MixOptions{}
+	assert.Nil(t, opts.Validate())
+
+	mix := SequenceMix(trax, af, opts)
+	assert.Equal(t, 5, len(mix))
+
+	ids := []string{}
+	for _, i := range mix {
+		ids = append(ids, i.Track.ID)
+	}
+	assert.Equal(t, []string{"slow", "next", "fast", "clash", "missing"}, ids)
+
+	assert.Equal(t, MixTransitionStart, mix[0].Transition)
+	assert.Equal(t, MixTransitionHarmonic, mix[1].Transition)
+	assert.Equal(t, "9B", mix[1].Camelot)
+	assert.Equal(t, MixTransitionClash, mix[3].Transition)
+	assert.Equal(t, MixTransitionUnknown, mix[4].Transition)
+
+	t.Run("BadOptions", func(t *testing.T) {
+		opts := MixOptions{BPMTolerance: -1}
+		assert.NotNil(t, opts.Validate())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, 0, len(SequenceMix(Tracks{}, AudioFeatures{}, MixOptions{})))
+	})
+}
@@ -15,12 +15,6 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	KeySeedArtists = "seed-artists"
-	KeySeedTracks  = "seed-tracks"
-	KeySeedGenres  = "seed-genres"
-)
-
 // TODO: cleanup
 type item struct {
 	DateSaved time.Time `json:"added_at"`
@@ -85,7 +79,7 @@ func makeRequest(ctx context.Context, req *http.Request) (*[]byte, error) {
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		if resp.StatusCode == 401 {
 			logger.WithField("event", EventNeedsRefreshToken).Info()
 			return nil, ErrTokenExpired("")
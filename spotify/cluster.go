@@ -0,0 +1,425 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	defaultClusterCount       = 5
+	maxClusterCount           = 20
+	defaultClusterIterations  = 50
+	defaultClusterGenres      = 20
+	defaultClusterGenreWeight = 0.5
+	defaultClusterExamples    = 5
+	// clusterExtremeThreshold is how far a cluster's average has to be from
+	// the library's average, on a 0-1 scale, for it to show up in the label
+	clusterExtremeThreshold = 0.15
+	clusterLabelExtremes    = 2
+	clusterLabelGenres      = 3
+)
+
+// clusterDescriptors are the words used when labeling a cluster with an
+// unusually low or high feature. Anything missing is described as
+// "low-<feature>" or "high-<feature>".
+var clusterDescriptors = map[string][2]string{
+	FeatureAcousticness:     {"electric", "acoustic"},
+	FeatureInstrumentalness: {"vocal", "instrumental"},
+	FeatureSpeechiness:      {"melodic", "wordy"},
+	FeatureLiveness:         {"studio", "live"},
+	FeatureTempo:            {"slow", "fast"},
+	FeatureLoudness:         {"quiet", "loud"},
+}
+
+// ClusterOptions controls how the library is clustered
+type ClusterOptions struct {
+	// K is the number of clusters to create
+	K int `json:"k"`
+	// Seed makes the clustering repeatable
+	Seed          int64 `json:"seed"`
+	MaxIterations int   `json:"max_iterations"`
+	// GenreLimit is how many of the most common genres are considered
+	GenreLimit int `json:"genre_limit"`
+	// GenreWeight scales the genres against the audio features
+	GenreWeight float64 `json:"genre_weight"`
+	// Examples is how many representative tracks to return per cluster
+	Examples int `json:"examples"`
+}
+
+// Cluster is a group of tracks that sound alike
+type Cluster struct {
+	ID     int             `json:"id"`
+	Label  string          `json:"label"`
+	Size   int             `json:"size"`
+	Genres sortablemap.Map `json:"genres"`
+	// Features is the average value of each feature in the cluster
+	Features map[string]float64 `json:"features"`
+	// Representative are the tracks closest to the center of the cluster
+	Representative Tracks `json:"representative"`
+	Tracks         Tracks `json:"-"`
+}
+
+// ----
+// Members
+// ----
+
+// Validate fills in the defaults for anything that isn't provided
+func (c *ClusterOptions) Validate() error {
+	if c.K < 0 || c.K > maxClusterCount {
+		return errors.New(fmt.Sprint("k must be between 0 and ", maxClusterCount, " (0 uses the default), got ", c.K))
+	}
+
+	if c.MaxIterations < 0 || c.GenreLimit < 0 || c.GenreWeight < 0 || c.Examples < 0 {
+		return errors.New("cluster options must be positive")
+	}
+
+	if c.K == 0 {
+		c.K = defaultClusterCount
+	}
+
+	if c.MaxIterations == 0 {
+		c.MaxIterations = defaultClusterIterations
+	}
+
+	if c.GenreLimit == 0 {
+		c.GenreLimit = defaultClusterGenres
+	}
+
+	if c.GenreWeight == 0 {
+		c.GenreWeight = defaultClusterGenreWeight
+	}
+
+	if c.Examples == 0 {
+		c.Examples = defaultClusterExamples
+	}
+
+	return nil
+}
+
+// ClusterTracks groups the tracks using k-means over their normalized audio
+// features and the genres of their artists. Tracks without audio features
+// are skipped. The options should be validated first.
+func ClusterTracks(trax Tracks, af AudioFeatures, genres map[string][]string, opts ClusterOptions) []Cluster {
+	features := af.ByID()
+	items := Tracks{}
+	for _, t := range trax {
+		if _, ok := features[t.ID]; ok {
+			items = append(items, t)
+		}
+	}
+
+	if len(items) < 1 {
+		return []Cluster{}
+	}
+
+	k := opts.K
+	if k > len(items) {
+		k = len(items)
+	}
+
+	normalized := normalizeFeatures(items, features)
+	topGenres := commonGenres(items, genres, opts.GenreLimit)
+
+	vectors := [][]float64{}
+	for i, t := range items {
+		v := append([]float64{}, normalized[i]...)
+		for _, g := range topGenres {
+			hot := 0.0
+			for _, tg := range genres[t.ID] {
+				if tg == g {
+					hot = opts.GenreWeight
+					break
+				}
+			}
+			v = append(v, hot)
+		}
+		vectors = append(vectors, v)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	centroids := seedCentroids(vectors, k, rng)
+	assignments := make([]int, len(vectors))
+	for iter := 0; iter < opts.MaxIterations; iter++ {
+		changed := false
+		for i, v := range vectors {
+			closest := nearestCentroid(v, centroids)
+			if iter == 0 || closest != assignments[i] {
+				changed = true
+			}
+			assignments[i] = closest
+		}
+
+		if !changed {
+			break
+		}
+
+		centroids = updateCentroids(vectors, assignments, centroids)
+	}
+
+	// the averages for the whole library are used to find what makes each
+	// cluster stand out
+	overall := make([]float64, len(ProfileFeatures))
+	for _, v := range normalized {
+		for f := range ProfileFeatures {
+			overall[f] += v[f] / float64(len(normalized))
+		}
+	}
+
+	ret := []Cluster{}
+	for c := range centroids {
+		members := []int{}
+		for i, a := range assignments {
+			if a == c {
+				members = append(members, i)
+			}
+		}
+
+		if len(members) < 1 {
+			continue
+		}
+
+		cluster := Cluster{Size: len(members), Features: map[string]float64{}, Tracks: Tracks{}}
+		counts := map[string]int{}
+		means := make([]float64, len(ProfileFeatures))
+		for _, m := range members {
+			cluster.Tracks = append(cluster.Tracks, items[m])
+			f := features[items[m].ID]
+			for fi, name := range ProfileFeatures {
+				v, _ := f.Feature(name)
+				cluster.Features[name] += v / float64(len(members))
+				means[fi] += normalized[m][fi] / float64(len(members))
+			}
+
+			for _, g := range genres[items[m].ID] {
+				counts[g]++
+			}
+		}
+
+		cluster.Genres = sortedGenreCounts(counts).Take(clusterLabelGenres)
+		cluster.Label = clusterLabel(means, overall, cluster.Genres)
+
+		sort.SliceStable(members, func(i, j int) bool {
+			return distance(vectors[members[i]], centroids[c]) < distance(vectors[members[j]], centroids[c])
+		})
+		cluster.Representative = Tracks{}
+		for i := 0; i < len(members) && i < opts.Examples; i++ {
+			cluster.Representative = append(cluster.Representative, items[members[i]])
+		}
+
+		ret = append(ret, cluster)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Size > ret[j].Size
+	})
+	for i := range ret {
+		ret[i].ID = i
+	}
+
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+// normalizeFeatures scales every profile feature to 0-1 based on the range
+// found in the tracks, so tempo and loudness don't outweigh everything else.
+func normalizeFeatures(trax Tracks, features map[string]AudioFeature) [][]float64 {
+	mins := make([]float64, len(ProfileFeatures))
+	maxes := make([]float64, len(ProfileFeatures))
+	ret := [][]float64{}
+	for i, t := range trax {
+		f := features[t.ID]
+		v := []float64{}
+		for fi, name := range ProfileFeatures {
+			val, _ := f.Feature(name)
+			if i == 0 || val < mins[fi] {
+				mins[fi] = val
+			}
+			if i == 0 || val > maxes[fi] {
+				maxes[fi] = val
+			}
+			v = append(v, val)
+		}
+		ret = append(ret, v)
+	}
+
+	for _, v := range ret {
+		for fi := range v {
+			if maxes[fi] == mins[fi] {
+				v[fi] = 0
+				continue
+			}
+			v[fi] = (v[fi] - mins[fi]) / (maxes[fi] - mins[fi])
+		}
+	}
+
+	return ret
+}
+
+// commonGenres returns up to limit of the most common genres in the tracks
+func commonGenres(trax Tracks, genres map[string][]string, limit int) []string {
+	counts := map[string]int{}
+	for _, t := range trax {
+		for _, g := range genres[t.ID] {
+			counts[g]++
+		}
+	}
+
+	ret := []string{}
+	for _, i := range sortedGenreCounts(counts).Take(limit) {
+		ret = append(ret, i.Key)
+	}
+	return ret
+}
+
+// sortedGenreCounts sorts by count, falling back to the name so the order
+// doesn't depend on map iteration.
+func sortedGenreCounts(counts map[string]int) sortablemap.Map {
+	ret := sortablemap.GetSortableMap(counts)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Value == ret[j].Value {
+			return ret[i].Key < ret[j].Key
+		}
+		return ret[i].Value > ret[j].Value
+	})
+	return ret
+}
+
+// seedCentroids picks the starting centroids using k-means++, which spreads
+// them out by favoring points far from the centroids already chosen.
+func seedCentroids(vectors [][]float64, k int, rng *rand.Rand) [][]float64 {
+	ret := [][]float64{append([]float64{}, vectors[rng.Intn(len(vectors))]...)}
+	for len(ret) < k {
+		weights := make([]float64, len(vectors))
+		total := 0.0
+		for i, v := range vectors {
+			d := distance(v, ret[nearestCentroid(v, ret)])
+			weights[i] = d * d
+			total += weights[i]
+		}
+
+		if total == 0 {
+			// every point is already a centroid
+			break
+		}
+
+		target := rng.Float64() * total
+		picked := len(vectors) - 1
+		for i, w := range weights {
+			target -= w
+			if target <= 0 && w > 0 {
+				picked = i
+				break
+			}
+		}
+		ret = append(ret, append([]float64{}, vectors[picked]...))
+	}
+
+	return ret
+}
+
+func nearestCentroid(v []float64, centroids [][]float64) int {
+	ret := 0
+	best := math.MaxFloat64
+	for i, c := range centroids {
+		if d := distance(v, c); d < best {
+			ret = i
+			best = d
+		}
+	}
+	return ret
+}
+
+// updateCentroids moves each centroid to the mean of its members. Centroids
+// without members are left where they are.
+func updateCentroids(vectors [][]float64, assignments []int, centroids [][]float64) [][]float64 {
+	sums := make([][]float64, len(centroids))
+	counts := make([]int, len(centroids))
+	for i := range sums {
+		sums[i] = make([]float64, len(centroids[i]))
+	}
+
+	for i, v := range vectors {
+		a := assignments[i]
+		counts[a]++
+		for d := range v {
+			sums[a][d] += v[d]
+		}
+	}
+
+	ret := [][]float64{}
+	for i := range centroids {
+		if counts[i] == 0 {
+			ret = append(ret, centroids[i])
+			continue
+		}
+
+		for d := range sums[i] {
+			sums[i][d] /= float64(counts[i])
+		}
+		ret = append(ret, sums[i])
+	}
+	return ret
+}
+
+func distance(a []float64, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += math.Pow(a[i]-b[i], 2)
+	}
+	return math.Sqrt(sum)
+}
+
+// clusterLabel describes the cluster using the features that stand out the
+// most from the rest of the library, followed by its dominant genre.
+func clusterLabel(means []float64, overall []float64, genres sortablemap.Map) string {
+	type extreme struct {
+		name string
+		diff float64
+	}
+
+	extremes := []extreme{}
+	for fi, name := range ProfileFeatures {
+		d := means[fi] - overall[fi]
+		if math.Abs(d) >= clusterExtremeThreshold {
+			extremes = append(extremes, extreme{name: name, diff: d})
+		}
+	}
+
+	sort.SliceStable(extremes, func(i, j int) bool {
+		return math.Abs(extremes[i].diff) > math.Abs(extremes[j].diff)
+	})
+
+	words := []string{}
+	for i := 0; i < len(extremes) && i < clusterLabelExtremes; i++ {
+		high := 0
+		if extremes[i].diff > 0 {
+			high = 1
+		}
+
+		if d, ok := clusterDescriptors[extremes[i].name]; ok {
+			words = append(words, d[high])
+			continue
+		}
+
+		words = append(words, fmt.Sprint([]string{"low", "high"}[high], "-", extremes[i].name))
+	}
+
+	if len(genres) > 0 {
+		words = append(words, genres[0].Key)
+	}
+
+	if len(words) < 1 {
+		return "mixed"
+	}
+
+	return strings.Join(words, " ")
+}
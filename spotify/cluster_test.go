@@ -0,0 +1,77 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterOptionsValidate(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		opts := ClusterOptions{}
+		assert.Nil(t, opts.Validate())
+		assert.Equal(t, defaultClusterCount, opts.K)
+		assert.Equal(t, defaultClusterIterations, opts.MaxIterations)
+		assert.Equal(t, defaultClusterExamples, opts.Examples)
+	})
+
+	t.Run("TooManyClusters", func(t *testing.T) {
+		opts := ClusterOptions{K: maxClusterCount + 1}
+		assert.NotNil(t, opts.Validate())
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		opts := ClusterOptions{GenreWeight: -1}
+		assert.NotNil(t, opts.Validate())
+	})
+}
+
+func TestClusterTracks(t *testing.T) {
+	trax := Tracks{}
+	af := AudioFeatures{}
+	genres := map[string][]string{}
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprint("loud", i)
+		trax = append(trax, Track{ID: id})
+		af = append(af, AudioFeature{ID: id, Energy: 0.9, Acousticness: 0.05, Tempo: 120})
+		genres[id] = []string{"punk"}
+
+		id = fmt.Sprint("soft", i)
+		trax = append(trax, Track{ID: id})
+		af = append(af, AudioFeature{ID: id, Energy: 0.2, Acousticness: 0.9, Tempo: 120})
+		genres[id] = []string{"folk"}
+	}
+	trax = append(trax, Track{ID: "no-features"})
+
+	opts := ClusterOptions{K: 2, Seed: 42}
+	assert.Nil(t, opts.Validate())
+
+	clusters := ClusterTracks(trax, af, genres, opts)
+	assert.Equal(t, 2, len(clusters))
+
+	labels := []string{}
+	for _, c := range clusters {
+		assert.Equal(t, 10, c.Size)
+		assert.Equal(t, 10, len(c.Tracks))
+		assert.Equal(t, defaultClusterExamples, len(c.Representative))
+		labels = append(labels, c.Label)
+	}
+	assert.ElementsMatch(t, []string{"high-energy electric punk", "low-energy acoustic folk"}, labels)
+
+	t.Run("Deterministic", func(t *testing.T) {
+		again := ClusterTracks(trax, af, genres, opts)
+		assert.Equal(t, clusters, again)
+	})
+
+	t.Run("MoreClustersThanTracks", func(t *testing.T) {
+		opts := ClusterOptions{K: 5}
+		assert.Nil(t, opts.Validate())
+		clusters := ClusterTracks(trax[:2], af, genres, opts)
+		assert.Equal(t, 2, len(clusters))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, 0, len(ClusterTracks(Tracks{}, af, genres, opts)))
+	})
+}
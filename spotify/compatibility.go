@@ -0,0 +1,490 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	CompatibilityDimensionArtists = "artists"
+	CompatibilityDimensionTracks  = "tracks"
+	CompatibilityDimensionGenres  = "genres"
+	CompatibilityDimensionAudio   = "audio"
+
+	PairingStatusPending  = "pending"
+	PairingStatusAccepted = "accepted"
+	PairingStatusRevoked  = "revoked"
+
+	// compatibilityPicksLimit is how many artists each user is told to
+	// introduce the other to
+	compatibilityPicksLimit = 5
+)
+
+var (
+	ErrPairingNotFound   = errors.New("pairing not found")
+	ErrPairingNotPending = errors.New("pairing is no longer pending")
+	ErrPairingSelf       = errors.New("users cant pair with themselves")
+	ErrNoTasteSnapshot   = errors.New("no taste snapshot for user")
+
+	// compatibilityFeatures are the audio features compared between users.
+	// They all go from 0 to 1, so none of them outweighs the others.
+	compatibilityFeatures = []string{
+		FeatureDanceability,
+		FeatureEnergy,
+		FeatureValence,
+		FeatureAcousticness,
+		FeatureInstrumentalness,
+		FeatureSpeechiness,
+		FeatureLiveness,
+	}
+)
+
+// TasteItem is an artist or track in a taste snapshot
+type TasteItem struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Genres []string `json:"genres,omitempty"`
+}
+
+// TasteSnapshot is what's stored of a user's taste so they can be compared
+// with another user without needing both of them to be logged in.
+type TasteSnapshot struct {
+	UserID string `json:"user_id"`
+	// Artists and Tracks are best first
+	Artists []TasteItem        `json:"artists"`
+	Tracks  []TasteItem        `json:"tracks"`
+	Genres  map[string]int     `json:"genres"`
+	Audio   map[string]float64 `json:"audio"`
+}
+
+// Pairing is an invite from one user to compare tastes with another. Both
+// users have consented once it's been accepted.
+type Pairing struct {
+	Code      string `db:"code" json:"code"`
+	InviterID string `db:"inviter_id" json:"inviter_id"`
+	InviteeID string `db:"invitee_id" json:"invitee_id,omitempty"`
+	Status    string `db:"status" json:"status"`
+}
+
+// CompatibilityPick is an artist one user should introduce the other to
+type CompatibilityPick struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Genres are the artist's genres the other user already listens to
+	Genres []string `json:"genres"`
+}
+
+// Compatibility compares two users' tastes from the first user's point of
+// view
+type Compatibility struct {
+	// Score goes from 0 (nothing in common) to 100 (identical tastes)
+	Score float64 `json:"score"`
+	// Dimensions are the overlap for each part of the users' tastes, from 0
+	// to 1
+	Dimensions    map[string]float64 `json:"dimensions"`
+	SharedArtists []TasteItem        `json:"shared_artists"`
+	SharedTracks  []TasteItem        `json:"shared_tracks"`
+	SharedGenres  []string           `json:"shared_genres"`
+	// IntroduceThemTo are the first user's artists the second user might
+	// like, and IntroduceYouTo is the other way around
+	IntroduceThemTo []CompatibilityPick `json:"introduce_them_to"`
+	IntroduceYouTo  []CompatibilityPick `json:"introduce_you_to"`
+}
+
+// ----
+// API
+// ----
+
+// NewTasteSnapshot builds the snapshot for a user from their top artists
+// and tracks, along with the audio features for those tracks.
+func NewTasteSnapshot(userID string, artists Artists, trax Tracks, af AudioFeatures) *TasteSnapshot {
+	ret := TasteSnapshot{
+		UserID:  userID,
+		Artists: []TasteItem{},
+		Tracks:  []TasteItem{},
+		Genres:  map[string]int{},
+		Audio:   map[string]float64{},
+	}
+
+	found := map[string]bool{}
+	for _, a := range artists {
+		if found[a.ID] {
+			continue
+		}
+		found[a.ID] = true
+
+		ret.Artists = append(ret.Artists, TasteItem{ID: a.ID, Name: a.Name, Genres: a.Genres})
+		for _, g := range a.Genres {
+			ret.Genres[g]++
+		}
+	}
+
+	for _, t := range trax {
+		if found[t.ID] {
+			continue
+		}
+		found[t.ID] = true
+
+		ret.Tracks = append(ret.Tracks, TasteItem{ID: t.ID, Name: t.Name})
+	}
+
+	if len(af.Analyzed()) > 0 {
+		mean := af.Mean()
+		for _, f := range compatibilityFeatures {
+			// the features come from a fixed list, so this can't fail
+			v, _ := mean.Feature(f)
+			ret.Audio[f] = v
+		}
+	}
+
+	return &ret
+}
+
+// SaveTasteSnapshot stores the snapshot, replacing the user's last one
+func SaveTasteSnapshot(ctx context.Context, s *TasteSnapshot) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.DB.Exec(ctx, `INSERT INTO taste_snapshots (spotify_id, snapshot) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE snapshot = VALUES(snapshot), updated_at = CURRENT_TIMESTAMP`,
+		s.UserID, string(b))
+	return err
+}
+
+// GetTasteSnapshot returns the user's last stored snapshot
+func GetTasteSnapshot(ctx context.Context, userID string) (*TasteSnapshot, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	rows := []string{}
+	err := deps.DB.Select(ctx, &rows, `SELECT snapshot FROM taste_snapshots WHERE spotify_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, fmt.Errorf("%w: %v", ErrNoTasteSnapshot, userID)
+	}
+
+	ret := TasteSnapshot{}
+	if err := json.Unmarshal([]byte(rows[0]), &ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// CreatePairing invites another user to compare tastes with the inviter.
+// The code is what the invite link is built from.
+func CreatePairing(ctx context.Context, inviterID string) (*Pairing, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	code, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := Pairing{Code: code.String(), InviterID: inviterID, Status: PairingStatusPending}
+	_, err = deps.DB.Exec(ctx, `INSERT INTO pairings (code, inviter_id, status) VALUES (?, ?, ?)`,
+		ret.Code, ret.InviterID, ret.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// GetPairing returns the pairing for the invite code
+func GetPairing(ctx context.Context, code string) (*Pairing, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	rows := []Pairing{}
+	err := deps.DB.Select(ctx, &rows,
+		`SELECT code, inviter_id, invitee_id, status FROM pairings WHERE code = ?`, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, ErrPairingNotFound
+	}
+
+	return &rows[0], nil
+}
+
+// AcceptPairing records the invitee's consent. Only pending invites from
+// someone else can be accepted.
+func AcceptPairing(ctx context.Context, p *Pairing, inviteeID string) error {
+	if p.Status != PairingStatusPending {
+		return ErrPairingNotPending
+	}
+
+	if p.InviterID == inviteeID {
+		return ErrPairingSelf
+	}
+
+	err := updatePairing(ctx, `UPDATE pairings SET invitee_id = ?, status = ?, accepted_at = CURRENT_TIMESTAMP
+		WHERE code = ? AND status = ?`, inviteeID, PairingStatusAccepted, p.Code, PairingStatusPending)
+	if err != nil {
+		return err
+	}
+
+	p.InviteeID = inviteeID
+	p.Status = PairingStatusAccepted
+	return nil
+}
+
+// RevokePairing withdraws consent for the pairing. Either user can revoke
+// it, and the report can't be viewed afterwards. The users' snapshots are
+// deleted once they aren't in any other pairings.
+func RevokePairing(ctx context.Context, p *Pairing) error {
+	if p.Status == PairingStatusRevoked {
+		return nil
+	}
+
+	err := updatePairing(ctx, `UPDATE pairings SET status = ? WHERE code = ?`, PairingStatusRevoked, p.Code)
+	if err != nil {
+		return err
+	}
+
+	p.Status = PairingStatusRevoked
+	return deleteTasteSnapshots(ctx, p)
+}
+
+// CompareTastes scores how much two users' tastes overlap. Artists and
+// tracks are compared by how many they share, genres by how often they show
+// up and audio by the root mean square distance between their average
+// features.
+func CompareTastes(you, them *TasteSnapshot) *Compatibility {
+	ret := Compatibility{
+		Dimensions:    map[string]float64{},
+		SharedArtists: sharedTasteItems(you.Artists, them.Artists),
+		SharedTracks:  sharedTasteItems(you.Tracks, them.Tracks),
+		SharedGenres:  []string{},
+	}
+
+	ret.Dimensions[CompatibilityDimensionArtists] = jaccard(you.Artists, them.Artists)
+	ret.Dimensions[CompatibilityDimensionTracks] = jaccard(you.Tracks, them.Tracks)
+
+	yourGenres := map[string]float64{}
+	theirGenres := map[string]float64{}
+	for g, v := range you.Genres {
+		yourGenres[g] = float64(v)
+		if _, ok := them.Genres[g]; ok {
+			ret.SharedGenres = append(ret.SharedGenres, g)
+		}
+	}
+	for g, v := range them.Genres {
+		theirGenres[g] = float64(v)
+	}
+	ret.Dimensions[CompatibilityDimensionGenres] = cosine(yourGenres, theirGenres)
+
+	sort.SliceStable(ret.SharedGenres, func(i, j int) bool {
+		a := you.Genres[ret.SharedGenres[i]] + them.Genres[ret.SharedGenres[i]]
+		b := you.Genres[ret.SharedGenres[j]] + them.Genres[ret.SharedGenres[j]]
+		if a == b {
+			return ret.SharedGenres[i] < ret.SharedGenres[j]
+		}
+		return a > b
+	})
+
+	// the distance between the two averages is divided by the furthest
+	// apart they could be, so every feature counts by how far apart the
+	// users actually are rather than how far both are from the middle
+	if len(you.Audio) > 0 && len(them.Audio) > 0 {
+		sum := 0.0
+		for _, f := range compatibilityFeatures {
+			d := you.Audio[f] - them.Audio[f]
+			sum += d * d
+		}
+		ret.Dimensions[CompatibilityDimensionAudio] = 1 - math.Sqrt(sum/float64(len(compatibilityFeatures)))
+	}
+
+	total := 0.0
+	for name, v := range ret.Dimensions {
+		ret.Dimensions[name] = math.Round(v*10000) / 10000
+		total += v
+	}
+	if len(ret.Dimensions) > 0 {
+		ret.Score = math.Round(total/float64(len(ret.Dimensions))*10000) / 100
+	}
+
+	ret.IntroduceThemTo = introductions(you, them)
+	ret.IntroduceYouTo = introductions(them, you)
+
+	return &ret
+}
+
+// ----
+// Members
+// ----
+
+// Includes returns whether the user is either side of the pairing
+func (p *Pairing) Includes(userID string) bool {
+	return len(userID) > 0 && (p.InviterID == userID || p.InviteeID == userID)
+}
+
+// Partner returns the other user in the pairing
+func (p *Pairing) Partner(userID string) string {
+	if p.InviterID == userID {
+		return p.InviteeID
+	}
+
+	return p.InviterID
+}
+
+// ----
+// Helpers
+// ----
+
+func updatePairing(ctx context.Context, query string, args ...interface{}) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	res, err := deps.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows < 1 {
+		return ErrPairingNotPending
+	}
+
+	return nil
+}
+
+// deleteTasteSnapshots deletes the snapshots of the pairing's users that
+// aren't in any pending or accepted pairings anymore
+func deleteTasteSnapshots(ctx context.Context, p *Pairing) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `DELETE FROM taste_snapshots WHERE spotify_id IN (?, ?) AND NOT EXISTS (
+			SELECT 1 FROM pairings p WHERE p.status IN (?, ?)
+			AND (p.inviter_id = taste_snapshots.spotify_id OR p.invitee_id = taste_snapshots.spotify_id))`,
+		p.InviterID, p.InviteeID, PairingStatusPending, PairingStatusAccepted)
+	return err
+}
+
+// sharedTasteItems returns the items in both lists, in the first list's
+// order
+func sharedTasteItems(a, b []TasteItem) []TasteItem {
+	found := map[string]bool{}
+	for _, i := range b {
+		found[i.ID] = true
+	}
+
+	ret := []TasteItem{}
+	for _, i := range a {
+		if found[i.ID] {
+			ret = append(ret, i)
+		}
+	}
+
+	return ret
+}
+
+// jaccard is the share of all the items that are in both lists
+func jaccard(a, b []TasteItem) float64 {
+	union := map[string]bool{}
+	for _, i := range a {
+		union[i.ID] = true
+	}
+	for _, i := range b {
+		union[i.ID] = true
+	}
+
+	if len(union) < 1 {
+		return 0
+	}
+
+	return float64(len(sharedTasteItems(a, b))) / float64(len(union))
+}
+
+// cosine is the cosine similarity between two sparse vectors
+func cosine(a, b map[string]float64) float64 {
+	dot := 0.0
+	magA := 0.0
+	magB := 0.0
+	for k, v := range a {
+		dot += v * b[k]
+		magA += v * v
+	}
+	for _, v := range b {
+		magB += v * v
+	}
+
+	if magA == 0 || magB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(magA) * math.Sqrt(magB))
+}
+
+// introductions picks the artists from one user that the other doesn't
+// listen to but shares the most genres with, keeping the first user's
+// ranking for ties.
+func introductions(from, to *TasteSnapshot) []CompatibilityPick {
+	known := map[string]bool{}
+	for _, a := range to.Artists {
+		known[a.ID] = true
+	}
+
+	ret := []CompatibilityPick{}
+	for _, a := range from.Artists {
+		if known[a.ID] {
+			continue
+		}
+
+		p := CompatibilityPick{ID: a.ID, Name: a.Name, Genres: []string{}}
+		for _, g := range a.Genres {
+			if _, ok := to.Genres[g]; ok {
+				p.Genres = append(p.Genres, g)
+			}
+		}
+
+		if len(p.Genres) > 0 {
+			ret = append(ret, p)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return len(ret[i].Genres) > len(ret[j].Genres)
+	})
+
+	if len(ret) > compatibilityPicksLimit {
+		ret = ret[:compatibilityPicksLimit]
+	}
+
+	return ret
+}
@@ -0,0 +1,202 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/data"
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTasteSnapshot(t *testing.T) {
+	artists := Artists{
+		{ID: "a", Name: "blink-182", Genres: []string{"pop punk", "punk"}},
+		{ID: "b", Name: "Green Day", Genres: []string{"punk"}},
+		{ID: "a", Name: "blink-182", Genres: []string{"pop punk", "punk"}},
+	}
+	trax := Tracks{{ID: "1", Name: "Dammit"}, {ID: "2", Name: "Basket Case"}, {ID: "1", Name: "Dammit"}}
+	af := AudioFeatures{{ID: "1", Energy: 0.8, Valence: 0.4}, {}, {ID: "2", Energy: 0.6, Valence: 0.6}}
+
+	s := NewTasteSnapshot("user", artists, trax, af)
+	assert.Equal(t, "user", s.UserID)
+	assert.Equal(t, 2, len(s.Artists))
+	assert.Equal(t, []TasteItem{{ID: "1", Name: "Dammit"}, {ID: "2", Name: "Basket Case"}}, s.Tracks)
+	assert.Equal(t, map[string]int{"pop punk": 1, "punk": 2}, s.Genres)
+	assert.Equal(t, len(compatibilityFeatures), len(s.Audio))
+	assert.InDelta(t, 0.7, s.Audio[FeatureEnergy], 0.0001)
+	assert.InDelta(t, 0.5, s.Audio[FeatureValence], 0.0001)
+}
+
+func TestCompareTastes(t *testing.T) {
+	you := &TasteSnapshot{
+		Artists: []TasteItem{
+			{ID: "a", Name: "blink-182", Genres: []string{"pop punk"}},
+			{ID: "b", Name: "Green Day", Genres: []string{"punk"}},
+			{ID: "c", Name: "Sum 41", Genres: []string{"pop punk", "punk"}},
+		},
+		Tracks: []TasteItem{{ID: "1"}, {ID: "2"}},
+		Genres: map[string]int{"pop punk": 2, "punk": 2},
+		Audio:  map[string]float64{FeatureEnergy: 0.9, FeatureValence: 0.7},
+	}
+	them := &TasteSnapshot{
+		Artists: []TasteItem{
+			{ID: "a", Name: "blink-182", Genres: []string{"pop punk"}},
+			{ID: "x", Name: "The Menzingers", Genres: []string{"punk", "emo"}},
+			{ID: "y", Name: "Phoebe Bridgers", Genres: []string{"indie pop"}},
+		},
+		Tracks: []TasteItem{{ID: "2"}, {ID: "3"}, {ID: "4"}},
+		Genres: map[string]int{"pop punk": 1, "punk": 1, "emo": 1, "indie pop": 1},
+		Audio:  map[string]float64{FeatureEnergy: 0.9, FeatureValence: 0.7},
+	}
+
+	c := CompareTastes(you, them)
+	assert.Equal(t, 0.2, c.Dimensions[CompatibilityDimensionArtists])
+	assert.Equal(t, 0.25, c.Dimensions[CompatibilityDimensionTracks])
+	assert.InDelta(t, 0.7071, c.Dimensions[CompatibilityDimensionGenres], 0.0001)
+	assert.Equal(t, 1.0, c.Dimensions[CompatibilityDimensionAudio])
+	assert.Equal(t, 53.93, c.Score)
+
+	assert.Equal(t, []TasteItem{{ID: "a", Name: "blink-182", Genres: []string{"pop punk"}}}, c.SharedArtists)
+	assert.Equal(t, []TasteItem{{ID: "2"}}, c.SharedTracks)
+	assert.Equal(t, []string{"pop punk", "punk"}, c.SharedGenres)
+
+	// Sum 41 has both of their genres, so they come first
+	assert.Equal(t, []CompatibilityPick{
+		{ID: "c", Name: "Sum 41", Genres: []string{"pop punk", "punk"}},
+		{ID: "b", Name: "Green Day", Genres: []string{"punk"}},
+	}, c.IntroduceThemTo)
+	assert.Equal(t, []CompatibilityPick{
+		{ID: "x", Name: "The Menzingers", Genres: []string{"punk"}},
+	}, c.IntroduceYouTo)
+
+	t.Run("OppositeAudio", func(t *testing.T) {
+		you := &TasteSnapshot{Audio: map[string]float64{}}
+		them := &TasteSnapshot{Audio: map[string]float64{}}
+		for _, f := range compatibilityFeatures {
+			you.Audio[f] = 0.9
+			them.Audio[f] = 0.1
+		}
+
+		c := CompareTastes(you, them)
+		assert.Equal(t, 0.2, c.Dimensions[CompatibilityDimensionAudio])
+		assert.Equal(t, 5.0, c.Score)
+	})
+
+	t.Run("LowForEveryone", func(t *testing.T) {
+		// speechiness, liveness and instrumentalness are low for nearly
+		// everyone, so they shouldn't make dance and folk look alike
+		dance := &TasteSnapshot{Audio: map[string]float64{
+			FeatureDanceability: 0.8, FeatureEnergy: 0.85, FeatureValence: 0.7, FeatureAcousticness: 0.05,
+			FeatureInstrumentalness: 0.05, FeatureSpeechiness: 0.05, FeatureLiveness: 0.1,
+		}}
+		folk := &TasteSnapshot{Audio: map[string]float64{
+			FeatureDanceability: 0.4, FeatureEnergy: 0.25, FeatureValence: 0.35, FeatureAcousticness: 0.85,
+			FeatureInstrumentalness: 0.05, FeatureSpeechiness: 0.04, FeatureLiveness: 0.12,
+		}}
+		moreDance := &TasteSnapshot{Audio: map[string]float64{
+			FeatureDanceability: 0.75, FeatureEnergy: 0.8, FeatureValence: 0.65, FeatureAcousticness: 0.1,
+			FeatureInstrumentalness: 0.02, FeatureSpeechiness: 0.06, FeatureLiveness: 0.15,
+		}}
+
+		apart := CompareTastes(dance, folk).Dimensions[CompatibilityDimensionAudio]
+		close := CompareTastes(dance, moreDance).Dimensions[CompatibilityDimensionAudio]
+		assert.Equal(t, 0.5719, apart)
+		assert.True(t, close > 0.9)
+		assert.True(t, close-apart > 0.3)
+	})
+}
+
+func TestTasteSnapshotStorage(t *testing.T) {
+	s := &TasteSnapshot{UserID: "user", Genres: map[string]int{"punk": 1}}
+	b, _ := json.Marshal(s)
+
+	db := &data.TestDB{Rows: map[string]interface{}{"user": []string{string(b)}}}
+	ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+	assert.Nil(t, SaveTasteSnapshot(ctx, s))
+	assert.Equal(t, []interface{}{"user", string(b)}, db.Args)
+
+	ret, err := GetTasteSnapshot(ctx, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, s, ret)
+
+	_, err = GetTasteSnapshot(ctx, "nope")
+	assert.True(t, errors.Is(err, ErrNoTasteSnapshot))
+
+	_, err = GetTasteSnapshot(context.Background(), "user")
+	assert.Equal(t, ErrMissingDeps, err.Error())
+}
+
+func TestPairing(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		db := &data.TestDB{Affected: 1}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p, err := CreatePairing(ctx, "inviter")
+		assert.Nil(t, err)
+		assert.Equal(t, 36, len(p.Code))
+		assert.Equal(t, PairingStatusPending, p.Status)
+		assert.Equal(t, []interface{}{p.Code, "inviter", PairingStatusPending}, db.Args)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		db := &data.TestDB{}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		_, err := GetPairing(ctx, "code")
+		assert.Equal(t, ErrPairingNotFound, err)
+
+		db.Rows = map[string]interface{}{"code": []Pairing{{Code: "code", InviterID: "inviter", Status: PairingStatusPending}}}
+		p, err := GetPairing(ctx, "code")
+		assert.Nil(t, err)
+		assert.Equal(t, "inviter", p.InviterID)
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		db := &data.TestDB{Affected: 1}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p := &Pairing{Code: "code", InviterID: "inviter", Status: PairingStatusPending}
+		assert.Equal(t, ErrPairingSelf, AcceptPairing(ctx, p, "inviter"))
+
+		assert.Nil(t, AcceptPairing(ctx, p, "invitee"))
+		assert.Equal(t, PairingStatusAccepted, p.Status)
+		assert.True(t, p.Includes("invitee"))
+		assert.Equal(t, "inviter", p.Partner("invitee"))
+		assert.Equal(t, "invitee", p.Partner("inviter"))
+		assert.False(t, p.Includes(""))
+
+		assert.Equal(t, ErrPairingNotPending, AcceptPairing(ctx, p, "someone"))
+	})
+
+	t.Run("AcceptRace", func(t *testing.T) {
+		// someone else accepted it between looking it up and accepting it
+		db := &data.TestDB{Affected: 0}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p := &Pairing{Code: "code", InviterID: "inviter", Status: PairingStatusPending}
+		assert.Equal(t, ErrPairingNotPending, AcceptPairing(ctx, p, "invitee"))
+		assert.Equal(t, PairingStatusPending, p.Status)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		db := &data.TestDB{Affected: 1}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p := &Pairing{Code: "code", InviterID: "inviter", InviteeID: "invitee", Status: PairingStatusAccepted}
+		assert.Nil(t, RevokePairing(ctx, p))
+		assert.Equal(t, PairingStatusRevoked, p.Status)
+
+		// both users' snapshots are deleted unless they're still paired
+		// with someone else
+		assert.Equal(t, 2, len(db.Execs))
+		assert.Equal(t, []interface{}{"inviter", "invitee", PairingStatusPending, PairingStatusAccepted}, db.Execs[1])
+
+		// it's already revoked, so the database isn't touched again
+		db.Err = errors.New("test error")
+		assert.Nil(t, RevokePairing(ctx, p))
+	})
+}
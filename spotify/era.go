@@ -0,0 +1,115 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	ReleasePrecisionYear  = "year"
+	ReleasePrecisionMonth = "month"
+	ReleasePrecisionDay   = "day"
+)
+
+// EraBreakdown describes when the music in a set of tracks was released
+type EraBreakdown struct {
+	Total      int             `json:"total"`
+	Unknown    int             `json:"unknown"`
+	Years      sortablemap.Map `json:"years"`
+	Decades    sortablemap.Map `json:"decades"`
+	MedianYear int             `json:"median_year"`
+	// MusicalAge is how many years ago the median track was released
+	MusicalAge int `json:"musical_age"`
+}
+
+// ----
+// Members
+// ----
+
+// ReleaseYear returns the year the album was released. Spotify only
+// guarantees the year is present, so the date is parsed according to its
+// precision.
+func (a *Album) ReleaseYear() (int, error) {
+	precision := a.ReleaseDatePrecision
+	if len(precision) < 1 {
+		// older payloads don't always include the precision, so guess
+		// based on the shape of the date
+		switch strings.Count(a.ReleaseDate, "-") {
+		case 0:
+			precision = ReleasePrecisionYear
+		case 1:
+			precision = ReleasePrecisionMonth
+		default:
+			precision = ReleasePrecisionDay
+		}
+	}
+
+	layout := ""
+	switch precision {
+	case ReleasePrecisionYear:
+		layout = "2006"
+	case ReleasePrecisionMonth:
+		layout = "2006-01"
+	case ReleasePrecisionDay:
+		layout = "2006-01-02"
+	default:
+		return 0, errors.New(fmt.Sprint("unknown release date precision: ", precision))
+	}
+
+	d, err := time.Parse(layout, a.ReleaseDate)
+	if err != nil {
+		// some very old releases come back as "0000", which isn't useful
+		return 0, err
+	}
+
+	if d.Year() < 1 {
+		return 0, errors.New(fmt.Sprint("invalid release date: ", a.ReleaseDate))
+	}
+
+	return d.Year(), nil
+}
+
+// GetEras buckets the tracks by the year and decade their album was
+// released. Tracks without a usable release date are counted as unknown.
+func (t *Tracks) GetEras(now time.Time) *EraBreakdown {
+	ret := EraBreakdown{Total: len(*t)}
+	years := map[string]int{}
+	decades := map[string]int{}
+	found := []int{}
+
+	for _, i := range *t {
+		y, err := i.Album.ReleaseYear()
+		if err != nil {
+			ret.Unknown++
+			continue
+		}
+
+		found = append(found, y)
+		years[strconv.Itoa(y)]++
+		decades[fmt.Sprint(y-y%10, "s")]++
+	}
+
+	ret.Years = sortablemap.GetSortableMap(years)
+	sort.Sort(sort.Reverse(ret.Years))
+	ret.Decades = sortablemap.GetSortableMap(decades)
+	sort.Sort(sort.Reverse(ret.Decades))
+
+	if len(found) > 0 {
+		sort.Ints(found)
+		mid := len(found) / 2
+		ret.MedianYear = found[mid]
+		if len(found)%2 == 0 {
+			ret.MedianYear = (found[mid-1] + found[mid]) / 2
+		}
+
+		ret.MusicalAge = now.Year() - ret.MedianYear
+	}
+
+	return &ret
+}
@@ -0,0 +1,65 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseYear(t *testing.T) {
+	cases := []struct {
+		date      string
+		precision string
+		exp       int
+		err       bool
+	}{
+		{"1999", ReleasePrecisionYear, 1999, false},
+		{"1999-06", ReleasePrecisionMonth, 1999, false},
+		{"1999-06-01", ReleasePrecisionDay, 1999, false},
+		{"2001-06-12", "", 2001, false},
+		{"2001", "", 2001, false},
+		{"0000", ReleasePrecisionYear, 0, true},
+		{"1999-06", ReleasePrecisionDay, 0, true},
+		{"1999", "century", 0, true},
+		{"", "", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprint(c.date, "_", c.precision), func(t *testing.T) {
+			a := Album{ReleaseDate: c.date, ReleaseDatePrecision: c.precision}
+			y, err := a.ReleaseYear()
+			assert.Equal(t, c.err, err != nil)
+			assert.Equal(t, c.exp, y)
+		})
+	}
+}
+
+func TestGetEras(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	trax := Tracks{
+		{Album: Album{ReleaseDate: "1997-04-01", ReleaseDatePrecision: ReleasePrecisionDay}},
+		{Album: Album{ReleaseDate: "1999-06", ReleaseDatePrecision: ReleasePrecisionMonth}},
+		{Album: Album{ReleaseDate: "1999", ReleaseDatePrecision: ReleasePrecisionYear}},
+		{Album: Album{ReleaseDate: "2011", ReleaseDatePrecision: ReleasePrecisionYear}},
+		{Album: Album{ReleaseDate: "0000", ReleaseDatePrecision: ReleasePrecisionYear}},
+	}
+
+	eras := trax.GetEras(now)
+	assert.Equal(t, 5, eras.Total)
+	assert.Equal(t, 1, eras.Unknown)
+	assert.Equal(t, 1999, eras.MedianYear)
+	assert.Equal(t, 22, eras.MusicalAge)
+
+	assert.Equal(t, "1990s", eras.Decades[0].Key)
+	assert.Equal(t, int32(3), eras.Decades[0].Value)
+	assert.Equal(t, "1999", eras.Years[0].Key)
+	assert.Equal(t, int32(2), eras.Years[0].Value)
+
+	t.Run("Empty", func(t *testing.T) {
+		eras := (&Tracks{}).GetEras(now)
+		assert.Equal(t, 0, eras.MedianYear)
+		assert.Equal(t, 0, eras.MusicalAge)
+	})
+}
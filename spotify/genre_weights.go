@@ -0,0 +1,170 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	GenreModeCount    = "count"
+	GenreModeWeighted = "weighted"
+)
+
+// GenreWeights controls how much each time range counts towards a user's
+// weighted genres
+type GenreWeights struct {
+	TimeRanges map[string]float64 `json:"time_ranges"`
+}
+
+// GenreSource is a ranked list of artists, best first, from one of the
+// user's time ranges
+type GenreSource struct {
+	TimeRange string
+	Artists   Artists
+}
+
+// WeightedGenres is the share each genre has of the user's taste
+type WeightedGenres struct {
+	Weights GenreWeights `json:"weights"`
+	// Genres and Parents are percentages that add up to 100
+	Genres  sortablemap.FloatMap `json:"genres"`
+	Parents sortablemap.FloatMap `json:"parents"`
+}
+
+// ----
+// API
+// ----
+
+// DefaultGenreWeights favours what the user has been listening to recently
+func DefaultGenreWeights() GenreWeights {
+	return GenreWeights{TimeRanges: map[string]float64{
+		TFShort.Value():  1,
+		TFMedium.Value(): 0.75,
+		TFLong.Value():   0.5,
+	}}
+}
+
+// TrackArtists returns the main artist for each of the tracks, in the same
+// order, filled in from the given artists so they have their genres.
+func TrackArtists(trax Tracks, artists Artists) Artists {
+	lookup := map[string]Artist{}
+	for _, a := range artists {
+		lookup[a.ID] = a
+	}
+
+	ret := Artists{}
+	for _, t := range trax {
+		if len(t.Artists) < 1 {
+			continue
+		}
+
+		a, ok := lookup[t.Artists[0].ID]
+		if !ok {
+			a = t.Artists[0]
+		}
+		ret = append(ret, a)
+	}
+
+	return ret
+}
+
+// WeighGenres scores each genre by the artists that have it. An artist
+// counts more the higher they rank and the more their time range is
+// weighted. Artists are only counted once per time range, at their best
+// rank, no matter how many sources they show up in.
+func WeighGenres(sources []GenreSource, w GenreWeights) *WeightedGenres {
+	best := map[string]map[string]float64{}
+	artists := map[string]Artist{}
+	for _, s := range sources {
+		if _, ok := best[s.TimeRange]; !ok {
+			best[s.TimeRange] = map[string]float64{}
+		}
+
+		for i, a := range s.Artists {
+			rank := float64(len(s.Artists)-i) / float64(len(s.Artists))
+			if rank > best[s.TimeRange][a.ID] {
+				best[s.TimeRange][a.ID] = rank
+			}
+
+			if len(artists[a.ID].Genres) < len(a.Genres) {
+				artists[a.ID] = a
+			}
+		}
+	}
+
+	scores := map[string]float32{}
+	parents := map[string]float32{}
+	for tr, ranks := range best {
+		for id, rank := range ranks {
+			score := float32(rank * w.TimeRanges[tr])
+			// an artist with a few genres under the same parent only counts
+			// toward it once
+			counted := map[string]bool{}
+			for _, g := range artists[id].Genres {
+				scores[g] += score
+				if p := ParentGenre(g); !counted[p] {
+					counted[p] = true
+					parents[p] += score
+				}
+			}
+		}
+	}
+
+	return &WeightedGenres{
+		Weights: w,
+		Genres:  percentages(scores),
+		Parents: percentages(parents),
+	}
+}
+
+// ----
+// Members
+// ----
+
+// Validate makes sure every weight is for a known time range and isn't
+// negative
+func (w GenreWeights) Validate() error {
+	total := 0.0
+	for tr, v := range w.TimeRanges {
+		if GetTimeFrame(tr).Value() != tr {
+			return errors.New(fmt.Sprint("unknown time range: ", tr))
+		}
+
+		if v < 0 {
+			return errors.New(fmt.Sprint("weight for ", tr, " must be positive, got ", v))
+		}
+		total += v
+	}
+
+	if total == 0 {
+		return errors.New("at least one time range weight is required")
+	}
+
+	return nil
+}
+
+// ----
+// Helpers
+// ----
+
+// percentages sorts the scores best first, as percentages of the total
+// rounded to two places
+func percentages(scores map[string]float32) sortablemap.FloatMap {
+	ret := sortablemap.GetSortableFloatMap(scores).Percentages()
+	for i := range ret {
+		ret[i].Value = float32(math.Round(float64(ret[i].Value)*100) / 100)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Value == ret[j].Value {
+			return ret[i].Key < ret[j].Key
+		}
+		return ret[i].Value > ret[j].Value
+	})
+
+	return ret
+}
@@ -0,0 +1,82 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackArtists(t *testing.T) {
+	trax := Tracks{
+		{ID: "1", Artists: []Artist{{ID: "a"}, {ID: "b"}}},
+		{ID: "2"},
+		{ID: "3", Artists: []Artist{{ID: "c", Name: "C"}}},
+	}
+
+	ret := TrackArtists(trax, Artists{{ID: "a", Genres: []string{"punk"}}})
+	assert.Equal(t, Artists{{ID: "a", Genres: []string{"punk"}}, {ID: "c", Name: "C"}}, ret)
+}
+
+func TestWeighGenres(t *testing.T) {
+	w := GenreWeights{TimeRanges: map[string]float64{"short_term": 1, "long_term": 0.5}}
+	sources := []GenreSource{
+		{TimeRange: "short_term", Artists: Artists{
+			{ID: "a", Genres: []string{"pop punk"}},
+			{ID: "b", Genres: []string{"dance pop"}},
+		}},
+		// a shows up again from the top tracks, but is only counted once
+		{TimeRange: "short_term", Artists: Artists{
+			{ID: "b", Genres: []string{"dance pop"}},
+			{ID: "a", Genres: []string{"pop punk"}},
+		}},
+		{TimeRange: "long_term", Artists: Artists{
+			{ID: "c", Genres: []string{"emo"}},
+		}},
+	}
+
+	ret := WeighGenres(sources, w)
+	// a: 1, b: 1 (best rank from the tracks), c: 0.5 * 1
+	assert.Equal(t, sortablemap.FloatMap{
+		{Key: "dance pop", Value: 40},
+		{Key: "pop punk", Value: 40},
+		{Key: "emo", Value: 20},
+	}, ret.Genres)
+	assert.Equal(t, sortablemap.FloatMap{
+		{Key: "punk", Value: 60},
+		{Key: "pop", Value: 40},
+	}, ret.Parents)
+
+	t.Run("RankMatters", func(t *testing.T) {
+		ret := WeighGenres(sources[:1], w)
+		assert.Equal(t, sortablemap.FloatMap{
+			{Key: "pop punk", Value: 66.67},
+			{Key: "dance pop", Value: 33.33},
+		}, ret.Genres)
+	})
+
+	t.Run("SameParent", func(t *testing.T) {
+		ret := WeighGenres([]GenreSource{{TimeRange: "short_term", Artists: Artists{
+			{ID: "a", Genres: []string{"pop punk", "skate punk"}},
+			{ID: "b", Genres: []string{"dance pop"}},
+		}}}, w)
+
+		// a has two punk genres, but only counts toward punk once
+		assert.Equal(t, sortablemap.FloatMap{
+			{Key: "punk", Value: 66.67},
+			{Key: "pop", Value: 33.33},
+		}, ret.Parents)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		ret := WeighGenres(nil, w)
+		assert.Equal(t, 0, len(ret.Genres))
+	})
+}
+
+func TestGenreWeightsValidate(t *testing.T) {
+	assert.Nil(t, DefaultGenreWeights().Validate())
+	assert.NotNil(t, GenreWeights{TimeRanges: map[string]float64{"forever": 1}}.Validate())
+	assert.NotNil(t, GenreWeights{TimeRanges: map[string]float64{"short_term": -1}}.Validate())
+	assert.NotNil(t, GenreWeights{TimeRanges: map[string]float64{"short_term": 0}}.Validate())
+}
@@ -0,0 +1,435 @@
+package spotify
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GraphFormatJSON    = "json"
+	GraphFormatDOT     = "dot"
+	GraphFormatGraphML = "graphml"
+
+	DefaultGraphDepth     = 2
+	MaxGraphDepth         = 3
+	DefaultGraphNodeLimit = 100
+	MaxGraphNodeLimit     = 200
+	// DefaultGraphCacheTTL is how long related artists are kept before
+	// they're fetched again
+	DefaultGraphCacheTTL = 24 * time.Hour
+	// MaxGraphCacheEntries is the most artists a builder keeps related
+	// artists for. The oldest are dropped first.
+	MaxGraphCacheEntries = 10000
+
+	// graphFetchConcurrency is how many related artist requests are made at
+	// once
+	graphFetchConcurrency = 5
+
+	graphmlNamespace = "http://graphml.graphdrawing.org/xmlns"
+)
+
+// RelatedArtistsFunc returns the artists related to the given artist
+type RelatedArtistsFunc func(ctx context.Context, id string) (*Artists, error)
+
+// GraphOptions controls how far a graph is built out from its seeds
+type GraphOptions struct {
+	// Depth is how many hops to take from the seeds
+	Depth int `json:"depth"`
+	// NodeLimit is the most artists the graph can hold
+	NodeLimit int `json:"node_limit"`
+}
+
+// GraphNode is an artist in the graph
+type GraphNode struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	URI        string   `json:"uri"`
+	Genres     []string `json:"genres"`
+	Popularity int32    `json:"popularity"`
+	Followers  int64    `json:"followers"`
+	Image      *Image   `json:"image"`
+	Seed       bool     `json:"seed"`
+	// Depth is the fewest hops from one of the seeds
+	Depth int `json:"depth"`
+	// InDegree is how many artists in the graph list this one as related
+	InDegree int `json:"in_degree"`
+	// Score favours artists close to the seeds that a lot of the graph is
+	// related to, from 0 to 1
+	Score float64 `json:"score"`
+}
+
+// GraphEdge is a related artist link
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ArtistGraph is the related artist graph around a set of seed artists
+type ArtistGraph struct {
+	Seeds []string    `json:"seeds"`
+	Depth int         `json:"depth"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphBuilder builds related artist graphs. Related artists are the same
+// for every user, so a builder can be shared and its cache reused.
+type GraphBuilder struct {
+	Related RelatedArtistsFunc
+	TTL     time.Duration
+
+	lock  sync.Mutex
+	cache map[string]graphCacheEntry
+	now   func() time.Time
+}
+
+type graphCacheEntry struct {
+	artists Artists
+	fetched time.Time
+}
+
+// ----
+// API
+// ----
+
+// NewGraphBuilder returns a builder that fetches related artists from
+// spotify
+func NewGraphBuilder() *GraphBuilder {
+	return &GraphBuilder{
+		Related: func(ctx context.Context, id string) (*Artists, error) {
+			a := Artist{ID: id}
+			return a.GetRelatedArtists(ctx)
+		},
+		TTL: DefaultGraphCacheTTL,
+	}
+}
+
+// ----
+// Members
+// ----
+
+// Validate makes sure the options are within range, filling in the
+// defaults for anything that wasn't provided.
+func (o *GraphOptions) Validate() error {
+	if o.Depth == 0 {
+		o.Depth = DefaultGraphDepth
+	}
+
+	if o.Depth < 1 || o.Depth > MaxGraphDepth {
+		return errors.New(fmt.Sprint("depth must be between 1 and ", MaxGraphDepth, ", got ", o.Depth))
+	}
+
+	if o.NodeLimit == 0 {
+		o.NodeLimit = DefaultGraphNodeLimit
+	}
+
+	if o.NodeLimit < 1 || o.NodeLimit > MaxGraphNodeLimit {
+		return errors.New(fmt.Sprint("node limit must be between 1 and ", MaxGraphNodeLimit, ", got ", o.NodeLimit))
+	}
+
+	return nil
+}
+
+// Build walks the related artists breadth first from the seeds. Once the
+// graph is full, edges are still added between the artists already in it.
+func (g *GraphBuilder) Build(ctx context.Context, seeds Artists, opts GraphOptions) (*ArtistGraph, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(seeds) < 1 {
+		return nil, errors.New("at least one seed artist is required")
+	}
+
+	ret := ArtistGraph{Seeds: []string{}, Depth: opts.Depth, Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	index := map[string]int{}
+	add := func(a Artist, depth int) {
+		index[a.ID] = len(ret.Nodes)
+		ret.Nodes = append(ret.Nodes, graphNode(a, depth))
+	}
+
+	queue := []string{}
+	for _, a := range seeds {
+		if _, ok := index[a.ID]; ok {
+			continue
+		}
+
+		ret.Seeds = append(ret.Seeds, a.ID)
+		add(a, 0)
+		ret.Nodes[index[a.ID]].Seed = true
+		queue = append(queue, a.ID)
+	}
+
+	// each level is fetched together, then added in the order it was
+	// found so the graph is the same as walking it one artist at a time
+	edges := map[GraphEdge]bool{}
+	level := queue
+	for depth := 0; depth < opts.Depth && len(level) > 0; depth++ {
+		related, err := g.relatedAll(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+
+		next := []string{}
+		for i, id := range level {
+			for _, r := range related[i] {
+				if _, ok := index[r.ID]; !ok {
+					if len(ret.Nodes) >= opts.NodeLimit {
+						continue
+					}
+
+					add(r, depth+1)
+					next = append(next, r.ID)
+				}
+
+				e := GraphEdge{From: id, To: r.ID}
+				if edges[e] || r.ID == id {
+					continue
+				}
+
+				edges[e] = true
+				ret.Edges = append(ret.Edges, e)
+				ret.Nodes[index[r.ID]].InDegree++
+			}
+		}
+		level = next
+	}
+
+	ret.score()
+	return &ret, nil
+}
+
+// DOT renders the graph in the graphviz dot language
+func (a *ArtistGraph) DOT() string {
+	b := strings.Builder{}
+	b.WriteString("digraph taste_map {\n")
+	for _, n := range a.Nodes {
+		b.WriteString(fmt.Sprintf("  %s [label=%s, depth=%d, in_degree=%d, score=%s];\n",
+			strconv.Quote(n.ID), strconv.Quote(n.Name), n.Depth, n.InDegree, strconv.FormatFloat(n.Score, 'f', 4, 64)))
+	}
+
+	for _, e := range a.Edges {
+		b.WriteString(fmt.Sprintf("  %s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To)))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// GraphML renders the graph as graphml, for tools like gephi
+func (a *ArtistGraph) GraphML() (string, error) {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+
+	type node struct {
+		ID   string `xml:"id,attr"`
+		Data []data `xml:"data"`
+	}
+
+	type edge struct {
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+	}
+
+	type key struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+
+	type graph struct {
+		ID          string `xml:"id,attr"`
+		EdgeDefault string `xml:"edgedefault,attr"`
+		Nodes       []node `xml:"node"`
+		Edges       []edge `xml:"edge"`
+	}
+
+	type graphml struct {
+		XMLName xml.Name `xml:"graphml"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Keys    []key    `xml:"key"`
+		Graph   graph    `xml:"graph"`
+	}
+
+	doc := graphml{
+		XMLNS: graphmlNamespace,
+		Keys: []key{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "genres", For: "node", Name: "genres", Type: "string"},
+			{ID: "popularity", For: "node", Name: "popularity", Type: "int"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "in_degree", For: "node", Name: "in_degree", Type: "int"},
+			{ID: "score", For: "node", Name: "score", Type: "double"},
+			{ID: "seed", For: "node", Name: "seed", Type: "boolean"},
+		},
+		Graph: graph{ID: "taste_map", EdgeDefault: "directed"},
+	}
+
+	for _, n := range a.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node{ID: n.ID, Data: []data{
+			{Key: "name", Value: n.Name},
+			{Key: "genres", Value: strings.Join(n.Genres, ", ")},
+			{Key: "popularity", Value: fmt.Sprint(n.Popularity)},
+			{Key: "depth", Value: fmt.Sprint(n.Depth)},
+			{Key: "in_degree", Value: fmt.Sprint(n.InDegree)},
+			{Key: "score", Value: strconv.FormatFloat(n.Score, 'f', 4, 64)},
+			{Key: "seed", Value: fmt.Sprint(n.Seed)},
+		}})
+	}
+
+	for _, e := range a.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, edge{Source: e.From, Target: e.To})
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(b) + "\n", nil
+}
+
+// ----
+// Helpers
+// ----
+
+// relatedAll returns the related artists for each of the artists, fetching
+// a few at a time
+func (g *GraphBuilder) relatedAll(ctx context.Context, ids []string) ([]Artists, error) {
+	ret := make([]Artists, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, graphFetchConcurrency)
+	wg := sync.WaitGroup{}
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ret[i], errs[i] = g.related(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// related returns the related artists for the artist, from the cache if
+// they were fetched recently enough
+func (g *GraphBuilder) related(ctx context.Context, id string) (Artists, error) {
+	now := time.Now
+	if g.now != nil {
+		now = g.now
+	}
+
+	g.lock.Lock()
+	entry, ok := g.cache[id]
+	if ok && now().Sub(entry.fetched) >= g.TTL {
+		delete(g.cache, id)
+		ok = false
+	}
+	g.lock.Unlock()
+	if ok {
+		return entry.artists, nil
+	}
+
+	res, err := g.Related(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.cache == nil {
+		g.cache = map[string]graphCacheEntry{}
+	}
+	g.cache[id] = graphCacheEntry{artists: *res, fetched: now()}
+	if len(g.cache) > MaxGraphCacheEntries {
+		g.evict(now())
+	}
+
+	return *res, nil
+}
+
+// evict drops the expired entries, then the oldest until the cache is back
+// under its limit. The lock must be held.
+func (g *GraphBuilder) evict(now time.Time) {
+	for id, e := range g.cache {
+		if now.Sub(e.fetched) >= g.TTL {
+			delete(g.cache, id)
+		}
+	}
+
+	for len(g.cache) > MaxGraphCacheEntries {
+		oldest := ""
+		for id, e := range g.cache {
+			if len(oldest) < 1 || e.fetched.Before(g.cache[oldest].fetched) {
+				oldest = id
+			}
+		}
+		delete(g.cache, oldest)
+	}
+}
+
+// score weighs how close each artist is to the seeds evenly with how many
+// artists in the graph they're related to, then sorts the nodes best first.
+func (a *ArtistGraph) score() {
+	maxInDegree := 0
+	for _, n := range a.Nodes {
+		if n.InDegree > maxInDegree {
+			maxInDegree = n.InDegree
+		}
+	}
+
+	for i := range a.Nodes {
+		closeness := 1 / float64(a.Nodes[i].Depth+1)
+		connected := 0.0
+		if maxInDegree > 0 {
+			connected = float64(a.Nodes[i].InDegree) / float64(maxInDegree)
+		}
+		a.Nodes[i].Score = (closeness + connected) / 2
+	}
+
+	sort.SliceStable(a.Nodes, func(i, j int) bool {
+		return a.Nodes[i].Score > a.Nodes[j].Score
+	})
+}
+
+func graphNode(a Artist, depth int) GraphNode {
+	ret := GraphNode{
+		ID:         a.ID,
+		Name:       a.Name,
+		URI:        a.URI,
+		Genres:     a.Genres,
+		Popularity: a.Popularity,
+		Followers:  a.Followers.Total,
+		Depth:      depth,
+	}
+
+	if ret.Genres == nil {
+		ret.Genres = []string{}
+	}
+
+	if len(a.Images) > 0 {
+		ret.Image = &a.Images[0]
+	}
+
+	return ret
+}
@@ -0,0 +1,153 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphOptionsValidate(t *testing.T) {
+	o := GraphOptions{}
+	assert.Nil(t, o.Validate())
+	assert.Equal(t, DefaultGraphDepth, o.Depth)
+	assert.Equal(t, DefaultGraphNodeLimit, o.NodeLimit)
+
+	assert.NotNil(t, (&GraphOptions{Depth: MaxGraphDepth + 1}).Validate())
+	assert.NotNil(t, (&GraphOptions{Depth: -1}).Validate())
+	assert.NotNil(t, (&GraphOptions{NodeLimit: MaxGraphNodeLimit + 1}).Validate())
+}
+
+func TestGraphBuilderBuild(t *testing.T) {
+	related := map[string]Artists{
+		"a": {{ID: "b", Name: "B"}, {ID: "c", Name: "C"}},
+		"b": {{ID: "a", Name: "A"}, {ID: "c", Name: "C"}, {ID: "d", Name: "D"}},
+		"c": {{ID: "b", Name: "B"}, {ID: "e", Name: "E"}},
+	}
+
+	calls := map[string]int{}
+	lock := sync.Mutex{}
+	g := &GraphBuilder{
+		TTL: time.Hour,
+		Related: func(ctx context.Context, id string) (*Artists, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			calls[id]++
+			ret := related[id]
+			return &ret, nil
+		},
+	}
+
+	seed := Artists{{ID: "a", Name: "A", Images: []Image{{URL: "http://image"}}}}
+	graph, err := g.Build(context.Background(), seed, GraphOptions{Depth: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, graph.Seeds)
+	assert.Equal(t, 5, len(graph.Nodes))
+
+	// d and e are two hops out so they aren't expanded
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, calls)
+	assert.Equal(t, 7, len(graph.Edges))
+
+	nodes := map[string]GraphNode{}
+	for _, n := range graph.Nodes {
+		nodes[n.ID] = n
+	}
+	assert.True(t, nodes["a"].Seed)
+	assert.Equal(t, "http://image", nodes["a"].Image.URL)
+	assert.Equal(t, 0, nodes["a"].Depth)
+	assert.Equal(t, 1, nodes["b"].Depth)
+	assert.Equal(t, 2, nodes["d"].Depth)
+	assert.Equal(t, 2, nodes["b"].InDegree)
+	assert.Equal(t, 2, nodes["c"].InDegree)
+
+	// the seed is closest, b and c are the most connected
+	assert.Equal(t, "a", graph.Nodes[0].ID)
+	assert.Equal(t, 0.75, nodes["b"].Score)
+	assert.Equal(t, 0.75, graph.Nodes[0].Score)
+
+	t.Run("Cached", func(t *testing.T) {
+		_, err := g.Build(context.Background(), seed, GraphOptions{Depth: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, calls)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		g.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		defer func() { g.now = nil }()
+
+		_, err := g.Build(context.Background(), seed, GraphOptions{Depth: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, calls["a"])
+	})
+
+	t.Run("Evicted", func(t *testing.T) {
+		g := &GraphBuilder{TTL: time.Hour, Related: g.Related}
+		g.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+		_, err := g.related(context.Background(), "a")
+		assert.Nil(t, err)
+		g.now = nil
+		_, err = g.related(context.Background(), "b")
+		assert.Nil(t, err)
+
+		// a has expired, so it's dropped instead of the newer b
+		g.lock.Lock()
+		g.evict(time.Now())
+		g.lock.Unlock()
+		_, ok := g.cache["a"]
+		assert.False(t, ok)
+		assert.Equal(t, 1, len(g.cache))
+	})
+
+	t.Run("NodeLimit", func(t *testing.T) {
+		graph, err := g.Build(context.Background(), seed, GraphOptions{Depth: 2, NodeLimit: 3})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(graph.Nodes))
+		for _, e := range graph.Edges {
+			assert.NotEqual(t, "d", e.To)
+			assert.NotEqual(t, "e", e.To)
+		}
+	})
+
+	t.Run("NoSeeds", func(t *testing.T) {
+		_, err := g.Build(context.Background(), Artists{}, GraphOptions{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("RelatedError", func(t *testing.T) {
+		g := &GraphBuilder{Related: func(ctx context.Context, id string) (*Artists, error) {
+			return nil, errors.New("test error")
+		}}
+		_, err := g.Build(context.Background(), seed, GraphOptions{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestArtistGraphExport(t *testing.T) {
+	graph := ArtistGraph{
+		Nodes: []GraphNode{
+			{ID: "a", Name: `The "A" Team`, Seed: true, Score: 1},
+			{ID: "b", Name: "B & Co", Depth: 1, InDegree: 1, Score: 0.5},
+		},
+		Edges: []GraphEdge{{From: "a", To: "b"}},
+	}
+
+	t.Run("DOT", func(t *testing.T) {
+		dot := graph.DOT()
+		assert.True(t, strings.HasPrefix(dot, "digraph taste_map {\n"))
+		assert.Contains(t, dot, `"a" [label="The \"A\" Team", depth=0, in_degree=0, score=1.0000];`)
+		assert.Contains(t, dot, `"a" -> "b";`)
+	})
+
+	t.Run("GraphML", func(t *testing.T) {
+		ml, err := graph.GraphML()
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(ml, `<?xml version="1.0" encoding="UTF-8"?>`))
+		assert.Contains(t, ml, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+		assert.Contains(t, ml, `<data key="name">B &amp; Co</data>`)
+		assert.Contains(t, ml, `<edge source="a" target="b"></edge>`)
+	})
+}
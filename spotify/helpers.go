@@ -59,3 +59,15 @@ func GetTimeFrame(str string) TimeFrame {
 		return TFShort
 	}
 }
+
+// chunkRange returns the bounds of the page beginning at start for a
+// collection of the given length, making sure the final page doesn't run
+// past the end of the collection.
+func chunkRange(start int, length int, limit int) (int, int) {
+	ending := start + limit
+	if ending > length {
+		ending = length
+	}
+
+	return start, ending
+}
@@ -51,3 +51,17 @@ func TestTimeFrames(t *testing.T) {
 		})
 	})
 }
+
+func TestChunkRange(t *testing.T) {
+	t.Run("FullPage", func(t *testing.T) {
+		beginning, ending := chunkRange(0, 250, 100)
+		assert.Equal(t, 0, beginning)
+		assert.Equal(t, 100, ending)
+	})
+
+	t.Run("PartialFinalPage", func(t *testing.T) {
+		beginning, ending := chunkRange(200, 250, 100)
+		assert.Equal(t, 200, beginning)
+		assert.Equal(t, 250, ending)
+	})
+}
@@ -0,0 +1,161 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	// MoodHappy is for positive, energetic tracks
+	MoodHappy = "happy"
+	// MoodCalm is for positive, low energy tracks
+	MoodCalm = "calm"
+	// MoodSad is for negative, low energy tracks
+	MoodSad = "sad"
+	// MoodAngry is for negative, energetic tracks
+	MoodAngry = "angry"
+)
+
+var (
+	// Moods is every mood quadrant a track can be classified into
+	Moods = []string{MoodHappy, MoodCalm, MoodSad, MoodAngry}
+
+	// DefaultMoodThresholds splits the quadrants down the middle
+	DefaultMoodThresholds = MoodThresholds{Valence: 0.5, Energy: 0.5}
+)
+
+// MoodThresholds are the points at which a track's valence and energy are
+// considered high. Values at or above the threshold are high.
+type MoodThresholds struct {
+	Valence float32 `json:"valence"`
+	Energy  float32 `json:"energy"`
+}
+
+// MoodDistribution describes how a set of tracks falls into each mood
+type MoodDistribution struct {
+	Total       int                `json:"total"`
+	Counts      sortablemap.Map    `json:"counts"`
+	Percentages map[string]float64 `json:"percentages"`
+	Examples    map[string]Tracks  `json:"examples"`
+}
+
+// ----
+// Members
+// ----
+
+// Validate makes sure both thresholds are within spotify's 0-1 range
+func (m MoodThresholds) Validate() error {
+	if m.Valence < 0 || m.Valence > 1 {
+		return errors.New(fmt.Sprint("valence threshold must be between 0 and 1, got ", m.Valence))
+	}
+
+	if m.Energy < 0 || m.Energy > 1 {
+		return errors.New(fmt.Sprint("energy threshold must be between 0 and 1, got ", m.Energy))
+	}
+
+	return nil
+}
+
+// Classify returns the mood quadrant for the given audio features
+func (m MoodThresholds) Classify(a AudioFeature) string {
+	positive := a.Valence >= m.Valence
+	energetic := a.Energy >= m.Energy
+
+	switch {
+	case positive && energetic:
+		return MoodHappy
+	case positive:
+		return MoodCalm
+	case energetic:
+		return MoodAngry
+	}
+
+	return MoodSad
+}
+
+// Shift returns the change, in percentage points, for each mood between an
+// earlier distribution and this one.
+func (d *MoodDistribution) Shift(earlier *MoodDistribution) map[string]float64 {
+	ret := map[string]float64{}
+	for _, m := range Moods {
+		ret[m] = d.Percentages[m] - earlier.Percentages[m]
+	}
+
+	return ret
+}
+
+// ClassifyMoods sorts the tracks into mood quadrants. Tracks without audio
+// features are skipped, and up to examples tracks are kept for each mood in
+// the order they were given.
+func ClassifyMoods(trax Tracks, af AudioFeatures, m MoodThresholds, examples int) *MoodDistribution {
+	features := af.ByID()
+	counts := map[string]int{}
+	ret := MoodDistribution{
+		Percentages: map[string]float64{},
+		Examples:    map[string]Tracks{},
+	}
+
+	for _, mood := range Moods {
+		counts[mood] = 0
+		ret.Examples[mood] = Tracks{}
+	}
+
+	for _, t := range trax {
+		f, ok := features[t.ID]
+		if !ok {
+			continue
+		}
+
+		mood := m.Classify(f)
+		counts[mood]++
+		ret.Total++
+		if len(ret.Examples[mood]) < examples {
+			ret.Examples[mood] = append(ret.Examples[mood], t)
+		}
+	}
+
+	for mood, count := range counts {
+		if ret.Total > 0 {
+			ret.Percentages[mood] = float64(count) / float64(ret.Total) * 100
+		} else {
+			ret.Percentages[mood] = 0
+		}
+	}
+
+	ret.Counts = sortablemap.GetSortableMap(counts)
+	sort.Sort(sort.Reverse(ret.Counts))
+
+	return &ret
+}
+
+// FilterByMood returns the tracks that fall into the given mood
+func FilterByMood(trax Tracks, af AudioFeatures, m MoodThresholds, mood string) Tracks {
+	features := af.ByID()
+	ret := Tracks{}
+	for _, t := range trax {
+		f, ok := features[t.ID]
+		if !ok {
+			continue
+		}
+
+		if m.Classify(f) == mood {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret
+}
+
+// IsMood returns whether the given string is a supported mood
+func IsMood(mood string) bool {
+	for _, m := range Moods {
+		if m == mood {
+			return true
+		}
+	}
+
+	return false
+}
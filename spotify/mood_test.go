@@ -0,0 +1,85 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoodThresholds(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		assert.Nil(t, DefaultMoodThresholds.Validate())
+		assert.NotNil(t, MoodThresholds{Valence: 1.1, Energy: 0.5}.Validate())
+		assert.NotNil(t, MoodThresholds{Valence: 0.5, Energy: -0.1}.Validate())
+	})
+
+	t.Run("Classify", func(t *testing.T) {
+		cases := map[string]AudioFeature{
+			MoodHappy: {Valence: 0.9, Energy: 0.9},
+			MoodCalm:  {Valence: 0.9, Energy: 0.1},
+			MoodSad:   {Valence: 0.1, Energy: 0.1},
+			MoodAngry: {Valence: 0.1, Energy: 0.9},
+		}
+
+		for exp, af := range cases {
+			assert.Equal(t, exp, DefaultMoodThresholds.Classify(af))
+		}
+	})
+
+	t.Run("ThresholdIsInclusive", func(t *testing.T) {
+		assert.Equal(t, MoodHappy, DefaultMoodThresholds.Classify(AudioFeature{Valence: 0.5, Energy: 0.5}))
+	})
+
+	t.Run("CustomThresholds", func(t *testing.T) {
+		m := MoodThresholds{Valence: 0.8, Energy: 0.2}
+		assert.Equal(t, MoodAngry, m.Classify(AudioFeature{Valence: 0.7, Energy: 0.3}))
+	})
+}
+
+func TestClassifyMoods(t *testing.T) {
+	trax := Tracks{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}, {ID: "5"}}
+	af := AudioFeatures{
+		{ID: "1", Valence: 0.9, Energy: 0.9},
+		{ID: "2", Valence: 0.8, Energy: 0.8},
+		{ID: "3", Valence: 0.7, Energy: 0.7},
+		{ID: "4", Valence: 0.1, Energy: 0.1},
+	}
+
+	d := ClassifyMoods(trax, af, DefaultMoodThresholds, 2)
+	assert.Equal(t, 4, d.Total)
+	assert.Equal(t, MoodHappy, d.Counts[0].Key)
+	assert.Equal(t, int32(3), d.Counts[0].Value)
+	assert.Equal(t, 75.0, d.Percentages[MoodHappy])
+	assert.Equal(t, 25.0, d.Percentages[MoodSad])
+	assert.Equal(t, 0.0, d.Percentages[MoodCalm])
+	happy := d.Examples[MoodHappy]
+	assert.Equal(t, []string{"1", "2"}, happy.IDs())
+	assert.Equal(t, 0, len(d.Examples[MoodAngry]))
+
+	t.Run("Shift", func(t *testing.T) {
+		earlier := ClassifyMoods(trax, af[3:], DefaultMoodThresholds, 2)
+		shift := d.Shift(earlier)
+		assert.Equal(t, 75.0, shift[MoodHappy])
+		assert.Equal(t, -75.0, shift[MoodSad])
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		d := ClassifyMoods(Tracks{}, AudioFeatures{}, DefaultMoodThresholds, 2)
+		assert.Equal(t, 0, d.Total)
+		assert.Equal(t, 4, len(d.Counts))
+	})
+}
+
+func TestFilterByMood(t *testing.T) {
+	trax := Tracks{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	af := AudioFeatures{
+		{ID: "1", Valence: 0.1, Energy: 0.1},
+		{ID: "2", Valence: 0.9, Energy: 0.9},
+		{ID: "3", Valence: 0.2, Energy: 0.3},
+	}
+
+	sad := FilterByMood(trax, af, DefaultMoodThresholds, MoodSad)
+	assert.Equal(t, []string{"1", "3"}, sad.IDs())
+	assert.True(t, IsMood(MoodSad))
+	assert.False(t, IsMood("hangry"))
+}
@@ -0,0 +1,216 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	ObscurityComponentTracks    = "track_popularity"
+	ObscurityComponentArtists   = "artist_popularity"
+	ObscurityComponentFollowers = "followers"
+	ObscurityComponentGenres    = "genres"
+
+	obscurityItemsLimit = 5
+	// obscurityMaxFollowers is the follower count, as a power of ten, that's
+	// considered completely mainstream (100 million)
+	obscurityMaxFollowers = 8
+)
+
+var (
+	// obscurityWeights is how much each component counts towards the score
+	obscurityWeights = map[string]float64{
+		ObscurityComponentTracks:    0.35,
+		ObscurityComponentArtists:   0.25,
+		ObscurityComponentFollowers: 0.2,
+		ObscurityComponentGenres:    0.2,
+	}
+
+	// mainstreamGenres are the genres that dominate the charts. Any genre
+	// that isn't in here is considered niche.
+	mainstreamGenres = map[string]bool{
+		"pop":                  true,
+		"dance pop":            true,
+		"post-teen pop":        true,
+		"pop rap":              true,
+		"rap":                  true,
+		"hip hop":              true,
+		"trap":                 true,
+		"rock":                 true,
+		"modern rock":          true,
+		"album rock":           true,
+		"classic rock":         true,
+		"r&b":                  true,
+		"urban contemporary":   true,
+		"country":              true,
+		"contemporary country": true,
+		"edm":                  true,
+		"electropop":           true,
+		"latin":                true,
+		"reggaeton":            true,
+	}
+)
+
+// ObscurityItem is a track or artist along with how popular it is
+type ObscurityItem struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Popularity int64  `json:"popularity"`
+	Followers  int64  `json:"followers,omitempty"`
+}
+
+// Obscurity describes how far from the mainstream a user's taste is. The
+// score goes from 0 (entirely mainstream) to 100 (entirely obscure).
+type Obscurity struct {
+	TimeRange  string             `json:"time_range"`
+	Score      float64            `json:"score"`
+	Components map[string]float64 `json:"components"`
+	// Percentile is the share of other users that are more mainstream. It's
+	// nil when there's no one to compare against.
+	Percentile      *float64        `json:"percentile"`
+	MostMainstream  []ObscurityItem `json:"most_mainstream"`
+	LeastMainstream []ObscurityItem `json:"least_mainstream"`
+}
+
+// ----
+// API
+// ----
+
+// SaveObscurity stores the user's score so other users can be compared
+// against it.
+func SaveObscurity(ctx context.Context, userID string, o *Obscurity) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	_, err := deps.DB.Exec(ctx, `INSERT INTO obscurity_snapshots (spotify_id, time_range, score) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE score = VALUES(score), updated_at = CURRENT_TIMESTAMP`,
+		userID, o.TimeRange, o.Score)
+	return err
+}
+
+// GetObscurityPercentile compares the score against every other user's
+// score for the same time range.
+func GetObscurityPercentile(ctx context.Context, userID string, o *Obscurity) (*float64, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	scores := []float64{}
+	err := deps.DB.Select(ctx, &scores,
+		`SELECT score FROM obscurity_snapshots WHERE time_range = ? AND spotify_id <> ?`,
+		o.TimeRange, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return percentileRank(scores, o.Score), nil
+}
+
+// ----
+// Members
+// ----
+
+// ScoreObscurity scores how obscure the user's top tracks and artists are,
+// based on their popularity, the artists' followers and how niche their
+// genres are.
+func ScoreObscurity(tf TimeFrame, trax Tracks, artists Artists) *Obscurity {
+	ret := Obscurity{
+		TimeRange:       tf.Value(),
+		Components:      map[string]float64{},
+		MostMainstream:  []ObscurityItem{},
+		LeastMainstream: []ObscurityItem{},
+	}
+
+	items := []ObscurityItem{}
+	if len(trax) > 0 {
+		sum := 0.0
+		for _, t := range trax {
+			sum += float64(t.Popularity)
+			items = append(items, ObscurityItem{Type: OutlierTypeTrack, ID: t.ID, Name: t.Name, Popularity: t.Popularity})
+		}
+		ret.Components[ObscurityComponentTracks] = 100 - sum/float64(len(trax))
+	}
+
+	if len(artists) > 0 {
+		popularity := 0.0
+		followers := 0.0
+		genres := 0
+		niche := 0
+		for _, a := range artists {
+			popularity += float64(a.Popularity)
+			reach := math.Log10(float64(a.Followers.Total)+1) / obscurityMaxFollowers
+			followers += math.Min(1, reach) * 100
+			for _, g := range a.Genres {
+				genres++
+				if !mainstreamGenres[strings.ToLower(g)] {
+					niche++
+				}
+			}
+
+			items = append(items, ObscurityItem{
+				Type:       OutlierTypeArtist,
+				ID:         a.ID,
+				Name:       a.Name,
+				Popularity: int64(a.Popularity),
+				Followers:  a.Followers.Total,
+			})
+		}
+
+		ret.Components[ObscurityComponentArtists] = 100 - popularity/float64(len(artists))
+		ret.Components[ObscurityComponentFollowers] = 100 - followers/float64(len(artists))
+		if genres > 0 {
+			ret.Components[ObscurityComponentGenres] = float64(niche) / float64(genres) * 100
+		}
+	}
+
+	// only the components we have data for count towards the score
+	total := 0.0
+	for name, v := range ret.Components {
+		ret.Score += v * obscurityWeights[name]
+		total += obscurityWeights[name]
+	}
+	if total > 0 {
+		ret.Score = math.Round(ret.Score/total*100) / 100
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Popularity > items[j].Popularity
+	})
+	for i := 0; i < len(items) && i < obscurityItemsLimit; i++ {
+		ret.MostMainstream = append(ret.MostMainstream, items[i])
+		ret.LeastMainstream = append(ret.LeastMainstream, items[len(items)-1-i])
+	}
+
+	return &ret
+}
+
+// ----
+// Helpers
+// ----
+
+// percentileRank returns the share of scores below the given score, with
+// ties counting as half. When there are no scores nil is returned.
+func percentileRank(scores []float64, score float64) *float64 {
+	if len(scores) < 1 {
+		return nil
+	}
+
+	below := 0.0
+	for _, s := range scores {
+		if s < score {
+			below++
+		} else if s == score {
+			below += 0.5
+		}
+	}
+
+	ret := below / float64(len(scores)) * 100
+	return &ret
+}
@@ -0,0 +1,88 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/data"
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreObscurity(t *testing.T) {
+	trax := Tracks{
+		{ID: "1", Name: "All The Small Things", Popularity: 80},
+		{ID: "2", Name: "Deep Cut", Popularity: 20},
+	}
+	artists := Artists{
+		{ID: "a", Name: "blink-182", Popularity: 80, Genres: []string{"pop", "pop punk"}, Followers: Followers{Total: 99999999}},
+		{ID: "b", Name: "Tiny Band", Popularity: 10, Genres: []string{"bedroom punk"}, Followers: Followers{Total: 0}},
+	}
+
+	o := ScoreObscurity(TFLong, trax, artists)
+	assert.Equal(t, "long_term", o.TimeRange)
+	assert.Equal(t, 50.0, o.Components[ObscurityComponentTracks])
+	assert.Equal(t, 55.0, o.Components[ObscurityComponentArtists])
+	assert.InDelta(t, 50.0, o.Components[ObscurityComponentFollowers], 0.01)
+	assert.InDelta(t, 66.67, o.Components[ObscurityComponentGenres], 0.01)
+	assert.Equal(t, 54.58, o.Score)
+	assert.Nil(t, o.Percentile)
+
+	assert.Equal(t, 4, len(o.MostMainstream))
+	assert.Equal(t, "1", o.MostMainstream[0].ID)
+	assert.Equal(t, "b", o.LeastMainstream[0].ID)
+
+	t.Run("TracksOnly", func(t *testing.T) {
+		o := ScoreObscurity(TFShort, trax, Artists{})
+		assert.Equal(t, 50.0, o.Score)
+		assert.Equal(t, 1, len(o.Components))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		o := ScoreObscurity(TFShort, Tracks{}, Artists{})
+		assert.Equal(t, 0.0, o.Score)
+		assert.Equal(t, 0, len(o.MostMainstream))
+	})
+}
+
+func TestGetObscurityPercentile(t *testing.T) {
+	o := &Obscurity{TimeRange: "short_term", Score: 50}
+
+	t.Run("NoDB", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{})
+		_, err := GetObscurityPercentile(ctx, "user", o)
+		assert.NotNil(t, err)
+		assert.NotNil(t, SaveObscurity(ctx, "user", o))
+	})
+
+	t.Run("HappyPath", func(t *testing.T) {
+		db := &data.TestDB{Rows: map[string]interface{}{"": []float64{10, 20, 50, 90}}}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p, err := GetObscurityPercentile(ctx, "user", o)
+		assert.Nil(t, err)
+		assert.Equal(t, 62.5, *p)
+		assert.Equal(t, []interface{}{"short_term", "user"}, db.Args)
+
+		assert.Nil(t, SaveObscurity(ctx, "user", o))
+		assert.Equal(t, []interface{}{"user", "short_term", 50.0}, db.Args)
+	})
+
+	t.Run("NoOtherUsers", func(t *testing.T) {
+		db := &data.TestDB{}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		p, err := GetObscurityPercentile(ctx, "user", o)
+		assert.Nil(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		db := &data.TestDB{Err: errors.New("test error")}
+		ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+		_, err := GetObscurityPercentile(ctx, "user", o)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,219 @@
+package spotify
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	OutlierTypeTrack  = "track"
+	OutlierTypeArtist = "artist"
+
+	// DefaultOutlierThreshold is how many standard deviations from the
+	// average something has to be to count as an outlier
+	DefaultOutlierThreshold = 2.0
+)
+
+// Outlier is a track or artist that stands out from the rest of the user's
+// top lists, along with why.
+type Outlier struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+	Explanation string   `json:"explanation"`
+}
+
+// ----
+// Members
+// ----
+
+// FindTrackOutliers returns the tracks that sound furthest from the rest,
+// or whose popularity is unusually low. How far a track sounds is its
+// distance from the average of the tracks' audio features, with each
+// feature measured in standard deviations so they count the same. A track
+// is an outlier when that distance is at least threshold standard
+// deviations more than the tracks' typical distance. The most extreme
+// tracks are first.
+func FindTrackOutliers(trax Tracks, af AudioFeatures, threshold float64) []Outlier {
+	analyzed := af.Analyzed()
+	features := analyzed.ByID()
+	stats := map[string]*FeatureStats{}
+	for _, f := range ProfileFeatures {
+		// the features come from a fixed list, so this can't fail
+		stats[f], _ = analyzed.Stats(f)
+	}
+
+	// how many standard deviations each track is from the average, for each
+	// feature
+	deviations := map[string]map[string]float64{}
+	distances := map[string]float64{}
+	for _, t := range trax {
+		f, ok := features[t.ID]
+		if !ok {
+			continue
+		}
+
+		deviations[t.ID] = map[string]float64{}
+		sum := 0.0
+		for _, name := range ProfileFeatures {
+			v, _ := f.Feature(name)
+			z := zScore(v, stats[name].Mean, stats[name].StdDev)
+			deviations[t.ID][name] = z
+			sum += z * z
+		}
+		distances[t.ID] = math.Sqrt(sum)
+	}
+
+	dists := []float64{}
+	for _, d := range distances {
+		dists = append(dists, d)
+	}
+	distStats := summarize(dists)
+
+	popularity := make([]float64, len(trax))
+	for i, t := range trax {
+		popularity[i] = float64(t.Popularity)
+	}
+	popStats := summarize(popularity)
+
+	ret := []Outlier{}
+	for _, t := range trax {
+		o := Outlier{Type: OutlierTypeTrack, ID: t.ID, Name: t.Name, Reasons: []string{}}
+
+		// only sounding unusually far away is interesting here
+		if d, ok := distances[t.ID]; ok {
+			if z := zScore(d, distStats.Mean, distStats.StdDev); z >= threshold {
+				o.Reasons = append(o.Reasons, fmt.Sprintf("sounds %.1fσ further from your average than usual, mostly %s",
+					z, strings.Join(mainDeviations(deviations[t.ID]), " and ")))
+				o.Score = math.Max(o.Score, z)
+			}
+		}
+
+		// only unusually obscure tracks are interesting here
+		if z := zScore(float64(t.Popularity), popStats.Mean, popStats.StdDev); z < 0 {
+			o.add("popularity", z, threshold)
+		}
+
+		if len(o.Reasons) > 0 {
+			ret = append(ret, o.explain())
+		}
+	}
+
+	return sortOutliers(ret)
+}
+
+// FindArtistOutliers returns the artists whose genres appear nowhere else in
+// the user's top artists, or whose popularity is at least threshold standard
+// deviations below the average. The most extreme artists are first.
+func FindArtistOutliers(artists Artists, threshold float64) []Outlier {
+	counts := map[string]int{}
+	for _, a := range artists {
+		for _, g := range a.Genres {
+			counts[g]++
+		}
+	}
+
+	popularity := make([]float64, len(artists))
+	for i, a := range artists {
+		popularity[i] = float64(a.Popularity)
+	}
+	popStats := summarize(popularity)
+
+	ret := []Outlier{}
+	for _, a := range artists {
+		o := Outlier{Type: OutlierTypeArtist, ID: a.ID, Name: a.Name, Reasons: []string{}}
+
+		unique := len(a.Genres) > 0
+		for _, g := range a.Genres {
+			if counts[g] > 1 {
+				unique = false
+				break
+			}
+		}
+
+		if unique && len(artists) > 1 {
+			o.Reasons = append(o.Reasons, fmt.Sprint("genres (", strings.Join(a.Genres, ", "), ") appear nowhere else in your top artists"))
+			o.Score = math.Max(o.Score, threshold)
+		}
+
+		if z := zScore(float64(a.Popularity), popStats.Mean, popStats.StdDev); z < 0 {
+			o.add("popularity", z, threshold)
+		}
+
+		if len(o.Reasons) > 0 {
+			ret = append(ret, o.explain())
+		}
+	}
+
+	return sortOutliers(ret)
+}
+
+// ----
+// Helpers
+// ----
+
+// mainDeviations describes the features that put the track furthest from
+// the average, the biggest first
+func mainDeviations(deviations map[string]float64) []string {
+	names := append([]string{}, ProfileFeatures...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return math.Abs(deviations[names[i]]) > math.Abs(deviations[names[j]])
+	})
+
+	ret := []string{}
+	for _, name := range names[:2] {
+		z := deviations[name]
+		if math.Abs(z) < 1 && len(ret) > 0 {
+			break
+		}
+
+		direction := "above"
+		if z < 0 {
+			direction = "below"
+		}
+		ret = append(ret, fmt.Sprintf("%s %.1fσ %s", name, math.Abs(z), direction))
+	}
+
+	return ret
+}
+
+// add records the reason if the z score is past the threshold
+func (o *Outlier) add(name string, z float64, threshold float64) {
+	if math.Abs(z) < threshold {
+		return
+	}
+
+	direction := "above"
+	if z < 0 {
+		direction = "below"
+	}
+
+	o.Reasons = append(o.Reasons, fmt.Sprintf("%s %.1fσ %s your average", name, math.Abs(z), direction))
+	o.Score = math.Max(o.Score, math.Abs(z))
+}
+
+func (o Outlier) explain() Outlier {
+	o.Explanation = strings.Join(o.Reasons, "; ")
+	return o
+}
+
+func sortOutliers(o []Outlier) []Outlier {
+	sort.SliceStable(o, func(i, j int) bool {
+		return o[i].Score > o[j].Score
+	})
+	return o
+}
+
+// zScore returns how many standard deviations the value is from the mean.
+// When there's no deviation at all nothing can stand out, so 0 is returned.
+func zScore(v float64, mean float64, stdDev float64) float64 {
+	if stdDev == 0 {
+		return 0
+	}
+
+	return (v - mean) / stdDev
+}
@@ -0,0 +1,85 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindTrackOutliers(t *testing.T) {
+	trax := Tracks{}
+	af := AudioFeatures{}
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprint(i)
+		trax = append(trax, Track{ID: id, Name: id, Popularity: 70})
+		af = append(af, AudioFeature{ID: id, Valence: 0.7, Energy: 0.8})
+	}
+
+	trax = append(trax, Track{ID: "sad", Name: "Adam's Song", Popularity: 70})
+	af = append(af, AudioFeature{ID: "sad", Valence: 0.05, Energy: 0.8})
+	trax = append(trax, Track{ID: "deep-cut", Name: "Deep Cut", Popularity: 5})
+	af = append(af, AudioFeature{ID: "deep-cut", Valence: 0.7, Energy: 0.8})
+	// a track spotify hasn't analyzed doesn't move the average
+	trax = append(trax, Track{ID: "unanalyzed", Name: "Unanalyzed", Popularity: 70})
+	af = append(af, AudioFeature{})
+
+	outliers := FindTrackOutliers(trax, af, DefaultOutlierThreshold)
+	assert.Equal(t, 2, len(outliers))
+
+	byID := map[string]Outlier{}
+	for _, o := range outliers {
+		assert.Equal(t, OutlierTypeTrack, o.Type)
+		byID[o.ID] = o
+	}
+
+	assert.Equal(t, "sounds 3.3σ further from your average than usual, mostly valence 3.3σ below", byID["sad"].Explanation)
+	assert.Equal(t, "popularity 3.5σ below your average", byID["deep-cut"].Explanation)
+
+	t.Run("Distance", func(t *testing.T) {
+		trax := Tracks{}
+		af := AudioFeatures{}
+		for i := 0; i < 10; i++ {
+			id := fmt.Sprint(i)
+			trax = append(trax, Track{ID: id, Name: id})
+			spread := float32(i%2) * 0.2
+			af = append(af, AudioFeature{ID: id, Valence: 0.6 + spread, Energy: 0.7 + spread, Danceability: 0.4 + spread})
+		}
+
+		// no feature is far off on its own, but together they are
+		trax = append(trax, Track{ID: "odd", Name: "Odd"})
+		af = append(af, AudioFeature{ID: "odd", Valence: 0.9, Energy: 0.5, Danceability: 0.7})
+
+		outliers := FindTrackOutliers(trax, af, DefaultOutlierThreshold)
+		assert.Equal(t, 1, len(outliers))
+		assert.Equal(t, "odd", outliers[0].ID)
+		assert.Contains(t, outliers[0].Explanation, "mostly")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, 0, len(FindTrackOutliers(Tracks{}, AudioFeatures{}, DefaultOutlierThreshold)))
+	})
+}
+
+func TestFindArtistOutliers(t *testing.T) {
+	artists := Artists{
+		{ID: "1", Name: "blink-182", Genres: []string{"pop punk", "punk"}, Popularity: 80},
+		{ID: "2", Name: "Green Day", Genres: []string{"pop punk", "punk"}, Popularity: 80},
+		{ID: "3", Name: "Dolly Parton", Genres: []string{"country"}, Popularity: 78},
+		{ID: "4", Name: "No Genres", Popularity: 80},
+	}
+
+	outliers := FindArtistOutliers(artists, DefaultOutlierThreshold)
+	assert.Equal(t, 1, len(outliers))
+	assert.Equal(t, "3", outliers[0].ID)
+	assert.Equal(t, "genres (country) appear nowhere else in your top artists", outliers[0].Explanation)
+
+	t.Run("SingleArtist", func(t *testing.T) {
+		assert.Equal(t, 0, len(FindArtistOutliers(artists[2:3], DefaultOutlierThreshold)))
+	})
+}
+
+func TestZScore(t *testing.T) {
+	assert.Equal(t, 2.0, zScore(14, 10, 2))
+	assert.Equal(t, 0.0, zScore(14, 10, 0))
+}
@@ -0,0 +1,470 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/keys"
+)
+
+const (
+	playlistsPageLimit     = 50
+	playlistItemsPageLimit = 100
+	playlistCoverMaxBytes  = 256 * 1024
+)
+
+// Playlist represents a spotify playlist
+type Playlist struct {
+	Links         map[string]string `json:"external_urls"`
+	Collaborative bool              `json:"collaborative"`
+	Description   string            `json:"description"`
+	ID            string            `json:"id"`
+	Images        []Image           `json:"images"`
+	Name          string            `json:"name"`
+	Owner         User              `json:"owner"`
+	Public        bool              `json:"public"`
+	SnapshotID    string            `json:"snapshot_id"`
+	URI           string            `json:"uri"`
+	Tracks        struct {
+		Total int `json:"total"`
+	} `json:"tracks"`
+}
+
+// Playlists is a collection of spotify Playlists
+type Playlists []Playlist
+
+// PlaylistRef is a lightweight reference to a playlist
+type PlaylistRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DuplicateTrack describes a track that is listed more than once across a
+// set of playlists.
+type DuplicateTrack struct {
+	Track     Track         `json:"track"`
+	Playlists []PlaylistRef `json:"playlists"`
+}
+
+// ----
+// API
+// ----
+
+// GetUserPlaylists will retrieve every playlist the user owns or follows.
+func GetUserPlaylists(ctx context.Context) (*Playlists, error) {
+	url := fmt.Sprint("https://api.spotify.com/v1/me/playlists?offset=0&limit=", playlistsPageLimit)
+	ret := Playlists{}
+	for len(url) > 0 {
+		req, err := parseRequestForPlaylistPage(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		p, next, err := parseResponseForGetUserPlaylists(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *p...)
+		url = next
+	}
+
+	return &ret, nil
+}
+
+// GetPlaylistTracks will retrieve every track in the playlist. Local files
+// and podcast episodes are skipped.
+func GetPlaylistTracks(ctx context.Context, id string) (*Tracks, error) {
+	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%v/tracks?offset=0&limit=%v", id, playlistItemsPageLimit)
+	ret := Tracks{}
+	for len(url) > 0 {
+		req, err := parseRequestForPlaylistPage(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		t, next, err := parseResponseForGetPlaylistTracks(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, *t...)
+		url = next
+	}
+
+	return &ret, nil
+}
+
+// CreatePlaylist will create a new, empty playlist for the given user.
+func CreatePlaylist(ctx context.Context, userID string, name string, description string, public bool) (*Playlist, error) {
+	req, err := parseRequestForCreatePlaylist(ctx, userID, name, description, public)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeRequest(context.WithValue(ctx, keys.ContextSkipCache, true), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponseForCreatePlaylist(body)
+}
+
+// AddTracksToPlaylist will append the provided track uris to the end of the
+// playlist. Spotify only accepts 100 items per request, so the uris are
+// sent in batches. The snapshot id from the final batch is returned.
+func AddTracksToPlaylist(ctx context.Context, id string, uris []string) (string, error) {
+	snapshot := ""
+	for i := 0; i < len(uris); i += playlistItemsPageLimit {
+		begin, ending := chunkRange(i, len(uris), playlistItemsPageLimit)
+		req, err := parseRequestForPlaylistItems(ctx, "POST", id, uris[begin:ending])
+		if err != nil {
+			return "", err
+		}
+
+		body, err := makeRequest(context.WithValue(ctx, keys.ContextSkipCache, true), req)
+		if err != nil {
+			return "", err
+		}
+
+		snapshot, err = parseResponseForSnapshot(body)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// ReplacePlaylistTracks will overwrite the items in the playlist with the
+// provided track uris. Providing no uris will clear the playlist.
+func ReplacePlaylistTracks(ctx context.Context, id string, uris []string) (string, error) {
+	_, ending := chunkRange(0, len(uris), playlistItemsPageLimit)
+	req, err := parseRequestForPlaylistItems(ctx, "PUT", id, uris[:ending])
+	if err != nil {
+		return "", err
+	}
+
+	body, err := makeRequest(context.WithValue(ctx, keys.ContextSkipCache, true), req)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot, err := parseResponseForSnapshot(body)
+	if err != nil {
+		return "", err
+	}
+
+	// anything past the first page has to be appended
+	if ending < len(uris) {
+		return AddTracksToPlaylist(ctx, id, uris[ending:])
+	}
+
+	return snapshot, nil
+}
+
+// ReorderPlaylistTracks will move rangeLength items, starting at rangeStart,
+// so that they are placed before the item at insertBefore. The snapshotID
+// is optional and will make spotify validate the playlist hasn't changed.
+func ReorderPlaylistTracks(ctx context.Context, id string, rangeStart int, insertBefore int, rangeLength int, snapshotID string) (string, error) {
+	req, err := parseRequestForReorderPlaylist(ctx, id, rangeStart, insertBefore, rangeLength, snapshotID)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := makeRequest(context.WithValue(ctx, keys.ContextSkipCache, true), req)
+	if err != nil {
+		return "", err
+	}
+
+	return parseResponseForSnapshot(body)
+}
+
+// UploadPlaylistCover will set the cover image for the playlist. Spotify
+// requires the image to be a jpeg no larger than 256 KB.
+func UploadPlaylistCover(ctx context.Context, id string, jpeg []byte) error {
+	req, err := parseRequestForPlaylistCover(ctx, id, jpeg)
+	if err != nil {
+		return err
+	}
+
+	_, err = makeRequest(context.WithValue(ctx, keys.ContextSkipCache, true), req)
+	return err
+}
+
+// ----
+// Members
+// ----
+
+// Ref returns a lightweight reference to the playlist
+func (p *Playlist) Ref() PlaylistRef {
+	return PlaylistRef{ID: p.ID, Name: p.Name}
+}
+
+// FindDuplicateTracks will look through the tracks for each of the given
+// playlists and return the tracks that are listed more than once. Tracks are
+// matched on their artist and name, so the same song released on a single
+// and an album is still considered a duplicate.
+func FindDuplicateTracks(playlists Playlists, tracks map[string]Tracks) []DuplicateTrack {
+	found := map[string]*DuplicateTrack{}
+	order := []string{}
+	for _, p := range playlists {
+		for _, t := range tracks[p.ID] {
+			key := duplicateKey(&t)
+			if _, ok := found[key]; !ok {
+				found[key] = &DuplicateTrack{Track: t}
+				order = append(order, key)
+			}
+
+			found[key].Playlists = append(found[key].Playlists, p.Ref())
+		}
+	}
+
+	ret := []DuplicateTrack{}
+	for _, k := range order {
+		if len(found[k].Playlists) > 1 {
+			ret = append(ret, *found[k])
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return len(ret[i].Playlists) > len(ret[j].Playlists)
+	})
+
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+func duplicateKey(t *Track) string {
+	return fmt.Sprint(strings.ToLower(t.FindArtist()), "|", strings.ToLower(strings.TrimSpace(t.Name)))
+}
+
+func parseRequestForPlaylistPage(ctx context.Context, url string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseResponseForGetUserPlaylists(body *[]byte) (*Playlists, string, error) {
+	type tempResp struct {
+		Items Playlists `json:"items"`
+		Next  string    `json:"next"`
+	}
+
+	var ret tempResp
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &ret.Items, ret.Next, nil
+}
+
+func parseResponseForGetPlaylistTracks(body *[]byte) (*Tracks, string, error) {
+	type tempResp struct {
+		Items items  `json:"items"`
+		Next  string `json:"next"`
+	}
+
+	var ret tempResp
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	trax := Tracks{}
+	for _, i := range ret.Items.Tracks() {
+		// playlists can hold podcast episodes, which aren't tracks. Local
+		// files and tracks that have been removed come back without an id.
+		if i.Type != trackTypeTrack || i.IsLocal || len(i.ID) < 1 {
+			continue
+		}
+
+		trax = append(trax, i)
+	}
+
+	return &trax, ret.Next, nil
+}
+
+func parseRequestForCreatePlaylist(ctx context.Context, userID string, name string, description string, public bool) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	if len(userID) < 1 {
+		return nil, errors.New("no user id provided")
+	}
+
+	if len(name) < 1 {
+		return nil, errors.New("no playlist name provided")
+	}
+
+	b, err := json.Marshal(struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}{
+		Name:        name,
+		Description: description,
+		Public:      public,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.spotify.com/v1/users/%v/playlists", userID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
+}
+
+func parseResponseForCreatePlaylist(body *[]byte) (*Playlist, error) {
+	var ret Playlist
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// parseRequestForPlaylistItems builds the request to add (POST) or replace
+// (PUT) the items in a playlist.
+func parseRequestForPlaylistItems(ctx context.Context, method string, id string, uris []string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	if len(uris) > playlistItemsPageLimit {
+		return nil, errors.New(fmt.Sprint("too many items provided: ", len(uris)))
+	}
+
+	b, err := json.Marshal(struct {
+		URIs []string `json:"uris"`
+	}{URIs: uris})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%v/tracks", id)
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
+}
+
+func parseRequestForReorderPlaylist(ctx context.Context, id string, rangeStart int, insertBefore int, rangeLength int, snapshotID string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	if rangeStart < 0 || insertBefore < 0 || rangeLength < 1 {
+		return nil, errors.New("invalid reorder range")
+	}
+
+	b, err := json.Marshal(struct {
+		RangeStart   int    `json:"range_start"`
+		InsertBefore int    `json:"insert_before"`
+		RangeLength  int    `json:"range_length"`
+		SnapshotID   string `json:"snapshot_id,omitempty"`
+	}{
+		RangeStart:   rangeStart,
+		InsertBefore: insertBefore,
+		RangeLength:  rangeLength,
+		SnapshotID:   snapshotID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%v/tracks", id)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
+}
+
+func parseRequestForPlaylistCover(ctx context.Context, id string, jpeg []byte) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	if len(jpeg) < 1 {
+		return nil, errors.New("no image provided")
+	}
+
+	if len(jpeg) > playlistCoverMaxBytes {
+		return nil, errors.New(fmt.Sprint("image too large: ", len(jpeg), " bytes"))
+	}
+
+	body := base64.StdEncoding.EncodeToString(jpeg)
+	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%v/images", id)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "image/jpeg")
+	return req, nil
+}
+
+func parseResponseForSnapshot(body *[]byte) (string, error) {
+	type tempResp struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+
+	var ret tempResp
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return "", err
+	}
+
+	return ret.SnapshotID, nil
+}
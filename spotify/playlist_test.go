@@ -0,0 +1,368 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedHttpClient returns the provided bodies in order, one per request, so
+// methods that page through results can be tested.
+type pagedHttpClient struct {
+	Bodies   []string
+	Requests []*http.Request
+}
+
+func (c *pagedHttpClient) Do(req *http.Request) (*http.Response, error) {
+	c.Requests = append(c.Requests, req)
+	body := "{}"
+	if len(c.Bodies) > 0 {
+		body = c.Bodies[0]
+		c.Bodies = c.Bodies[1:]
+	}
+
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func getPagedTestDependencies(ctx context.Context, bodies ...string) (context.Context, *pagedHttpClient) {
+	client := &pagedHttpClient{Bodies: bodies}
+	ctx = context.WithValue(ctx, keys.ContextDependencies, &Dependencies{Client: client})
+	return context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test"), client
+}
+
+func TestGetUserPlaylists(t *testing.T) {
+	t.Run("TestParseRequestForPlaylistPage", func(t *testing.T) {
+		_, err := parseRequestForPlaylistPage(context.Background(), "http://localhost")
+		assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+	})
+
+	t.Run("TestParseResponseForGetUserPlaylists", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(`{"items":[{"id":"1","name":"one"}],"next":"http://next"}`)
+			p, next, err := parseResponseForGetUserPlaylists(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(*p))
+			assert.Equal(t, "http://next", next)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, _, err := parseResponseForGetUserPlaylists(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		t.Run("FollowsPages", func(t *testing.T) {
+			ctx, client := getPagedTestDependencies(context.Background(),
+				`{"items":[{"id":"1"},{"id":"2"}],"next":"https://api.spotify.com/v1/me/playlists?offset=2"}`,
+				`{"items":[{"id":"3"}],"next":null}`,
+			)
+
+			p, err := GetUserPlaylists(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, len(*p))
+			assert.Equal(t, 2, len(client.Requests))
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetUserPlaylists(ctx)
+			assert.NotEqual(t, nil, err)
+		})
+	})
+}
+
+func TestGetPlaylistTracks(t *testing.T) {
+	t.Run("TestParseResponseForGetPlaylistTracks", func(t *testing.T) {
+		t.Run("skips local files and episodes", func(t *testing.T) {
+			bytes := []byte(`{"items":[{"track":{"id":"1","type":"track"}},{"track":{"id":null,"type":"track","is_local":true}},
+				{"track":{"id":"2","type":"episode"}},{"track":null}]}`)
+			trax, next, err := parseResponseForGetPlaylistTracks(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(*trax))
+			assert.Equal(t, "", next)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, _, err := parseResponseForGetPlaylistTracks(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		ctx, client := getPagedTestDependencies(context.Background(),
+			`{"items":[{"track":{"id":"1","type":"track"}}],"next":"https://api.spotify.com/v1/playlists/abc/tracks?offset=100"}`,
+			`{"items":[{"track":{"id":"2","type":"track"}}]}`,
+		)
+
+		trax, err := GetPlaylistTracks(ctx, "abc")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, trax.IDs())
+		assert.Equal(t, "/v1/playlists/abc/tracks", client.Requests[0].URL.Path)
+	})
+}
+
+func TestFindDuplicateTracks(t *testing.T) {
+	artist := []Artist{{Name: "blink-182"}}
+	playlists := Playlists{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}}
+	tracks := map[string]Tracks{
+		"a": {
+			{ID: "1", Name: "Dammit", Artists: artist},
+			{ID: "2", Name: "Josie", Artists: artist},
+		},
+		"b": {
+			// same song, different release
+			{ID: "3", Name: "dammit ", Artists: artist},
+			{ID: "4", Name: "Adam's Song", Artists: artist},
+			{ID: "4", Name: "Adam's Song", Artists: artist},
+		},
+	}
+
+	dupes := FindDuplicateTracks(playlists, tracks)
+	assert.Equal(t, 2, len(dupes))
+	assert.Equal(t, "1", dupes[0].Track.ID)
+	assert.Equal(t, []PlaylistRef{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}}, dupes[0].Playlists)
+	assert.Equal(t, []PlaylistRef{{ID: "b", Name: "B"}, {ID: "b", Name: "B"}}, dupes[1].Playlists)
+}
+
+func TestCreatePlaylist(t *testing.T) {
+	t.Run("TestParseRequestForCreatePlaylist", func(t *testing.T) {
+		ctx := context.Background()
+		t.Run("no token", func(t *testing.T) {
+			_, err := parseRequestForCreatePlaylist(ctx, "user", "name", "", false)
+			assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+		})
+
+		token := "tok"
+		ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, token)
+
+		t.Run("no user", func(t *testing.T) {
+			_, err := parseRequestForCreatePlaylist(ctx, "", "name", "", false)
+			assert.NotNil(t, err)
+		})
+
+		t.Run("no name", func(t *testing.T) {
+			_, err := parseRequestForCreatePlaylist(ctx, "user", "", "", false)
+			assert.NotNil(t, err)
+		})
+
+		t.Run("token gets stored in header", func(t *testing.T) {
+			req, err := parseRequestForCreatePlaylist(ctx, "user", "name", "desc", true)
+			assert.Nil(t, err)
+			assert.Equal(t, req.Header.Get("Authorization"), fmt.Sprint("Bearer ", token))
+			assert.Equal(t, "/v1/users/user/playlists", req.URL.Path)
+
+			b, _ := ioutil.ReadAll(req.Body)
+			assert.Equal(t, `{"name":"name","description":"desc","public":true}`, string(b))
+		})
+	})
+
+	t.Run("TestParseResponseForCreatePlaylist", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(createPlaylistPayload)
+
+			p, err := parseResponseForCreatePlaylist(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, "7d2D2S200NyUE5KYs80PwO", p.ID)
+			assert.Equal(t, "thelinmichael", p.Owner.ID)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseResponseForCreatePlaylist(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 201, createPlaylistPayload)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			p, err := CreatePlaylist(ctx, "user", "name", "", false)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "New Playlist", p.Name)
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := CreatePlaylist(ctx, "user", "name", "", false)
+			assert.NotEqual(t, nil, err)
+		})
+	})
+}
+
+func TestPlaylistItems(t *testing.T) {
+	t.Run("TestParseRequestForPlaylistItems", func(t *testing.T) {
+		ctx := context.Background()
+		t.Run("no token", func(t *testing.T) {
+			_, err := parseRequestForPlaylistItems(ctx, "POST", "id", []string{"uri"})
+			assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+		})
+
+		token := "tok"
+		ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, token)
+
+		t.Run("too many items", func(t *testing.T) {
+			uris := make([]string, playlistItemsPageLimit+1)
+			_, err := parseRequestForPlaylistItems(ctx, "POST", "id", uris)
+			assert.NotNil(t, err)
+		})
+
+		t.Run("method and body", func(t *testing.T) {
+			req, err := parseRequestForPlaylistItems(ctx, "PUT", "id", []string{"a", "b"})
+			assert.Nil(t, err)
+			assert.Equal(t, "PUT", req.Method)
+			assert.Equal(t, req.Header.Get("Authorization"), fmt.Sprint("Bearer ", token))
+
+			b, _ := ioutil.ReadAll(req.Body)
+			assert.Equal(t, `{"uris":["a","b"]}`, string(b))
+		})
+	})
+
+	t.Run("TestParseResponseForSnapshot", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(`{"snapshot_id":"abc"}`)
+			s, err := parseResponseForSnapshot(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, "abc", s)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseResponseForSnapshot(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("AddTracksToPlaylist", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 201, `{"snapshot_id":"abc"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			s, err := AddTracksToPlaylist(ctx, "id", []string{"uri"})
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "abc", s)
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := AddTracksToPlaylist(ctx, "id", []string{"uri"})
+			assert.NotEqual(t, nil, err)
+		})
+	})
+
+	t.Run("ReplacePlaylistTracks", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 200, `{"snapshot_id":"abc"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			s, err := ReplacePlaylistTracks(ctx, "id", []string{})
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "abc", s)
+		})
+	})
+}
+
+func TestReorderPlaylistTracks(t *testing.T) {
+	ctx := context.WithValue(context.Background(), keys.ContextSpotifyAccessToken, "tok")
+	t.Run("InvalidRange", func(t *testing.T) {
+		_, err := parseRequestForReorderPlaylist(ctx, "id", 0, 2, 0, "")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		req, err := parseRequestForReorderPlaylist(ctx, "id", 1, 5, 2, "")
+		assert.Nil(t, err)
+
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, `{"range_start":1,"insert_before":5,"range_length":2}`, string(b))
+	})
+}
+
+func TestUploadPlaylistCover(t *testing.T) {
+	ctx := context.WithValue(context.Background(), keys.ContextSpotifyAccessToken, "tok")
+	t.Run("NoImage", func(t *testing.T) {
+		_, err := parseRequestForPlaylistCover(ctx, "id", []byte{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		_, err := parseRequestForPlaylistCover(ctx, "id", make([]byte, playlistCoverMaxBytes+1))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("EncodesBody", func(t *testing.T) {
+		req, err := parseRequestForPlaylistCover(ctx, "id", []byte("jpeg"))
+		assert.Nil(t, err)
+		assert.Equal(t, "image/jpeg", req.Header.Get("Content-Type"))
+
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "anBlZw==", string(b))
+	})
+
+	t.Run("Accepted", func(t *testing.T) {
+		ctx := getTestDependencies(ctx, 202, "")
+		err := UploadPlaylistCover(ctx, "id", []byte("jpeg"))
+		assert.Nil(t, err)
+	})
+}
+
+var (
+	createPlaylistPayload = `{
+		"collaborative": false,
+		"description": "New playlist description",
+		"external_urls": {
+		  "spotify": "https://open.spotify.com/playlist/7d2D2S200NyUE5KYs80PwO"
+		},
+		"followers": {
+		  "href": null,
+		  "total": 0
+		},
+		"href": "https://api.spotify.com/v1/playlists/7d2D2S200NyUE5KYs80PwO",
+		"id": "7d2D2S200NyUE5KYs80PwO",
+		"images": [],
+		"name": "New Playlist",
+		"owner": {
+		  "display_name": "JMPerez²",
+		  "external_urls": {
+			"spotify": "https://open.spotify.com/user/thelinmichael"
+		  },
+		  "href": "https://api.spotify.com/v1/users/thelinmichael",
+		  "id": "thelinmichael",
+		  "type": "user",
+		  "uri": "spotify:user:thelinmichael"
+		},
+		"public": false,
+		"snapshot_id": "MSw4ZTJkYWYyMzYzZDVhZjQ1OGRlNWYzNmMyNTk1ZDc0MDg0OWNhNGJh",
+		"tracks": {
+		  "href": "https://api.spotify.com/v1/playlists/7d2D2S200NyUE5KYs80PwO/tracks",
+		  "items": [],
+		  "limit": 100,
+		  "next": null,
+		  "offset": 0,
+		  "previous": null,
+		  "total": 0
+		},
+		"type": "playlist",
+		"uri": "spotify:playlist:7d2D2S200NyUE5KYs80PwO"
+	  }`
+)
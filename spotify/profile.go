@@ -0,0 +1,262 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	FeatureDanceability     = "danceability"
+	FeatureEnergy           = "energy"
+	FeatureValence          = "valence"
+	FeatureAcousticness     = "acousticness"
+	FeatureInstrumentalness = "instrumentalness"
+	FeatureSpeechiness      = "speechiness"
+	FeatureLiveness         = "liveness"
+	FeatureLoudness         = "loudness"
+	FeatureTempo            = "tempo"
+	ModeMajor               = "major"
+	ModeMinor               = "minor"
+)
+
+var (
+	// ProfileFeatures are the audio features that are summarized in a profile
+	ProfileFeatures = []string{
+		FeatureDanceability,
+		FeatureEnergy,
+		FeatureValence,
+		FeatureAcousticness,
+		FeatureInstrumentalness,
+		FeatureSpeechiness,
+		FeatureLiveness,
+		FeatureLoudness,
+		FeatureTempo,
+	}
+
+	// KeyNames maps spotify's pitch class notation to a readable key
+	KeyNames = []string{"C", "C♯/D♭", "D", "D♯/E♭", "E", "F", "F♯/G♭", "G", "G♯/A♭", "A", "A♯/B♭", "B"}
+)
+
+// FeatureStats summarizes the values of a single audio feature
+type FeatureStats struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"std_dev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	P10    float64 `json:"p10"`
+	P25    float64 `json:"p25"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+}
+
+// AudioProfile is the "sound fingerprint" for a set of tracks
+type AudioProfile struct {
+	Count    int                     `json:"count"`
+	Features map[string]FeatureStats `json:"features"`
+	Keys     sortablemap.Map         `json:"keys"`
+	Modes    sortablemap.Map         `json:"modes"`
+}
+
+// ----
+// Members
+// ----
+
+// Feature returns the value of the audio feature with the given name
+func (a *AudioFeature) Feature(name string) (float64, error) {
+	switch name {
+	case FeatureDanceability:
+		return float64(a.Danceability), nil
+	case FeatureEnergy:
+		return float64(a.Energy), nil
+	case FeatureValence:
+		return float64(a.Valence), nil
+	case FeatureAcousticness:
+		return float64(a.Acousticness), nil
+	case FeatureInstrumentalness:
+		return float64(a.Instrumentalness), nil
+	case FeatureSpeechiness:
+		return float64(a.Speechiness), nil
+	case FeatureLiveness:
+		return float64(a.Liveness), nil
+	case FeatureLoudness:
+		return float64(a.Loudness), nil
+	case FeatureTempo:
+		return float64(a.Tempo), nil
+	}
+
+	return 0, errors.New(fmt.Sprint("unknown audio feature: ", name))
+}
+
+// KeyName returns the readable key for the track, or an empty string if
+// spotify couldn't detect one.
+func (a *AudioFeature) KeyName() string {
+	if a.Key < 0 || a.Key >= len(KeyNames) {
+		return ""
+	}
+
+	return KeyNames[a.Key]
+}
+
+// ModeName returns whether the track is in a major or minor key
+func (a *AudioFeature) ModeName() string {
+	if a.Mode == 1 {
+		return ModeMajor
+	}
+
+	return ModeMinor
+}
+
+// Analyzed returns the features for the tracks spotify has analyzed. The
+// rest come back as null, which leaves them empty.
+func (a *AudioFeatures) Analyzed() AudioFeatures {
+	ret := AudioFeatures{}
+	for _, i := range *a {
+		if i != (AudioFeature{}) {
+			ret = append(ret, i)
+		}
+	}
+
+	return ret
+}
+
+// Values returns the value of the given feature for each of the analyzed
+// tracks
+func (a *AudioFeatures) Values(name string) ([]float64, error) {
+	ret := []float64{}
+	for _, i := range a.Analyzed() {
+		v, err := i.Feature(name)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, v)
+	}
+
+	return ret, nil
+}
+
+// Stats summarizes the given feature across all of the analyzed tracks
+func (a *AudioFeatures) Stats(name string) (*FeatureStats, error) {
+	vals, err := a.Values(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := summarize(vals)
+	return &ret, nil
+}
+
+// Mean returns a track with the average of every profile feature across
+// all of the analyzed tracks. It's useful as a target to compare other tracks with.
+func (a *AudioFeatures) Mean() AudioFeature {
+	ret := AudioFeature{}
+	analyzed := a.Analyzed()
+	if len(analyzed) < 1 {
+		return ret
+	}
+
+	n := float32(len(analyzed))
+	for _, i := range analyzed {
+		ret.Danceability += i.Danceability / n
+		ret.Energy += i.Energy / n
+		ret.Valence += i.Valence / n
+		ret.Acousticness += i.Acousticness / n
+		ret.Instrumentalness += i.Instrumentalness / n
+		ret.Speechiness += i.Speechiness / n
+		ret.Liveness += i.Liveness / n
+		ret.Loudness += i.Loudness / n
+		ret.Tempo += i.Tempo / n
+	}
+
+	return ret
+}
+
+// Profile summarizes every profile feature, along with the distribution of
+// keys and modes, across all of the analyzed tracks.
+func (a *AudioFeatures) Profile() *AudioProfile {
+	analyzed := a.Analyzed()
+	ret := AudioProfile{
+		Count:    len(analyzed),
+		Features: map[string]FeatureStats{},
+	}
+
+	for _, f := range ProfileFeatures {
+		// the features come from a fixed list, so this can't fail
+		stats, _ := a.Stats(f)
+		ret.Features[f] = *stats
+	}
+
+	keys := map[string]int{}
+	modes := map[string]int{}
+	for _, i := range analyzed {
+		if k := i.KeyName(); len(k) > 0 {
+			keys[k]++
+		}
+		modes[i.ModeName()]++
+	}
+
+	ret.Keys = sortablemap.GetSortableMap(keys)
+	sort.Sort(sort.Reverse(ret.Keys))
+	ret.Modes = sortablemap.GetSortableMap(modes)
+	sort.Sort(sort.Reverse(ret.Modes))
+
+	return &ret
+}
+
+// ----
+// Helpers
+// ----
+
+// summarize returns the stats for the values, in any order
+func summarize(values []float64) FeatureStats {
+	ret := FeatureStats{}
+	if len(values) < 1 {
+		return ret
+	}
+
+	vals := append([]float64{}, values...)
+	sort.Float64s(vals)
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	ret.Mean = sum / float64(len(vals))
+
+	variance := 0.0
+	for _, v := range vals {
+		variance += math.Pow(v-ret.Mean, 2)
+	}
+	ret.StdDev = math.Sqrt(variance / float64(len(vals)))
+
+	ret.Min = vals[0]
+	ret.Max = vals[len(vals)-1]
+	ret.Median = percentile(vals, 50)
+	ret.P10 = percentile(vals, 10)
+	ret.P25 = percentile(vals, 25)
+	ret.P75 = percentile(vals, 75)
+	ret.P90 = percentile(vals, 90)
+
+	return ret
+}
+
+// percentile returns the value at the given percentile, interpolating
+// between the closest ranks. The values must already be sorted.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) < 1 {
+		return 0
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
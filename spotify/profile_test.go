@@ -0,0 +1,101 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioFeatureFeature(t *testing.T) {
+	a := AudioFeature{Energy: 0.5, Tempo: 120}
+
+	t.Run("Known", func(t *testing.T) {
+		v, err := a.Feature(FeatureTempo)
+		assert.Nil(t, err)
+		assert.Equal(t, 120.0, v)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := a.Feature("cowbell")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("EveryProfileFeature", func(t *testing.T) {
+		for _, f := range ProfileFeatures {
+			_, err := a.Feature(f)
+			assert.Nil(t, err, f)
+		}
+	})
+}
+
+func TestAudioFeaturesStats(t *testing.T) {
+	// the empty one is a track spotify hasn't analyzed
+	af := AudioFeatures{
+		{ID: "1", Tempo: 100},
+		{ID: "2", Tempo: 120},
+		{},
+		{ID: "3", Tempo: 140},
+		{ID: "4", Tempo: 160},
+		{ID: "5", Tempo: 180},
+	}
+
+	stats, err := af.Stats(FeatureTempo)
+	assert.Nil(t, err)
+	assert.Equal(t, 140.0, stats.Mean)
+	assert.Equal(t, 140.0, stats.Median)
+	assert.InDelta(t, 28.28, stats.StdDev, 0.01)
+	assert.Equal(t, 100.0, stats.Min)
+	assert.Equal(t, 180.0, stats.Max)
+	assert.Equal(t, 120.0, stats.P25)
+	assert.Equal(t, 172.0, stats.P90)
+
+	t.Run("Empty", func(t *testing.T) {
+		stats, err := (&AudioFeatures{}).Stats(FeatureTempo)
+		assert.Nil(t, err)
+		assert.Equal(t, FeatureStats{}, *stats)
+	})
+
+	t.Run("UnknownFeature", func(t *testing.T) {
+		_, err := af.Stats("cowbell")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestAudioFeaturesMean(t *testing.T) {
+	af := AudioFeatures{
+		{ID: "1", Energy: 0.2, Tempo: 100, Loudness: -10},
+		{},
+		{ID: "2", Energy: 0.6, Tempo: 140, Loudness: -6},
+	}
+
+	m := af.Mean()
+	assert.InDelta(t, 0.4, m.Energy, 0.0001)
+	assert.InDelta(t, 120, m.Tempo, 0.0001)
+	assert.InDelta(t, -8, m.Loudness, 0.0001)
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, AudioFeature{}, (&AudioFeatures{}).Mean())
+	})
+}
+
+func TestAudioFeaturesProfile(t *testing.T) {
+	af := AudioFeatures{
+		{ID: "1", Key: 0, Mode: 1, Energy: 0.2},
+		{ID: "2", Key: 0, Mode: 0, Energy: 0.4},
+		{ID: "3", Key: 9, Mode: 1, Energy: 0.6},
+		{ID: "4", Key: -1, Mode: 1, Energy: 0.8},
+		{},
+	}
+
+	p := af.Profile()
+	assert.Equal(t, 4, p.Count)
+	assert.Equal(t, len(ProfileFeatures), len(p.Features))
+	assert.InDelta(t, 0.5, p.Features[FeatureEnergy].Mean, 0.0001)
+
+	assert.Equal(t, 2, len(p.Keys))
+	assert.Equal(t, "C", p.Keys[0].Key)
+	assert.Equal(t, int32(2), p.Keys[0].Value)
+
+	assert.Equal(t, ModeMajor, p.Modes[0].Key)
+	assert.Equal(t, int32(3), p.Modes[0].Value)
+}
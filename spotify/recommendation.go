@@ -3,8 +3,12 @@ package spotify
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/mike-webster/spotify-views/keys"
@@ -20,13 +24,68 @@ type Recommendation struct {
 	} `json:"seeds"`
 }
 
+// RecommendationRequest holds the seeds and tunable attributes for a
+// recommendations request. Min, Max and Target are keyed by the attribute
+// name, see RecommendationAttributes.
+type RecommendationRequest struct {
+	SeedArtists []string           `json:"seed_artists"`
+	SeedTracks  []string           `json:"seed_tracks"`
+	SeedGenres  []string           `json:"seed_genres"`
+	Limit       int                `json:"limit"`
+	Market      string             `json:"market"`
+	Min         map[string]float64 `json:"min"`
+	Max         map[string]float64 `json:"max"`
+	Target      map[string]float64 `json:"target"`
+}
+
+const (
+	// RecommendationSeedLimit is the most seeds, of any type, spotify accepts
+	RecommendationSeedLimit = 5
+	// RecommendationMaxLimit is the most tracks spotify returns at once
+	RecommendationMaxLimit = 100
+)
+
+var (
+	// RecommendationAttributes are the tunable attributes along with the
+	// range spotify accepts for each
+	RecommendationAttributes = map[string][2]float64{
+		"acousticness":     {0, 1},
+		"danceability":     {0, 1},
+		"duration_ms":      {0, 3600000},
+		"energy":           {0, 1},
+		"instrumentalness": {0, 1},
+		"key":              {0, 11},
+		"liveness":         {0, 1},
+		"loudness":         {-60, 0},
+		"mode":             {0, 1},
+		"popularity":       {0, 100},
+		"speechiness":      {0, 1},
+		"tempo":            {0, 300},
+		"time_signature":   {3, 7},
+		"valence":          {0, 1},
+	}
+
+	// recommendationIntAttributes only accept whole numbers
+	recommendationIntAttributes = map[string]bool{
+		"duration_ms":    true,
+		"key":            true,
+		"mode":           true,
+		"popularity":     true,
+		"time_signature": true,
+	}
+)
+
 // ----
 // API
 // ---
 
 // GetRecommendations will perform a request to  retrieve spotify's recommendations for the user
-func GetRecommendations(ctx context.Context, seeds map[string][]string) (*Recommendation, error) {
-	req, err := parseRecommendationsRequest(ctx, seeds)
+func GetRecommendations(ctx context.Context, r RecommendationRequest) (*Recommendation, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	req, err := parseRecommendationsRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
@@ -43,42 +102,111 @@ func GetRecommendations(ctx context.Context, seeds map[string][]string) (*Recomm
 // Members
 // ----
 
-// ----
-// Helpers
-// ----
+// Seeds returns the number of seeds of every type
+func (r *RecommendationRequest) Seeds() int {
+	return len(r.SeedArtists) + len(r.SeedTracks) + len(r.SeedGenres)
+}
+
+// Tuned returns whether any of the tunable attributes were set
+func (r *RecommendationRequest) Tuned() bool {
+	return len(r.Min)+len(r.Max)+len(r.Target) > 0
+}
+
+// Validate makes sure the request can be sent to spotify
+func (r *RecommendationRequest) Validate() error {
+	if r.Seeds() < 1 {
+		return errors.New("at least one seed is required")
+	}
+
+	if r.Seeds() > RecommendationSeedLimit {
+		return errors.New(fmt.Sprint("at most ", RecommendationSeedLimit, " seeds are allowed, got ", r.Seeds()))
+	}
+
+	if r.Limit < 0 || r.Limit > RecommendationMaxLimit {
+		return errors.New(fmt.Sprint("limit must be between 1 and ", RecommendationMaxLimit, ", got ", r.Limit))
+	}
+
+	for prefix, vals := range map[string]map[string]float64{"min_": r.Min, "max_": r.Max, "target_": r.Target} {
+		for name, v := range vals {
+			bounds, ok := RecommendationAttributes[name]
+			if !ok {
+				return errors.New(fmt.Sprint("unknown recommendation attribute: ", name))
+			}
+
+			if v < bounds[0] || v > bounds[1] {
+				return errors.New(fmt.Sprint(prefix, name, " must be between ", bounds[0], " and ", bounds[1], ", got ", v))
+			}
 
-func getRecommendationsURL(ctx context.Context, seeds map[string][]string) string {
-	qs := "?"
-	if artists, ok := seeds[KeySeedArtists]; ok {
-		qs = fmt.Sprint(qs, "seed_artists=", strings.Join(artists, ","))
+			if recommendationIntAttributes[name] && v != math.Trunc(v) {
+				return errors.New(fmt.Sprint(prefix, name, " must be a whole number, got ", v))
+			}
+		}
 	}
 
-	if tracks, ok := seeds[KeySeedTracks]; ok {
-		if len(qs) > 1 {
-			qs += "&"
+	for name, min := range r.Min {
+		if max, ok := r.Max[name]; ok && min > max {
+			return errors.New(fmt.Sprint("min_", name, " can't be more than max_", name))
+		}
+
+		if target, ok := r.Target[name]; ok && target < min {
+			return errors.New(fmt.Sprint("target_", name, " can't be less than min_", name))
 		}
+	}
 
-		qs = fmt.Sprint(qs, "seed_tracks=", strings.Join(tracks, ","))
+	for name, max := range r.Max {
+		if target, ok := r.Target[name]; ok && target > max {
+			return errors.New(fmt.Sprint("target_", name, " can't be more than max_", name))
+		}
 	}
 
-	if genres, ok := seeds[KeySeedGenres]; ok {
-		if len(qs) > 1 {
-			qs += "&"
+	return nil
+}
+
+// Values encodes the request as query string values
+func (r *RecommendationRequest) Values() url.Values {
+	ret := url.Values{}
+	for k, v := range map[string][]string{
+		"seed_artists": r.SeedArtists,
+		"seed_tracks":  r.SeedTracks,
+		"seed_genres":  r.SeedGenres,
+	} {
+		if len(v) > 0 {
+			ret.Set(k, strings.Join(v, ","))
 		}
+	}
 
-		qs = fmt.Sprint(qs, "seed_genres=", strings.Join(genres, ","))
+	if r.Limit > 0 {
+		ret.Set("limit", strconv.Itoa(r.Limit))
 	}
-	return fmt.Sprint("https://api.spotify.com/v1/recommendations", qs)
+
+	if len(r.Market) > 0 {
+		ret.Set("market", r.Market)
+	}
+
+	for prefix, vals := range map[string]map[string]float64{"min_": r.Min, "max_": r.Max, "target_": r.Target} {
+		for name, v := range vals {
+			ret.Set(prefix+name, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+func getRecommendationsURL(ctx context.Context, r RecommendationRequest) string {
+	return fmt.Sprint("https://api.spotify.com/v1/recommendations?", r.Values().Encode())
 }
 
-func parseRecommendationsRequest(ctx context.Context, seeds map[string][]string) (*http.Request, error) {
+func parseRecommendationsRequest(ctx context.Context, r RecommendationRequest) (*http.Request, error) {
 	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
 	if token == nil {
 		return nil, ErrNoToken("no access token provided")
 	}
 
-	url := getRecommendationsURL(ctx, seeds)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", getRecommendationsURL(ctx, r), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -12,7 +12,7 @@ import (
 )
 
 func TestGetRecommendations(t *testing.T) {
-	seeds := map[string][]string{KeySeedArtists: {"1234", "1235"}}
+	seeds := RecommendationRequest{SeedArtists: []string{"1234", "1235"}}
 	t.Run("TestParseRecommendationsRequest", func(t *testing.T) {
 		ctx := context.Background()
 		t.Run("no token", func(t *testing.T) {
@@ -51,15 +51,23 @@ func TestGetRecommendations(t *testing.T) {
 			ctx := getTestDependencies(context.Background(), 200, "{}")
 			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
 
-			_, err := GetRecommendations(ctx, map[string][]string{"test": {"test"}})
+			_, err := GetRecommendations(ctx, RecommendationRequest{SeedTracks: []string{"test"}})
 			assert.Equal(t, nil, err)
 		})
 
+		t.Run("InvalidRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 200, "{}")
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetRecommendations(ctx, RecommendationRequest{})
+			assert.NotNil(t, err)
+		})
+
 		t.Run("BadRequest", func(t *testing.T) {
 			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
 			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
 
-			_, err := GetRecommendations(ctx, map[string][]string{"test": {"Test"}})
+			_, err := GetRecommendations(ctx, RecommendationRequest{SeedTracks: []string{"Test"}})
 			assert.NotEqual(t, nil, err)
 		})
 	})
@@ -69,19 +77,56 @@ func TestGetRecommendationsURL(t *testing.T) {
 	url := "https://api.spotify.com/v1/recommendations"
 	ids := []string{"ids1", "ids2"}
 	t.Run("TestArtists", func(t *testing.T) {
-		exp := fmt.Sprint(url, "?seed_artists=", strings.Join(ids, ","))
-		assert.Equal(t, exp, getRecommendationsURL(context.Background(), map[string][]string{KeySeedArtists: {ids[0], ids[1]}}))
+		exp := fmt.Sprint(url, "?seed_artists=", strings.Join(ids, "%2C"))
+		assert.Equal(t, exp, getRecommendationsURL(context.Background(), RecommendationRequest{SeedArtists: ids}))
 	})
 	t.Run("TestTracks", func(t *testing.T) {
-		exp := fmt.Sprint(url, "?seed_tracks=", strings.Join(ids, ","))
-		assert.Equal(t, exp, getRecommendationsURL(context.Background(), map[string][]string{KeySeedTracks: {ids[0], ids[1]}}))
+		exp := fmt.Sprint(url, "?seed_tracks=", strings.Join(ids, "%2C"))
+		assert.Equal(t, exp, getRecommendationsURL(context.Background(), RecommendationRequest{SeedTracks: ids}))
 	})
 	t.Run("TestGenres", func(t *testing.T) {
-		exp := fmt.Sprint(url, "?seed_genres=", strings.Join(ids, ","))
-		assert.Equal(t, exp, getRecommendationsURL(context.Background(), map[string][]string{KeySeedGenres: {ids[0], ids[1]}}))
+		exp := fmt.Sprint(url, "?seed_genres=", strings.Join(ids, "%2C"))
+		assert.Equal(t, exp, getRecommendationsURL(context.Background(), RecommendationRequest{SeedGenres: ids}))
+	})
+	t.Run("TestTunables", func(t *testing.T) {
+		r := RecommendationRequest{
+			SeedGenres: []string{"r&b"},
+			Limit:      10,
+			Market:     "US",
+			Min:        map[string]float64{"popularity": 20},
+			Max:        map[string]float64{"energy": 0.4},
+			Target:     map[string]float64{"tempo": 92.5},
+		}
+		exp := fmt.Sprint(url, "?limit=10&market=US&max_energy=0.4&min_popularity=20&seed_genres=r%26b&target_tempo=92.5")
+		assert.Equal(t, exp, getRecommendationsURL(context.Background(), r))
 	})
 }
 
+func TestRecommendationRequestValidate(t *testing.T) {
+	seeds := []string{"1"}
+	for name, tc := range map[string]struct {
+		req RecommendationRequest
+		ok  bool
+	}{
+		"Valid":            {RecommendationRequest{SeedArtists: seeds, Min: map[string]float64{"energy": 0.2}, Max: map[string]float64{"energy": 0.6}, Target: map[string]float64{"energy": 0.4}}, true},
+		"NoSeeds":          {RecommendationRequest{}, false},
+		"TooManySeeds":     {RecommendationRequest{SeedArtists: []string{"1", "2", "3"}, SeedTracks: []string{"4", "5", "6"}}, false},
+		"LimitTooHigh":     {RecommendationRequest{SeedArtists: seeds, Limit: 101}, false},
+		"UnknownAttr":      {RecommendationRequest{SeedArtists: seeds, Target: map[string]float64{"cowbell": 1}}, false},
+		"OutOfRange":       {RecommendationRequest{SeedArtists: seeds, Max: map[string]float64{"valence": 1.5}}, false},
+		"NotWholeNumber":   {RecommendationRequest{SeedArtists: seeds, Min: map[string]float64{"popularity": 20.5}}, false},
+		"MinAboveMax":      {RecommendationRequest{SeedArtists: seeds, Min: map[string]float64{"energy": 0.8}, Max: map[string]float64{"energy": 0.2}}, false},
+		"TargetBelowMin":   {RecommendationRequest{SeedArtists: seeds, Min: map[string]float64{"tempo": 100}, Target: map[string]float64{"tempo": 90}}, false},
+		"TargetAboveMax":   {RecommendationRequest{SeedArtists: seeds, Max: map[string]float64{"tempo": 100}, Target: map[string]float64{"tempo": 110}}, false},
+		"NegativeLoudness": {RecommendationRequest{SeedArtists: seeds, Target: map[string]float64{"loudness": -8}}, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.req.Validate()
+			assert.Equal(t, tc.ok, err == nil, err)
+		})
+	}
+}
+
 var (
 	getRecommendationsPayload = `{
 		"tracks": [
@@ -0,0 +1,183 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/keys"
+)
+
+const (
+	SearchTypeTrack    = "track"
+	SearchTypeArtist   = "artist"
+	SearchTypeAlbum    = "album"
+	SearchTypePlaylist = "playlist"
+
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+	searchMaxOffset    = 1000
+)
+
+// SearchOptions holds the optional values for a catalogue search
+type SearchOptions struct {
+	// Types is the kinds of items to search for, defaults to tracks
+	Types []string
+	// Limit is the number of items to return per type, defaults to 20
+	Limit int
+	// Offset is the index of the first item to return per type
+	Offset int
+	// Market is an ISO 3166-1 alpha-2 country code or "from_token"
+	Market string
+}
+
+// SearchPage holds the paging information for a set of search results
+type SearchPage struct {
+	Link   string `json:"href"`
+	Limit  int    `json:"limit"`
+	Next   string `json:"next"`
+	Offset int    `json:"offset"`
+	Total  int    `json:"total"`
+}
+
+// TrackResults holds the tracks found by a search
+type TrackResults struct {
+	SearchPage
+	Items Tracks `json:"items"`
+}
+
+// ArtistResults holds the artists found by a search
+type ArtistResults struct {
+	SearchPage
+	Items Artists `json:"items"`
+}
+
+// AlbumResults holds the albums found by a search
+type AlbumResults struct {
+	SearchPage
+	Items []Album `json:"items"`
+}
+
+// PlaylistResults holds the playlists found by a search
+type PlaylistResults struct {
+	SearchPage
+	Items Playlists `json:"items"`
+}
+
+// SearchResults holds the results of a search. Only the types that were
+// searched for will be populated.
+type SearchResults struct {
+	Tracks    *TrackResults    `json:"tracks,omitempty"`
+	Artists   *ArtistResults   `json:"artists,omitempty"`
+	Albums    *AlbumResults    `json:"albums,omitempty"`
+	Playlists *PlaylistResults `json:"playlists,omitempty"`
+}
+
+// ----
+// API
+// ----
+
+// Search will look through the spotify catalogue for items matching the
+// query.
+func Search(ctx context.Context, query string, opts SearchOptions) (*SearchResults, error) {
+	req, err := parseRequestForSearch(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponseForSearch(body)
+}
+
+// ----
+// Members
+// ----
+
+// Validate will make sure the options can be sent to spotify, filling in
+// the defaults for anything that wasn't provided.
+func (o *SearchOptions) Validate() error {
+	if len(o.Types) < 1 {
+		o.Types = []string{SearchTypeTrack}
+	}
+
+	for _, i := range o.Types {
+		switch i {
+		case SearchTypeTrack, SearchTypeArtist, SearchTypeAlbum, SearchTypePlaylist:
+		default:
+			return errors.New(fmt.Sprint("unsupported search type: ", i))
+		}
+	}
+
+	if o.Limit == 0 {
+		o.Limit = searchDefaultLimit
+	}
+
+	if o.Limit < 1 || o.Limit > searchMaxLimit {
+		return errors.New(fmt.Sprint("limit must be between 1 and ", searchMaxLimit))
+	}
+
+	if o.Offset < 0 || o.Offset > searchMaxOffset {
+		return errors.New(fmt.Sprint("offset must be between 0 and ", searchMaxOffset))
+	}
+
+	if len(o.Market) > 0 && len(o.Market) != 2 && o.Market != "from_token" {
+		return errors.New(fmt.Sprint("invalid market: ", o.Market))
+	}
+
+	return nil
+}
+
+// ----
+// Helpers
+// ----
+
+func parseRequestForSearch(ctx context.Context, query string, opts SearchOptions) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	if len(strings.TrimSpace(query)) < 1 {
+		return nil, errors.New("no search query provided")
+	}
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	qs := url.Values{}
+	qs.Set("q", query)
+	qs.Set("type", strings.Join(opts.Types, ","))
+	qs.Set("limit", fmt.Sprint(opts.Limit))
+	qs.Set("offset", fmt.Sprint(opts.Offset))
+	if len(opts.Market) > 0 {
+		qs.Set("market", opts.Market)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprint("https://api.spotify.com/v1/search?", qs.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseResponseForSearch(body *[]byte) (*SearchResults, error) {
+	var ret SearchResults
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
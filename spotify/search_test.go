@@ -0,0 +1,170 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	t.Run("TestParseRequestForSearch", func(t *testing.T) {
+		ctx := context.Background()
+		t.Run("no token", func(t *testing.T) {
+			_, err := parseRequestForSearch(ctx, "blink", SearchOptions{})
+			assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+		})
+
+		token := "tok"
+		ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, token)
+
+		t.Run("no query", func(t *testing.T) {
+			_, err := parseRequestForSearch(ctx, "  ", SearchOptions{})
+			assert.NotNil(t, err)
+		})
+
+		t.Run("defaults", func(t *testing.T) {
+			req, err := parseRequestForSearch(ctx, "blink 182", SearchOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, req.Header.Get("Authorization"), fmt.Sprint("Bearer ", token))
+
+			qs := req.URL.Query()
+			assert.Equal(t, "blink 182", qs.Get("q"))
+			assert.Equal(t, SearchTypeTrack, qs.Get("type"))
+			assert.Equal(t, "20", qs.Get("limit"))
+			assert.Equal(t, "0", qs.Get("offset"))
+			assert.Equal(t, "", qs.Get("market"))
+		})
+
+		t.Run("options", func(t *testing.T) {
+			req, err := parseRequestForSearch(ctx, `artist:"blink-182"`, SearchOptions{
+				Types:  []string{SearchTypeArtist, SearchTypeAlbum},
+				Limit:  5,
+				Offset: 10,
+				Market: "US",
+			})
+			assert.Nil(t, err)
+
+			qs := req.URL.Query()
+			assert.Equal(t, `artist:"blink-182"`, qs.Get("q"))
+			assert.Equal(t, "artist,album", qs.Get("type"))
+			assert.Equal(t, "5", qs.Get("limit"))
+			assert.Equal(t, "10", qs.Get("offset"))
+			assert.Equal(t, "US", qs.Get("market"))
+		})
+	})
+
+	t.Run("TestSearchOptionsValidate", func(t *testing.T) {
+		cases := map[string]SearchOptions{
+			"BadType":      {Types: []string{"show"}},
+			"LimitTooHigh": {Limit: searchMaxLimit + 1},
+			"LimitTooLow":  {Limit: -1},
+			"BadOffset":    {Offset: searchMaxOffset + 1},
+			"BadMarket":    {Market: "USA"},
+		}
+
+		for name, opts := range cases {
+			t.Run(name, func(t *testing.T) {
+				assert.NotNil(t, opts.Validate())
+			})
+		}
+	})
+
+	t.Run("TestParseResponseForSearch", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(searchPayload)
+
+			res, err := parseResponseForSearch(&bytes)
+			assert.Nil(t, err)
+			assert.Nil(t, res.Albums)
+			assert.Nil(t, res.Playlists)
+			assert.Equal(t, 1, len(res.Tracks.Items))
+			assert.Equal(t, "All The Small Things", res.Tracks.Items[0].Name)
+			assert.Equal(t, 128, res.Tracks.Total)
+			assert.Equal(t, "blink-182", res.Artists.Items[0].Name)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseResponseForSearch(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("MainMethod", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 200, searchPayload)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := Search(ctx, "blink", SearchOptions{})
+			assert.Equal(t, nil, err)
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := Search(ctx, "blink", SearchOptions{})
+			assert.NotEqual(t, nil, err)
+		})
+	})
+}
+
+var (
+	searchPayload = `{
+		"artists": {
+		  "href": "https://api.spotify.com/v1/search?query=blink&type=artist&offset=0&limit=1",
+		  "items": [
+			{
+			  "external_urls": {
+				"spotify": "https://open.spotify.com/artist/6FBDaR13swtiWwGhX1WQsP"
+			  },
+			  "genres": ["pop punk", "punk", "socal pop punk"],
+			  "id": "6FBDaR13swtiWwGhX1WQsP",
+			  "images": [],
+			  "name": "blink-182",
+			  "popularity": 81,
+			  "type": "artist",
+			  "uri": "spotify:artist:6FBDaR13swtiWwGhX1WQsP"
+			}
+		  ],
+		  "limit": 1,
+		  "next": "https://api.spotify.com/v1/search?query=blink&type=artist&offset=1&limit=1",
+		  "offset": 0,
+		  "previous": null,
+		  "total": 54
+		},
+		"tracks": {
+		  "href": "https://api.spotify.com/v1/search?query=blink&type=track&offset=0&limit=1",
+		  "items": [
+			{
+			  "album": {
+				"images": [],
+				"name": "Enema Of The State"
+			  },
+			  "artists": [
+				{
+				  "id": "6FBDaR13swtiWwGhX1WQsP",
+				  "name": "blink-182"
+				}
+			  ],
+			  "external_urls": {
+				"spotify": "https://open.spotify.com/track/2m1hi0nfMR9vdGC8UcrnwU"
+			  },
+			  "id": "2m1hi0nfMR9vdGC8UcrnwU",
+			  "name": "All The Small Things",
+			  "popularity": 82,
+			  "uri": "spotify:track:2m1hi0nfMR9vdGC8UcrnwU"
+			}
+		  ],
+		  "limit": 1,
+		  "next": "https://api.spotify.com/v1/search?query=blink&type=track&offset=1&limit=1",
+		  "offset": 0,
+		  "previous": null,
+		  "total": 128
+		}
+	  }`
+)
@@ -0,0 +1,216 @@
+package spotify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	DefaultShareExpiry = 30 * 24 * time.Hour
+	MaxShareExpiry     = 365 * 24 * time.Hour
+
+	// shareSlugBytes is how much randomness is in a slug, so they can't be
+	// guessed or walked
+	shareSlugBytes = 16
+)
+
+var (
+	ErrShareNotFound = errors.New("share not found")
+)
+
+// ShareItem is a track in a share, with just enough to show it
+type ShareItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Artist string `json:"artist"`
+	Image  string `json:"image,omitempty"`
+}
+
+// ShareSnapshot is a frozen copy of a user's results. Anything that
+// couldn't be retrieved is left empty.
+type ShareSnapshot struct {
+	TimeRange string          `json:"time_range"`
+	TopTracks []ShareItem     `json:"top_tracks"`
+	TopGenres sortablemap.Map `json:"top_genres"`
+	WordCloud sortablemap.Map `json:"word_cloud"`
+	Obscurity *float64        `json:"obscurity"`
+}
+
+// Share is a snapshot anyone with the slug can view, until it expires or
+// the user revokes it.
+type Share struct {
+	Slug      string        `json:"slug"`
+	UserID    string        `json:"-"`
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Snapshot  ShareSnapshot `json:"snapshot"`
+}
+
+// ----
+// API
+// ----
+
+// NewShareItems keeps the details of the tracks a share needs
+func NewShareItems(trax Tracks) []ShareItem {
+	ret := []ShareItem{}
+	for _, t := range trax {
+		ret = append(ret, ShareItem{ID: t.ID, Name: t.Name, Artist: t.FindArtist(), Image: t.FindImage().URL})
+	}
+
+	return ret
+}
+
+// ShareExpiry returns how long a share should last for the requested
+// number of hours. Zero uses the default.
+func ShareExpiry(hours int) (time.Duration, error) {
+	if hours == 0 {
+		return DefaultShareExpiry, nil
+	}
+
+	ret := time.Duration(hours) * time.Hour
+	if ret < 0 || ret > MaxShareExpiry {
+		return 0, errors.New(fmt.Sprint("expiry must be between 1 and ", int(MaxShareExpiry.Hours()), " hours, got ", hours))
+	}
+
+	return ret, nil
+}
+
+// CreateShare stores the snapshot under a new slug that's viewable until
+// the expiry has passed
+func CreateShare(ctx context.Context, userID string, s ShareSnapshot, expiry time.Duration) (*Share, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	slug, err := newShareSlug()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	ret := Share{Slug: slug, UserID: userID, CreatedAt: now, ExpiresAt: now.Add(expiry), Snapshot: s}
+	b, err := json.Marshal(ret)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = deps.DB.Exec(ctx, `INSERT INTO shares (slug, spotify_id, share, expires_at) VALUES (?, ?, ?, ?)`,
+		ret.Slug, ret.UserID, string(b), ret.ExpiresAt.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// GetShare returns the share for the slug, as long as it hasn't expired or
+// been revoked
+func GetShare(ctx context.Context, slug string) (*Share, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	rows := []string{}
+	err := deps.DB.Select(ctx, &rows, `SELECT share FROM shares WHERE slug = ? AND revoked = 0
+		AND expires_at > UTC_TIMESTAMP()`, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, ErrShareNotFound
+	}
+
+	ret := Share{}
+	if err := json.Unmarshal([]byte(rows[0]), &ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// GetUserShares returns the user's shares that can still be viewed, newest
+// first
+func GetUserShares(ctx context.Context, userID string) ([]Share, error) {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return nil, errors.New(ErrMissingDeps)
+	}
+
+	rows := []string{}
+	err := deps.DB.Select(ctx, &rows, `SELECT share FROM shares WHERE spotify_id = ? AND revoked = 0
+		AND expires_at > UTC_TIMESTAMP() ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Share{}
+	for _, r := range rows {
+		s := Share{}
+		if err := json.Unmarshal([]byte(r), &s); err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+
+	return ret, nil
+}
+
+// RevokeShare stops the share from being viewed. Only the user that
+// created it can revoke it, and revoking it again does nothing.
+func RevokeShare(ctx context.Context, userID string, slug string) error {
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	res, err := deps.DB.Exec(ctx, `UPDATE shares SET revoked = 1 WHERE slug = ? AND spotify_id = ?`, slug, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	// nothing changed, either because it's already revoked or it isn't
+	// the user's share
+	slugs := []string{}
+	err = deps.DB.Select(ctx, &slugs, `SELECT slug FROM shares WHERE slug = ? AND spotify_id = ?`, slug, userID)
+	if err != nil {
+		return err
+	}
+
+	if len(slugs) < 1 {
+		return ErrShareNotFound
+	}
+
+	return nil
+}
+
+// ----
+// Helpers
+// ----
+
+func newShareSlug() (string, error) {
+	b := make([]byte, shareSlugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,88 @@
+package spotify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mike-webster/spotify-views/data"
+	"github.com/mike-webster/spotify-views/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareExpiry(t *testing.T) {
+	d, err := ShareExpiry(0)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultShareExpiry, d)
+
+	d, err = ShareExpiry(48)
+	assert.Nil(t, err)
+	assert.Equal(t, 48*time.Hour, d)
+
+	for _, h := range []int{-1, 365*24 + 1} {
+		_, err := ShareExpiry(h)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestNewShareItems(t *testing.T) {
+	trax := Tracks{{
+		ID:      "1",
+		Name:    "Dammit",
+		Artists: []Artist{{Name: "blink-182"}},
+		Album:   Album{Images: []Image{{URL: "big"}, {URL: "medium"}}},
+	}}
+
+	assert.Equal(t, []ShareItem{{ID: "1", Name: "Dammit", Artist: "blink-182", Image: "medium"}}, NewShareItems(trax))
+}
+
+func TestShare(t *testing.T) {
+	db := &data.TestDB{Affected: 1}
+	ctx := context.WithValue(context.Background(), keys.ContextDependencies, &Dependencies{DB: db})
+
+	score := 42.0
+	snapshot := ShareSnapshot{TimeRange: "short_term", TopTracks: []ShareItem{{ID: "1"}}, Obscurity: &score}
+	s, err := CreateShare(ctx, "user", snapshot, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 22, len(s.Slug))
+	assert.Equal(t, time.Hour, s.ExpiresAt.Sub(s.CreatedAt))
+	assert.Equal(t, "user", db.Args[1])
+
+	// the share is read back the way it was stored
+	db.Rows = map[string]interface{}{
+		s.Slug:           []string{db.Args[2].(string)},
+		s.Slug + " user": []string{s.Slug},
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		ret, err := GetShare(ctx, s.Slug)
+		assert.Nil(t, err)
+		assert.Equal(t, s.Slug, ret.Slug)
+		assert.Equal(t, snapshot, ret.Snapshot)
+		// who made the share isn't public
+		assert.Equal(t, "", ret.UserID)
+
+		_, err = GetShare(ctx, "nope")
+		assert.Equal(t, ErrShareNotFound, err)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		assert.Nil(t, RevokeShare(ctx, "user", s.Slug))
+		assert.Equal(t, []interface{}{s.Slug, "user"}, db.Args)
+
+		// it's already revoked, so nothing changes but it still succeeds
+		db.Affected = 0
+		assert.Nil(t, RevokeShare(ctx, "user", s.Slug))
+
+		// someone else's share, or one that doesn't exist
+		assert.Equal(t, ErrShareNotFound, RevokeShare(ctx, "other", s.Slug))
+		assert.Equal(t, ErrShareNotFound, RevokeShare(ctx, "user", "nope"))
+		db.Affected = 1
+	})
+
+	t.Run("SlugsAreUnique", func(t *testing.T) {
+		other, err := CreateShare(ctx, "user", snapshot, time.Hour)
+		assert.Nil(t, err)
+		assert.NotEqual(t, s.Slug, other.Slug)
+	})
+}
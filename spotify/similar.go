@@ -0,0 +1,172 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	// featureRanges are the bounds spotify documents for the features that
+	// aren't already on a 0-1 scale
+	featureRanges = map[string][2]float64{
+		FeatureLoudness: {-60, 0},
+		FeatureTempo:    {0, 250},
+	}
+)
+
+// SimilarityWeights controls how much each audio feature, and the genres
+// the tracks share, count towards how similar two tracks are. The feature
+// weights are relative to each other, and Genres is relative to all of the
+// features combined.
+type SimilarityWeights struct {
+	Features map[string]float64 `json:"features"`
+	Genres   float64            `json:"genres"`
+}
+
+// SimilarityTarget is the track other tracks are compared against
+type SimilarityTarget struct {
+	Track    Track
+	Features AudioFeature
+	Genres   []string
+}
+
+// SimilarTrack is a track along with how closely it matches the target
+type SimilarTrack struct {
+	Track             Track    `json:"track"`
+	Score             float64  `json:"score"`
+	FeatureSimilarity float64  `json:"feature_similarity"`
+	GenreSimilarity   float64  `json:"genre_similarity"`
+	SharedGenres      []string `json:"shared_genres"`
+}
+
+// ----
+// Members
+// ----
+
+// DefaultSimilarityWeights weighs every feature evenly, with shared genres
+// counting as much as all of the features together.
+func DefaultSimilarityWeights() SimilarityWeights {
+	ret := SimilarityWeights{Features: map[string]float64{}, Genres: 1}
+	for _, f := range ProfileFeatures {
+		ret.Features[f] = 1
+	}
+	return ret
+}
+
+// Validate makes sure the weights can be used to score tracks
+func (w SimilarityWeights) Validate() error {
+	total := w.Genres
+	for f, v := range w.Features {
+		if _, err := (&AudioFeature{}).Feature(f); err != nil {
+			return err
+		}
+
+		if v < 0 {
+			return errors.New(fmt.Sprint("weight for ", f, " must be positive, got ", v))
+		}
+		total += v
+	}
+
+	if w.Genres < 0 {
+		return errors.New(fmt.Sprint("genre weight must be positive, got ", w.Genres))
+	}
+
+	if total == 0 {
+		return errors.New("at least one weight is required")
+	}
+
+	return nil
+}
+
+// Compare scores how similar the given track is to the target, from 0 to 1
+func (s SimilarityTarget) Compare(t Track, f AudioFeature, genres []string, w SimilarityWeights) SimilarTrack {
+	ret := SimilarTrack{Track: t, SharedGenres: []string{}}
+
+	featureTotal := 0.0
+	sum := 0.0
+	for name, weight := range w.Features {
+		a, _ := s.Features.Feature(name)
+		b, _ := f.Feature(name)
+		d := normalizeFeature(name, a) - normalizeFeature(name, b)
+		sum += weight * d * d
+		featureTotal += weight
+	}
+
+	if featureTotal > 0 {
+		ret.FeatureSimilarity = 1 - math.Sqrt(sum/featureTotal)
+	}
+
+	found := map[string]bool{}
+	for _, g := range s.Genres {
+		found[g] = true
+	}
+
+	union := len(s.Genres)
+	for _, g := range genres {
+		if found[g] {
+			ret.SharedGenres = append(ret.SharedGenres, g)
+			continue
+		}
+		union++
+	}
+
+	if union > 0 {
+		ret.GenreSimilarity = float64(len(ret.SharedGenres)) / float64(union)
+	}
+
+	featureWeight := 0.0
+	if featureTotal > 0 {
+		featureWeight = 1
+	}
+
+	if featureWeight+w.Genres > 0 {
+		ret.Score = (featureWeight*ret.FeatureSimilarity + w.Genres*ret.GenreSimilarity) / (featureWeight + w.Genres)
+	}
+
+	return ret
+}
+
+// FindSimilarTracks returns up to limit of the candidates that are most
+// similar to the target. The target itself, and any tracks without audio
+// features, are skipped.
+func FindSimilarTracks(target SimilarityTarget, candidates Tracks, af AudioFeatures, genres map[string][]string, w SimilarityWeights, limit int) []SimilarTrack {
+	features := af.ByID()
+	found := map[string]bool{target.Track.ID: true}
+	ret := []SimilarTrack{}
+	for _, t := range candidates {
+		f, ok := features[t.ID]
+		if !ok || found[t.ID] {
+			continue
+		}
+
+		found[t.ID] = true
+		ret = append(ret, target.Compare(t, f, genres[t.ID], w))
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret
+}
+
+// ----
+// Helpers
+// ----
+
+// normalizeFeature scales a feature value to 0-1 using spotify's documented
+// range for it, so it can be compared to the other features.
+func normalizeFeature(name string, v float64) float64 {
+	r, ok := featureRanges[name]
+	if !ok {
+		return v
+	}
+
+	return math.Max(0, math.Min(1, (v-r[0])/(r[1]-r[0])))
+}
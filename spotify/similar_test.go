@@ -0,0 +1,74 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarityWeights(t *testing.T) {
+	assert.Nil(t, DefaultSimilarityWeights().Validate())
+	assert.NotNil(t, SimilarityWeights{Features: map[string]float64{"cowbell": 1}}.Validate())
+	assert.NotNil(t, SimilarityWeights{Features: map[string]float64{FeatureEnergy: -1}}.Validate())
+	assert.NotNil(t, SimilarityWeights{Genres: -1, Features: map[string]float64{FeatureEnergy: 1}}.Validate())
+	assert.NotNil(t, SimilarityWeights{}.Validate())
+}
+
+func TestSimilarityCompare(t *testing.T) {
+	target := SimilarityTarget{
+		Track:    Track{ID: "target"},
+		Features: AudioFeature{Energy: 0.8, Tempo: 150},
+		Genres:   []string{"punk", "pop punk"},
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		res := target.Compare(Track{ID: "1"}, target.Features, target.Genres, DefaultSimilarityWeights())
+		assert.Equal(t, 1.0, res.Score)
+		assert.Equal(t, []string{"punk", "pop punk"}, res.SharedGenres)
+	})
+
+	t.Run("FeaturesOnly", func(t *testing.T) {
+		w := SimilarityWeights{Features: map[string]float64{FeatureEnergy: 1}}
+		res := target.Compare(Track{ID: "1"}, AudioFeature{Energy: 0.4}, []string{"folk"}, w)
+		assert.InDelta(t, 0.6, res.Score, 0.0001)
+		assert.Equal(t, 0.0, res.GenreSimilarity)
+	})
+
+	t.Run("GenresOnly", func(t *testing.T) {
+		w := SimilarityWeights{Genres: 1}
+		res := target.Compare(Track{ID: "1"}, AudioFeature{}, []string{"punk", "emo"}, w)
+		assert.InDelta(t, 1.0/3.0, res.Score, 0.0001)
+		assert.Equal(t, []string{"punk"}, res.SharedGenres)
+	})
+
+	t.Run("TempoIsNormalized", func(t *testing.T) {
+		w := SimilarityWeights{Features: map[string]float64{FeatureTempo: 1}}
+		res := target.Compare(Track{ID: "1"}, AudioFeature{Tempo: 100}, nil, w)
+		assert.InDelta(t, 0.8, res.Score, 0.0001)
+	})
+}
+
+func TestFindSimilarTracks(t *testing.T) {
+	target := SimilarityTarget{
+		Track:    Track{ID: "target"},
+		Features: AudioFeature{ID: "target", Energy: 0.8},
+		Genres:   []string{"punk"},
+	}
+	candidates := Tracks{{ID: "target"}, {ID: "far"}, {ID: "close"}, {ID: "close"}, {ID: "missing"}}
+	af := AudioFeatures{
+		{ID: "target", Energy: 0.8},
+		{ID: "far", Energy: 0.1},
+		{ID: "close", Energy: 0.7},
+	}
+	genres := map[string][]string{"close": {"punk"}, "far": {"folk"}}
+
+	res := FindSimilarTracks(target, candidates, af, genres, DefaultSimilarityWeights(), 10)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, "close", res[0].Track.ID)
+	assert.Equal(t, "far", res[1].Track.ID)
+
+	t.Run("Limit", func(t *testing.T) {
+		res := FindSimilarTracks(target, candidates, af, genres, DefaultSimilarityWeights(), 1)
+		assert.Equal(t, 1, len(res))
+	})
+}
@@ -0,0 +1,192 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+)
+
+const (
+	GenreLevelParent = "parent"
+	GenreLevelMicro  = "micro"
+
+	// GenreOther is the parent for micro genres we can't place
+	GenreOther = "other"
+)
+
+var (
+	// curatedGenres are the micro genres the token heuristics get wrong, or
+	// that don't contain a parent genre's name at all
+	curatedGenres = map[string]string{
+		"adult standards":    "jazz",
+		"alternative":        "rock",
+		"rap metal":          "metal",
+		"rap rock":           "rock",
+		"nu metal":           "metal",
+		"trap latino":        "latin",
+		"latin pop":          "latin",
+		"escape room":        "hip hop",
+		"lo-fi beats":        "hip hop",
+		"electropop":         "pop",
+		"hyperpop":           "pop",
+		"neo mellow":         "pop",
+		"boy band":           "pop",
+		"girl group":         "pop",
+		"permanent wave":     "rock",
+		"new wave":           "rock",
+		"mellow gold":        "rock",
+		"post-grunge":        "rock",
+		"stomp and holler":   "folk",
+		"urban contemporary": "r&b",
+		"quiet storm":        "r&b",
+		"new jack swing":     "r&b",
+		"big room":           "electronic",
+		"brostep":            "electronic",
+		"chillwave":          "electronic",
+		"vaporwave":          "electronic",
+		"downtempo":          "electronic",
+	}
+
+	// genreTokens are checked in order against the words in a micro genre,
+	// so "pop punk" is punk before it's pop
+	genreTokens = []struct {
+		Parent string
+		Tokens []string
+	}{
+		{"hip hop", []string{"hip hop", "rap", "trap", "drill", "grime", "boom bap"}},
+		{"metal", []string{"metal", "metalcore", "deathcore", "djent", "thrash", "doom"}},
+		{"punk", []string{"punk", "emo", "screamo", "hardcore", "post hardcore"}},
+		{"r&b", []string{"r&b", "soul", "funk", "motown", "neo soul"}},
+		{"electronic", []string{"edm", "house", "techno", "trance", "dubstep", "electro", "electronica", "electronic", "drum and bass", "dnb", "ambient", "idm", "synthwave", "uk garage"}},
+		{"country", []string{"country", "bluegrass", "americana", "honky tonk"}},
+		{"folk", []string{"folk", "singer songwriter", "acoustic"}},
+		{"jazz", []string{"jazz", "bebop", "swing", "bossa nova"}},
+		{"blues", []string{"blues"}},
+		{"classical", []string{"classical", "orchestra", "baroque", "opera", "choral", "romantic era"}},
+		{"reggae", []string{"reggae", "dancehall", "ska", "dub"}},
+		{"latin", []string{"latin", "latino", "reggaeton", "salsa", "bachata", "cumbia", "mexican", "corrido", "banda"}},
+		{"rock", []string{"rock", "grunge", "shoegaze", "britpop", "psychedelic", "garage rock"}},
+		{"pop", []string{"pop"}},
+		{"indie", []string{"indie"}},
+	}
+)
+
+// GenreNode is a parent genre along with the micro genres rolled up into it
+type GenreNode struct {
+	Name  string          `json:"name"`
+	Count int32           `json:"count"`
+	Micro sortablemap.Map `json:"micro"`
+}
+
+// GenreHierarchy is every parent genre, most common first
+type GenreHierarchy []GenreNode
+
+// ----
+// API
+// ----
+
+// ParentGenre returns the parent genre for the micro genre, using the
+// curated mapping first and then looking for a parent's words in it.
+func ParentGenre(micro string) string {
+	g := strings.ToLower(strings.TrimSpace(micro))
+	if p, ok := curatedGenres[g]; ok {
+		return p
+	}
+
+	words := fmt.Sprint(" ", strings.ReplaceAll(g, "-", " "), " ")
+	for _, i := range genreTokens {
+		for _, t := range i.Tokens {
+			if strings.Contains(words, fmt.Sprint(" ", t, " ")) {
+				return i.Parent
+			}
+		}
+	}
+
+	return GenreOther
+}
+
+// RollUpGenres groups the artists' micro genres under their parent genres.
+// A micro genre's count is how many artists have it. A parent's count is how
+// many artists have any of its micro genres, so an artist tagged with
+// several micro genres from the same parent only counts once.
+func RollUpGenres(artists Artists) GenreHierarchy {
+	index := map[string]int{}
+	ret := GenreHierarchy{}
+	for _, a := range artists {
+		counted := map[string]bool{}
+		for _, g := range a.Genres {
+			p := ParentGenre(g)
+			if _, ok := index[p]; !ok {
+				index[p] = len(ret)
+				ret = append(ret, GenreNode{Name: p, Micro: sortablemap.Map{}})
+			}
+
+			n := &ret[index[p]]
+			if i := n.Micro.Contains(g); i < 0 {
+				n.Micro = append(n.Micro, sortablemap.Item{Key: g, Value: 1})
+			} else {
+				n.Micro[i].Value++
+			}
+
+			if !counted[p] {
+				counted[p] = true
+				n.Count++
+			}
+		}
+	}
+
+	for _, n := range ret {
+		sortGenres(n.Micro)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Count == ret[j].Count {
+			return ret[i].Name < ret[j].Name
+		}
+		return ret[i].Count > ret[j].Count
+	})
+
+	return ret
+}
+
+// ----
+// Members
+// ----
+
+// Level flattens the hierarchy into the counts for the given level
+func (g GenreHierarchy) Level(level string) (sortablemap.Map, error) {
+	ret := sortablemap.Map{}
+	switch level {
+	case GenreLevelParent:
+		for _, n := range g {
+			ret = append(ret, sortablemap.Item{Key: n.Name, Value: n.Count})
+		}
+	case GenreLevelMicro:
+		for _, n := range g {
+			ret = append(ret, n.Micro...)
+		}
+	default:
+		return nil, errors.New(fmt.Sprint("unknown genre level: ", level))
+	}
+
+	sortGenres(ret)
+	return ret, nil
+}
+
+// ----
+// Helpers
+// ----
+
+// sortGenres sorts most common first, alphabetically for ties so the
+// order doesn't change between requests
+func sortGenres(m sortablemap.Map) {
+	sort.SliceStable(m, func(i, j int) bool {
+		if m[i].Value == m[j].Value {
+			return m[i].Key < m[j].Key
+		}
+		return m[i].Value > m[j].Value
+	})
+}
@@ -0,0 +1,80 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParentGenre(t *testing.T) {
+	for micro, parent := range map[string]string{
+		"pop punk":          "punk",
+		"modern pop punk":   "punk",
+		"socal pop punk":    "punk",
+		"post-hardcore":     "punk",
+		"pop rap":           "hip hop",
+		"rap metal":         "metal",
+		"dance pop":         "pop",
+		"k-pop":             "pop",
+		"indie pop":         "pop",
+		"indie rock":        "rock",
+		"bedroom indie":     "indie",
+		"singer-songwriter": "folk",
+		"alternative r&b":   "r&b",
+		"country rock":      "country",
+		"permanent wave":    "rock",
+		"Neo Mellow":        "pop",
+		"drum and bass":     "electronic",
+		"garage rock":       "rock",
+		"uk garage":         "electronic",
+		"popping":           GenreOther,
+		"vapor twitch":      GenreOther,
+	} {
+		assert.Equal(t, parent, ParentGenre(micro), micro)
+	}
+}
+
+func TestRollUpGenres(t *testing.T) {
+	artists := Artists{
+		{Name: "blink-182", Genres: []string{"pop punk", "socal pop punk", "modern pop punk"}},
+		{Name: "Neck Deep", Genres: []string{"pop punk", "modern pop punk"}},
+		{Name: "Green Day", Genres: []string{"pop punk", "socal pop punk", "dance pop"}},
+		{Name: "Dua Lipa", Genres: []string{"dance pop"}},
+		{Name: "BTS", Genres: []string{"k-pop"}},
+		{Name: "Lady Gaga", Genres: []string{"dance pop"}},
+		{Name: "Carly Rae Jepsen", Genres: []string{"dance pop"}},
+	}
+
+	// each punk artist counts once for punk, however many punk micro genres
+	// they're tagged with
+	h := RollUpGenres(artists)
+	assert.Equal(t, 2, len(h))
+	assert.Equal(t, "pop", h[0].Name)
+	assert.Equal(t, int32(5), h[0].Count)
+	assert.Equal(t, "punk", h[1].Name)
+	assert.Equal(t, int32(3), h[1].Count)
+	assert.Equal(t, sortablemap.Map{
+		{Key: "pop punk", Value: 3},
+		{Key: "modern pop punk", Value: 2},
+		{Key: "socal pop punk", Value: 2},
+	}, h[1].Micro)
+
+	t.Run("ParentLevel", func(t *testing.T) {
+		m, err := h.Level(GenreLevelParent)
+		assert.Nil(t, err)
+		assert.Equal(t, sortablemap.Map{{Key: "pop", Value: 5}, {Key: "punk", Value: 3}}, m)
+	})
+
+	t.Run("MicroLevel", func(t *testing.T) {
+		m, err := h.Level(GenreLevelMicro)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(m))
+		assert.Equal(t, "dance pop", m[0].Key)
+	})
+
+	t.Run("UnknownLevel", func(t *testing.T) {
+		_, err := h.Level("sub")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,226 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// rampWarmUp and rampCoolDown are the share of a ramp spent moving
+	// between the start and peak tempos
+	rampWarmUp   = 0.2
+	rampCoolDown = 0.2
+)
+
+// TempoFilter describes the tracks that fit a workout
+type TempoFilter struct {
+	MinBPM float32 `json:"min_bpm"`
+	// MaxBPM of zero means there's no upper limit
+	MaxBPM float32 `json:"max_bpm"`
+	// HalfDouble allows tracks at half or double the tempo to match
+	HalfDouble bool    `json:"half_double"`
+	MinEnergy  float32 `json:"min_energy"`
+	// TargetDuration is the length of the workout in milliseconds. Zero means
+	// every matching track is used.
+	TargetDuration int64 `json:"target_duration_ms"`
+}
+
+// TempoRamp describes a warm-up, peak and cool-down workout
+type TempoRamp struct {
+	StartBPM       float32 `json:"start_bpm"`
+	PeakBPM        float32 `json:"peak_bpm"`
+	HalfDouble     bool    `json:"half_double"`
+	MinEnergy      float32 `json:"min_energy"`
+	TargetDuration int64   `json:"target_duration_ms"`
+}
+
+// TempoTrack is a track picked for a workout. Tempo is the tempo the track
+// was matched at, which may be half or double what spotify reports.
+type TempoTrack struct {
+	Track    Track   `json:"track"`
+	Tempo    float32 `json:"tempo"`
+	Energy   float32 `json:"energy"`
+	Duration int64   `json:"duration_ms"`
+}
+
+// TempoTracks is a collection of TempoTracks
+type TempoTracks []TempoTrack
+
+// ----
+// Members
+// ----
+
+// Validate makes sure the filter describes a usable range
+func (f TempoFilter) Validate() error {
+	if f.MinBPM < 0 || f.MaxBPM < 0 {
+		return errors.New("bpm must be positive")
+	}
+
+	if f.MaxBPM > 0 && f.MaxBPM < f.MinBPM {
+		return errors.New(fmt.Sprint("max bpm ", f.MaxBPM, " is less than min bpm ", f.MinBPM))
+	}
+
+	if f.MinEnergy < 0 || f.MinEnergy > 1 {
+		return errors.New(fmt.Sprint("energy must be between 0 and 1, got ", f.MinEnergy))
+	}
+
+	if f.TargetDuration < 0 {
+		return errors.New("target duration must be positive")
+	}
+
+	return nil
+}
+
+// Match returns the tempo the track fits the filter at, and whether it fits
+func (f TempoFilter) Match(a AudioFeature) (float32, bool) {
+	if a.Energy < f.MinEnergy {
+		return 0, false
+	}
+
+	tempos := []float32{a.Tempo}
+	if f.HalfDouble {
+		tempos = append(tempos, a.Tempo*2, a.Tempo/2)
+	}
+
+	for _, t := range tempos {
+		if t < f.MinBPM {
+			continue
+		}
+
+		if f.MaxBPM > 0 && t > f.MaxBPM {
+			continue
+		}
+
+		return t, true
+	}
+
+	return 0, false
+}
+
+// Filter returns the tracks that match the filter, in the order given,
+// stopping once the target duration is reached.
+func (f TempoFilter) Filter(trax Tracks, af AudioFeatures) TempoTracks {
+	features := af.ByID()
+	ret := TempoTracks{}
+	for _, t := range trax {
+		if f.TargetDuration > 0 && ret.Duration() >= f.TargetDuration {
+			break
+		}
+
+		a, ok := features[t.ID]
+		if !ok {
+			continue
+		}
+
+		tempo, ok := f.Match(a)
+		if !ok {
+			continue
+		}
+
+		ret = append(ret, TempoTrack{Track: t, Tempo: tempo, Energy: a.Energy, Duration: a.Duration})
+	}
+
+	return ret
+}
+
+// Validate makes sure the ramp can be built
+func (r TempoRamp) Validate() error {
+	if r.StartBPM <= 0 || r.PeakBPM <= 0 {
+		return errors.New("start and peak bpm are required")
+	}
+
+	if r.PeakBPM < r.StartBPM {
+		return errors.New(fmt.Sprint("peak bpm ", r.PeakBPM, " is less than start bpm ", r.StartBPM))
+	}
+
+	if r.MinEnergy < 0 || r.MinEnergy > 1 {
+		return errors.New(fmt.Sprint("energy must be between 0 and 1, got ", r.MinEnergy))
+	}
+
+	if r.TargetDuration <= 0 {
+		return errors.New("target duration is required")
+	}
+
+	return nil
+}
+
+// TargetAt returns the tempo the ramp is aiming for at the given number of
+// milliseconds into the workout.
+func (r TempoRamp) TargetAt(elapsed int64) float32 {
+	progress := float64(elapsed) / float64(r.TargetDuration)
+	diff := float64(r.PeakBPM - r.StartBPM)
+
+	switch {
+	case progress < rampWarmUp:
+		return r.StartBPM + float32(diff*progress/rampWarmUp)
+	case progress > 1-rampCoolDown:
+		return r.PeakBPM - float32(diff*math.Min(1, (progress-(1-rampCoolDown))/rampCoolDown))
+	}
+
+	return r.PeakBPM
+}
+
+// Build picks the tracks for the ramp. At each point in the workout the
+// unused track closest to the target tempo is added, until the target
+// duration is reached or there are no tracks left.
+func (r TempoRamp) Build(trax Tracks, af AudioFeatures) TempoTracks {
+	features := af.ByID()
+	candidates := TempoTracks{}
+	for _, t := range trax {
+		a, ok := features[t.ID]
+		if !ok || a.Energy < r.MinEnergy {
+			continue
+		}
+
+		candidates = append(candidates, TempoTrack{Track: t, Tempo: a.Tempo, Energy: a.Energy, Duration: a.Duration})
+	}
+
+	ret := TempoTracks{}
+	for len(candidates) > 0 && ret.Duration() < r.TargetDuration {
+		target := r.TargetAt(ret.Duration())
+		best := -1
+		bestTempo := float32(0)
+		bestDiff := math.MaxFloat64
+		for i, c := range candidates {
+			tempos := []float32{c.Tempo}
+			if r.HalfDouble {
+				tempos = append(tempos, c.Tempo*2, c.Tempo/2)
+			}
+
+			for _, t := range tempos {
+				d := math.Abs(float64(t - target))
+				if d < bestDiff {
+					best = i
+					bestDiff = d
+					bestTempo = t
+				}
+			}
+		}
+
+		picked := candidates[best]
+		picked.Tempo = bestTempo
+		ret = append(ret, picked)
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+
+	return ret
+}
+
+// Duration returns the combined length of the tracks in milliseconds
+func (t *TempoTracks) Duration() int64 {
+	var ret int64
+	for _, i := range *t {
+		ret += i.Duration
+	}
+	return ret
+}
+
+// Tracks returns the spotify tracks in the order they were picked
+func (t *TempoTracks) Tracks() Tracks {
+	ret := Tracks{}
+	for _, i := range *t {
+		ret = append(ret, i.Track)
+	}
+	return ret
+}
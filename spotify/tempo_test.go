@@ -0,0 +1,86 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempoFilter(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		assert.Nil(t, TempoFilter{MinBPM: 120, MaxBPM: 140}.Validate())
+		assert.Nil(t, TempoFilter{MinBPM: 120}.Validate())
+		assert.NotNil(t, TempoFilter{MinBPM: 140, MaxBPM: 120}.Validate())
+		assert.NotNil(t, TempoFilter{MinEnergy: 2}.Validate())
+		assert.NotNil(t, TempoFilter{TargetDuration: -1}.Validate())
+	})
+
+	t.Run("Match", func(t *testing.T) {
+		f := TempoFilter{MinBPM: 150, MaxBPM: 170}
+		_, ok := f.Match(AudioFeature{Tempo: 80})
+		assert.False(t, ok)
+
+		f.HalfDouble = true
+		tempo, ok := f.Match(AudioFeature{Tempo: 80})
+		assert.True(t, ok)
+		assert.Equal(t, float32(160), tempo)
+
+		f.MinEnergy = 0.5
+		_, ok = f.Match(AudioFeature{Tempo: 160, Energy: 0.4})
+		assert.False(t, ok)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		trax := Tracks{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}}
+		af := AudioFeatures{
+			{ID: "1", Tempo: 160, Duration: 180000},
+			{ID: "2", Tempo: 100, Duration: 180000},
+			{ID: "3", Tempo: 165, Duration: 180000},
+			{ID: "4", Tempo: 155, Duration: 180000},
+		}
+
+		f := TempoFilter{MinBPM: 150, MaxBPM: 170}
+		res := f.Filter(trax, af)
+		assert.Equal(t, 3, len(res))
+		assert.Equal(t, int64(540000), res.Duration())
+
+		f.TargetDuration = 300000
+		res = f.Filter(trax, af)
+		tracks := res.Tracks()
+		assert.Equal(t, []string{"1", "3"}, tracks.IDs())
+	})
+}
+
+func TestTempoRamp(t *testing.T) {
+	r := TempoRamp{StartBPM: 100, PeakBPM: 160, TargetDuration: 1000}
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.Nil(t, r.Validate())
+		assert.NotNil(t, TempoRamp{StartBPM: 160, PeakBPM: 100, TargetDuration: 1}.Validate())
+		assert.NotNil(t, TempoRamp{StartBPM: 100, PeakBPM: 160}.Validate())
+	})
+
+	t.Run("TargetAt", func(t *testing.T) {
+		assert.Equal(t, float32(100), r.TargetAt(0))
+		assert.Equal(t, float32(130), r.TargetAt(100))
+		assert.Equal(t, float32(160), r.TargetAt(500))
+		assert.Equal(t, float32(130), r.TargetAt(900))
+		assert.Equal(t, float32(100), r.TargetAt(1000))
+	})
+
+	t.Run("Build", func(t *testing.T) {
+		trax := Tracks{{ID: "peak"}, {ID: "slow"}, {ID: "half"}, {ID: "cool"}}
+		af := AudioFeatures{
+			{ID: "peak", Tempo: 158, Duration: 300},
+			{ID: "slow", Tempo: 101, Duration: 200},
+			{ID: "half", Tempo: 81, Duration: 300},
+			{ID: "cool", Tempo: 120, Duration: 300},
+		}
+
+		r.HalfDouble = true
+		res := r.Build(trax, af)
+		tracks := res.Tracks()
+		assert.Equal(t, []string{"slow", "peak", "half", "cool"}, tracks.IDs())
+		assert.Equal(t, float32(162), res[2].Tempo)
+	})
+}
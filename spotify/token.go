@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/mike-webster/spotify-views/encrypt"
 	"github.com/mike-webster/spotify-views/keys"
 )
 
@@ -44,6 +45,29 @@ func ExchangeOauthCode(ctx context.Context, code string) (*Token, error) {
 	return parseTokensFromCodeSwapResponse(respBody)
 }
 
+// SaveRefreshToken stores the user's refresh token, encrypted with the
+// master key, so requests can be made for them while they aren't logged in.
+// It should only be saved once the user has consented to that.
+func SaveRefreshToken(ctx context.Context, userID string, refresh string) error {
+	if len(refresh) < 1 {
+		return errors.New("no refresh token provided")
+	}
+
+	deps := GetDependencies(ctx)
+	if deps == nil || deps.DB == nil {
+		return errors.New(ErrMissingDeps)
+	}
+
+	b, err := encrypt.Encrypt(ctx, []byte(refresh))
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.DB.Exec(ctx, `INSERT INTO tokens (spotify_id, refresh) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE refresh = VALUES(refresh)`, userID, base64.StdEncoding.EncodeToString(*b))
+	return err
+}
+
 // ----
 // Members
 // ----
@@ -64,7 +88,12 @@ func (t *Token) RefreshMe(ctx context.Context) (bool, error) {
 		return false, err
 	}
 
-	t.Access = tok
+	t.Access = tok.Access
+	// spotify only sometimes sends a new refresh token, and the old one
+	// stops working when it does
+	if len(tok.Refresh) > 0 {
+		t.Refresh = tok.Refresh
+	}
 	return true, nil
 }
 
@@ -72,17 +101,18 @@ func (t *Token) RefreshMe(ctx context.Context) (bool, error) {
 // Helpers
 // ----
 
-func parseTokenFromRefreshResponse(body *[]byte) (string, error) {
+func parseTokenFromRefreshResponse(body *[]byte) (*Token, error) {
 	type tempResp struct {
-		AccessToken string `json:"access_token"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	var b tempResp
 	err := json.Unmarshal(*body, &b)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return b.AccessToken, nil
+	return &Token{Access: b.AccessToken, Refresh: b.RefreshToken}, nil
 }
 
 func getRefreshRequest(ctx context.Context, refTok string) (*http.Request, error) {
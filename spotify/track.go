@@ -13,6 +13,7 @@ import (
 
 const (
 	fetchLimitTopTracks = 25
+	trackTypeTrack      = "track"
 )
 
 // Track represents a spotify track
@@ -24,6 +25,8 @@ type Track struct {
 	Popularity int64             `json:"popularity"`
 	Artists    []Artist          `json:"artists"`
 	Album      Album             `json:"album"`
+	Type       string            `json:"type"`
+	IsLocal    bool              `json:"is_local"`
 }
 
 // Tracks is a collection of spotify Tracks
@@ -47,6 +50,21 @@ func GetTopTracks(ctx context.Context, timeframe TimeFrame) (*Tracks, error) {
 	return parseTopTrackResponse(body)
 }
 
+// GetTrack will retrieve the track for the given id
+func GetTrack(ctx context.Context, id string) (*Track, error) {
+	req, err := getTrackRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTrackResponse(body)
+}
+
 func GetTopTracksForArtist(ctx context.Context, id string) (*Tracks, error) {
 	req, err := getTopTracksForArtistRequest(ctx, id)
 	if err != nil {
@@ -98,6 +116,31 @@ func parseTopTrackResponse(body *[]byte) (*Tracks, error) {
 	return &ret.Items, nil
 }
 
+func getTrackRequest(ctx context.Context, id string) (*http.Request, error) {
+	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
+	if token == nil {
+		return nil, ErrNoToken("no access token provided")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprint("https://api.spotify.com/v1/tracks/", id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	return req, nil
+}
+
+func parseTrackResponse(body *[]byte) (*Track, error) {
+	ret := Track{}
+	err := json.Unmarshal(*body, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
 func getTopTracksForArtistRequest(ctx context.Context, id string) (*http.Request, error) {
 	token := keys.GetContextValue(ctx, keys.ContextSpotifyAccessToken)
 	if token == nil {
@@ -131,7 +174,19 @@ func parseTopTracksForArtistResponse(body *[]byte) (*Tracks, error) {
 // Members
 // ----
 
+// GetGenres returns how many of the tracks' artists have each genre
 func (t *Tracks) GetGenres(ctx context.Context) (*sortablemap.Map, error) {
+	artists, err := t.GetArtists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return artists.GetGenres(ctx), nil
+}
+
+// GetArtists returns the full details of the tracks' artists, each artist
+// once
+func (t *Tracks) GetArtists(ctx context.Context) (*Artists, error) {
 	as := map[string]int32{}
 	aids := []string{}
 	ids := t.IDs()
@@ -150,24 +205,62 @@ func (t *Tracks) GetGenres(ctx context.Context) (*sortablemap.Map, error) {
 		return nil, errors.New(fmt.Sprint("no artists found for ", len(ids), "tracks"))
 	}
 
-	// go through artists to collect genres
-	artists, err := GetArtists(ctx, aids)
+	return GetArtists(ctx, aids)
+}
+
+// GetTrackGenres retrieves the artists for each of the tracks and returns
+// the genres for every track, keyed by the track id.
+func (t *Tracks) GetTrackGenres(ctx context.Context) (map[string][]string, error) {
+	artists, err := GetArtists(ctx, t.ArtistIDs())
 	if err != nil {
 		return nil, err
 	}
 
-	ret := map[string]int{}
-	for _, i := range *artists {
-		for _, ii := range i.Genres {
-			if _, ok := ret[ii]; ok {
-				ret[ii]++
-			} else {
-				ret[ii] = 1
+	return t.TrackGenres(*artists), nil
+}
+
+// ArtistIDs returns the unique ids for the artists on the tracks
+func (t *Tracks) ArtistIDs() []string {
+	found := map[string]bool{}
+	ret := []string{}
+	for _, i := range *t {
+		for _, a := range i.Artists {
+			if len(a.ID) < 1 || found[a.ID] {
+				continue
+			}
+
+			found[a.ID] = true
+			ret = append(ret, a.ID)
+		}
+	}
+	return ret
+}
+
+// TrackGenres combines the genres of each track's artists, keyed by the
+// track id. The artists should include everyone on the tracks.
+func (t *Tracks) TrackGenres(artists Artists) map[string][]string {
+	byArtist := map[string][]string{}
+	for _, a := range artists {
+		byArtist[a.ID] = a.Genres
+	}
+
+	ret := map[string][]string{}
+	for _, i := range *t {
+		found := map[string]bool{}
+		genres := []string{}
+		for _, a := range i.Artists {
+			for _, g := range byArtist[a.ID] {
+				if found[g] {
+					continue
+				}
+
+				found[g] = true
+				genres = append(genres, g)
 			}
 		}
+		ret[i.ID] = genres
 	}
-	retmap := sortablemap.GetSortableMap(ret)
-	return &retmap, nil
+	return ret
 }
 
 // EmbeddedPlayer will return the html to use for rendering the embedded spotify
@@ -185,6 +278,15 @@ func (t *Tracks) IDs() []string {
 	return ret
 }
 
+// URIs returns the URI for each of the tracks in the collection of Tracks
+func (t *Tracks) URIs() []string {
+	ret := []string{}
+	for _, i := range *t {
+		ret = append(ret, i.URI)
+	}
+	return ret
+}
+
 func (t *Track) FindArtist() string {
 	if len(t.Artists) < 1 {
 		return ""
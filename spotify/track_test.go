@@ -118,6 +118,61 @@ func TestTopTracksForArtists(t *testing.T) {
 	})
 }
 
+func TestGetTrack(t *testing.T) {
+	t.Run("TestGetTrackRequest", func(t *testing.T) {
+		ctx := context.Background()
+		t.Run("no token", func(t *testing.T) {
+			_, err := getTrackRequest(ctx, "1234")
+			assert.Equal(t, reflect.TypeOf(ErrNoToken("")), reflect.TypeOf(err))
+		})
+
+		token := "tok"
+		ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, token)
+
+		t.Run("token gets stored in header", func(t *testing.T) {
+			req, err := getTrackRequest(ctx, "1234")
+			assert.Nil(t, err)
+			assert.Equal(t, req.Header.Get("Authorization"), fmt.Sprint("Bearer ", token))
+			assert.Equal(t, "/v1/tracks/1234", req.URL.Path)
+		})
+	})
+
+	t.Run("TestParseTrackResponse", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			bytes := []byte(`{"id":"1234","name":"Dumpweed","artists":[{"id":"a","name":"blink-182"}]}`)
+
+			tr, err := parseTrackResponse(&bytes)
+			assert.Nil(t, err)
+			assert.Equal(t, "Dumpweed", tr.Name)
+			assert.Equal(t, "blink-182", tr.FindArtist())
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			bytes := []byte("fdakslfjda;klfjad;kjadl;")
+			_, err := parseTrackResponse(&bytes)
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("TestMainMethod", func(t *testing.T) {
+		t.Run("HappyPath", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 200, "{}")
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetTrack(ctx, "id")
+			assert.Equal(t, nil, err)
+		})
+
+		t.Run("BadRequest", func(t *testing.T) {
+			ctx := getTestDependencies(context.Background(), 400, `{"err":"bad_request"}`)
+			ctx = context.WithValue(ctx, keys.ContextSpotifyAccessToken, "test")
+
+			_, err := GetTrack(ctx, "id")
+			assert.NotEqual(t, nil, err)
+		})
+	})
+}
+
 func TestGetTrackGenres(t *testing.T) {
 	// TODO: this just needs some brain power to get through
 	// -- The getArtists call in the middle of the method will
@@ -135,6 +190,30 @@ func TestTracksIDs(t *testing.T) {
 	assert.Equal(t, []string{"1", "2"}, ts.IDs())
 }
 
+func TestTracksURIs(t *testing.T) {
+	ts := Tracks{Track{URI: "spotify:track:1"}, Track{URI: "spotify:track:2"}}
+	assert.Equal(t, []string{"spotify:track:1", "spotify:track:2"}, ts.URIs())
+}
+
+func TestTrackGenres(t *testing.T) {
+	ts := Tracks{
+		Track{ID: "1", Artists: []Artist{{ID: "a"}, {ID: "b"}}},
+		Track{ID: "2", Artists: []Artist{{ID: "b"}}},
+		Track{ID: "3", Artists: []Artist{{ID: "c"}}},
+	}
+	artists := Artists{
+		{ID: "a", Genres: []string{"punk", "pop punk"}},
+		{ID: "b", Genres: []string{"pop punk", "emo"}},
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, ts.ArtistIDs())
+
+	genres := ts.TrackGenres(artists)
+	assert.Equal(t, []string{"punk", "pop punk", "emo"}, genres["1"])
+	assert.Equal(t, []string{"pop punk", "emo"}, genres["2"])
+	assert.Equal(t, []string{}, genres["3"])
+}
+
 func TestFindArtist(t *testing.T) {
 	t.Run("NoArtist", func(t *testing.T) {
 		tr := Track{}
@@ -95,12 +95,12 @@ func GetSavedTracks(ctx context.Context) (*Tracks, error) {
 		t, newUrl, tot, err := getChunkOfUserLibraryTracks(ctx, url)
 		if err != nil {
 			logging.GetLogger(ctx).Warn(err.Error())
-			more = false
+			return nil, err
 		}
 		url = newUrl
 
 		ret = append(ret, *t...)
-		if tot == len(ret) {
+		if tot == len(ret) || len(url) < 1 {
 			more = false
 		}
 	}
@@ -0,0 +1,94 @@
+package wordcloud
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps rendered clouds in memory, for when there isn't a shared
+// cache to put them in. Once it's full the oldest render is dropped.
+type Cache struct {
+	TTL        time.Duration
+	MaxEntries int
+
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	render []byte
+	added  time.Time
+}
+
+// ----
+// Members
+// ----
+
+// Get returns the render stored under the key, as long as it hasn't
+// expired
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if c.time().Sub(e.added) >= c.TTL {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.render, true
+}
+
+// Set stores the render under the key, making room for it if the cache is
+// full
+func (c *Cache) Set(key string, render []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+
+	now := c.time()
+	c.entries[key] = cacheEntry{render: render, added: now}
+	if len(c.entries) > c.MaxEntries {
+		c.evict(now)
+	}
+}
+
+// ----
+// Helpers
+// ----
+
+func (c *Cache) time() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+
+	return time.Now()
+}
+
+// evict drops the expired entries, then the oldest until the cache is back
+// under its limit. The lock must be held.
+func (c *Cache) evict(now time.Time) {
+	for key, e := range c.entries {
+		if now.Sub(e.added) >= c.TTL {
+			delete(c.entries, key)
+		}
+	}
+
+	for len(c.entries) > c.MaxEntries {
+		oldest := ""
+		for key, e := range c.entries {
+			if len(oldest) < 1 || e.added.Before(c.entries[oldest].added) {
+				oldest = key
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,47 @@
+package wordcloud
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := &Cache{TTL: time.Hour, MaxEntries: 2}
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", []byte("render"))
+	b, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("render"), b)
+
+	t.Run("Expired", func(t *testing.T) {
+		c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		defer func() { c.now = nil }()
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, len(c.entries))
+	})
+
+	t.Run("Evicted", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			added := start.Add(time.Duration(i) * time.Minute)
+			c.now = func() time.Time { return added }
+			c.Set(fmt.Sprint(i), []byte{byte(i)})
+		}
+		c.now = nil
+
+		// the oldest is dropped to make room
+		assert.Equal(t, 2, len(c.entries))
+		_, ok := c.Get("0")
+		assert.False(t, ok)
+		_, ok = c.Get("2")
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,338 @@
+package wordcloud
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/mike-webster/spotify-views/sortablemap"
+	"golang.org/x/image/font"
+)
+
+const (
+	MaskNone    = "none"
+	MaskCircle  = "circle"
+	MaskDiamond = "diamond"
+
+	// a word is skipped when there's no room for it, and the cloud is done
+	// once this many in a row don't fit
+	maxMisses = 10
+	// a word that doesn't fit is tried again at this much of its size
+	shrink = 0.85
+	// the space between words, relative to their size
+	wordGap = 0.1
+)
+
+// Options are how a cloud is laid out and drawn
+type Options struct {
+	Width  int
+	Height int
+	// FontFile is the ttf the words are measured and drawn with. An svg
+	// asks the browser for FontFamily and FontWeight instead, since the
+	// file isn't embedded.
+	FontFile   string
+	FontFamily string
+	FontWeight int
+	Background string
+	// Colors are taken in turns, biggest word first
+	Colors []string
+	// Mask is the shape the words are kept inside of, stretched to fill
+	// the cloud
+	Mask string
+	// MaxFontSize and MinFontSize default to sizes relative to the shorter
+	// side of the cloud
+	MaxFontSize float64
+	MinFontSize float64
+}
+
+// Word is a word that's been placed in the cloud. X and Y are its center.
+type Word struct {
+	Word     string
+	FontSize float64
+	X        float64
+	Y        float64
+	Width    float64
+	Height   float64
+	Color    string
+}
+
+// Cloud is a set of words laid out so none of them overlap
+type Cloud struct {
+	Options
+	Words []Word
+
+	faces map[float64]font.Face
+}
+
+// ----
+// API
+// ----
+
+// New lays out the words, biggest first, as close to the middle as they
+// fit. Words are skipped when there's no room left for them.
+func New(words sortablemap.Map, opts Options) (*Cloud, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	ret := Cloud{Options: opts, Words: []Word{}, faces: map[float64]font.Face{}}
+	if err := ret.layout(words); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// ----
+// Members
+// ----
+
+// Validate makes sure the cloud can be drawn, filling in the defaults for
+// anything that wasn't provided.
+func (o *Options) Validate() error {
+	if o.Width < 1 || o.Height < 1 {
+		return errors.New(fmt.Sprint("invalid word cloud size: ", o.Width, "x", o.Height))
+	}
+
+	if len(o.FontFile) < 1 {
+		return errors.New("no word cloud font provided")
+	}
+
+	if len(o.Colors) < 1 {
+		return errors.New("no word cloud colors provided")
+	}
+
+	switch o.Mask {
+	case "":
+		o.Mask = MaskNone
+	case MaskNone, MaskCircle, MaskDiamond:
+	default:
+		return errors.New(fmt.Sprint("unknown word cloud mask: ", o.Mask))
+	}
+
+	side := math.Min(float64(o.Width), float64(o.Height))
+	if o.MaxFontSize <= 0 {
+		o.MaxFontSize = side / 7
+	}
+	if o.MinFontSize <= 0 {
+		o.MinFontSize = math.Min(math.Max(side/80, 8), o.MaxFontSize)
+	}
+	if o.MinFontSize > o.MaxFontSize {
+		return errors.New(fmt.Sprint("min font size ", o.MinFontSize, " is bigger than the max ", o.MaxFontSize))
+	}
+
+	return nil
+}
+
+// Image draws the cloud on its background
+func (c *Cloud) Image() (image.Image, error) {
+	dc := gg.NewContext(c.Width, c.Height)
+	if len(c.Background) > 0 {
+		dc.SetHexColor(c.Background)
+		dc.Clear()
+	}
+
+	for _, w := range c.Words {
+		f, err := c.face(w.FontSize)
+		if err != nil {
+			return nil, err
+		}
+		dc.SetFontFace(f)
+		dc.SetHexColor(w.Color)
+		dc.DrawStringAnchored(w.Word, w.X, w.Y, 0.5, 0.5)
+	}
+
+	return dc.Image(), nil
+}
+
+// PNG draws the cloud and encodes it as a png
+func (c *Cloud) PNG() ([]byte, error) {
+	img, err := c.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG writes the cloud as an svg. The measured width is kept with
+// textLength so a fallback font can't make the words overlap.
+func (c *Cloud) SVG() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		c.Width, c.Height, c.Width, c.Height))
+	if len(c.Background) > 0 {
+		sb.WriteString(fmt.Sprintf(`<rect width="100%%" height="100%%" fill="%s"/>`, html.EscapeString(c.Background)))
+	}
+	sb.WriteString(`<g`)
+	if len(c.FontFamily) > 0 {
+		sb.WriteString(fmt.Sprintf(` font-family="%s"`, html.EscapeString(c.FontFamily)))
+	}
+	if c.FontWeight > 0 {
+		sb.WriteString(fmt.Sprintf(` font-weight="%d"`, c.FontWeight))
+	}
+	sb.WriteString(` text-anchor="middle" dominant-baseline="central">`)
+	for _, w := range c.Words {
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" font-size="%.0f" fill="%s" textLength="%.1f" lengthAdjust="spacingAndGlyphs">%s</text>`,
+			w.X, w.Y, w.FontSize, html.EscapeString(w.Color), w.Width, html.EscapeString(w.Word)))
+	}
+	sb.WriteString("</g></svg>")
+
+	return sb.String()
+}
+
+// ----
+// Helpers
+// ----
+
+// layout places each word as close to the middle as it fits, walking out
+// along a spiral. Bigger words are placed first, and ties are broken by the
+// word so the same counts always make the same cloud.
+func (c *Cloud) layout(words sortablemap.Map) error {
+	if len(words) < 1 {
+		return nil
+	}
+
+	sorted := append(sortablemap.Map{}, words...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Value != sorted[j].Value {
+			return sorted[i].Value > sorted[j].Value
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	most := float64(sorted[0].Value)
+	least := float64(sorted[len(sorted)-1].Value)
+	dc := gg.NewContext(1, 1)
+
+	misses := 0
+	for _, w := range sorted {
+		scale := 1.0
+		if most > least {
+			scale = (float64(w.Value) - least) / (most - least)
+		}
+
+		// a word that doesn't fit is tried smaller before it's skipped
+		fits := false
+		placed := Word{Word: w.Key, Color: c.Colors[len(c.Words)%len(c.Colors)]}
+		for size := math.Round(c.MinFontSize + (c.MaxFontSize-c.MinFontSize)*scale); size >= c.MinFontSize && !fits; size = math.Floor(size * shrink) {
+			f, err := c.face(size)
+			if err != nil {
+				return err
+			}
+			dc.SetFontFace(f)
+			placed.FontSize = size
+			placed.Width, placed.Height = dc.MeasureString(w.Key)
+			fits = c.place(&placed)
+		}
+
+		if !fits {
+			misses++
+			if misses >= maxMisses {
+				break
+			}
+			continue
+		}
+
+		misses = 0
+		c.Words = append(c.Words, placed)
+	}
+
+	return nil
+}
+
+// place finds the first spot along the spiral where the word fits in the
+// mask without overlapping anything already placed, and returns false if
+// there isn't one
+func (c *Cloud) place(w *Word) bool {
+	centerX := float64(c.Width) / 2
+	centerY := float64(c.Height) / 2
+	growth := math.Min(centerX, centerY) / 200
+
+	for a := 0.0; growth*a < math.Hypot(centerX, centerY); a += 0.05 {
+		w.X = centerX + growth*a*math.Cos(a)
+		w.Y = centerY + growth*a*math.Sin(a)
+		if !c.inMask(*w) {
+			continue
+		}
+
+		overlaps := false
+		for _, p := range c.Words {
+			if overlap(*w, p) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inMask checks every corner of the word is inside the mask. The masks
+// are convex, so the rest of the word is too.
+func (c *Cloud) inMask(w Word) bool {
+	halfX := float64(c.Width) / 2
+	halfY := float64(c.Height) / 2
+	pad := w.FontSize * wordGap
+	for _, dx := range []float64{-1, 1} {
+		for _, dy := range []float64{-1, 1} {
+			// relative to the middle, so the edges are at -1 and 1
+			x := (w.X + dx*(w.Width/2+pad) - halfX) / halfX
+			y := (w.Y + dy*(w.Height/2+pad) - halfY) / halfY
+
+			switch c.Mask {
+			case MaskCircle:
+				if x*x+y*y > 1 {
+					return false
+				}
+			case MaskDiamond:
+				if math.Abs(x)+math.Abs(y) > 1 {
+					return false
+				}
+			default:
+				if math.Abs(x) > 1 || math.Abs(y) > 1 {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// face loads the font at the size, keeping it for the next word that's
+// the same size
+func (c *Cloud) face(size float64) (font.Face, error) {
+	if f, ok := c.faces[size]; ok {
+		return f, nil
+	}
+
+	f, err := gg.LoadFontFace(c.FontFile, size)
+	if err != nil {
+		return nil, err
+	}
+	c.faces[size] = f
+
+	return f, nil
+}
+
+// overlap checks if the words, along with the gap around them, overlap
+func overlap(a, b Word) bool {
+	gap := (a.FontSize + b.FontSize) * wordGap
+	return math.Abs(a.X-b.X)*2 < a.Width+b.Width+gap && math.Abs(a.Y-b.Y)*2 < a.Height+b.Height+gap
+}
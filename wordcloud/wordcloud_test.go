@@ -0,0 +1,177 @@
+package wordcloud
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mike-webster/spotify-views/sortablemap"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFont = "../web/fonts/Ubuntu-L.ttf"
+
+func testWords(n int) sortablemap.Map {
+	ret := sortablemap.Map{}
+	for i := 0; i < n; i++ {
+		ret = append(ret, sortablemap.Item{Key: fmt.Sprint("word", i), Value: int32(n - i)})
+	}
+	return ret
+}
+
+func TestOptionsValidate(t *testing.T) {
+	o := Options{Width: 800, Height: 400, FontFile: testFont, Colors: []string{"#000000"}}
+	assert.Nil(t, o.Validate())
+	assert.Equal(t, MaskNone, o.Mask)
+	// the defaults are relative to the shorter side
+	assert.InDelta(t, 400.0/7, o.MaxFontSize, 0.0001)
+	assert.Equal(t, 8.0, o.MinFontSize)
+
+	for _, o := range []Options{
+		{Height: 400, FontFile: testFont, Colors: []string{"#000000"}},
+		{Width: 400, Height: 400, Colors: []string{"#000000"}},
+		{Width: 400, Height: 400, FontFile: testFont},
+		{Width: 400, Height: 400, FontFile: testFont, Colors: []string{"#000000"}, Mask: "heart"},
+		{Width: 400, Height: 400, FontFile: testFont, Colors: []string{"#000000"}, MaxFontSize: 10, MinFontSize: 20},
+	} {
+		assert.NotNil(t, o.Validate())
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, mask := range []string{MaskNone, MaskCircle, MaskDiamond} {
+		t.Run(mask, func(t *testing.T) {
+			c, err := New(testWords(40), Options{
+				Width:    600,
+				Height:   300,
+				FontFile: testFont,
+				Colors:   []string{"#111111", "#222222"},
+				Mask:     mask,
+			})
+			assert.Nil(t, err)
+			assert.True(t, len(c.Words) > 0)
+
+			// the biggest word is placed first, right in the middle
+			assert.Equal(t, "word0", c.Words[0].Word)
+			assert.Equal(t, math.Round(c.MaxFontSize), c.Words[0].FontSize)
+			assert.Equal(t, 300.0, c.Words[0].X)
+			assert.Equal(t, 150.0, c.Words[0].Y)
+
+			for i, w := range c.Words {
+				assert.Equal(t, c.Colors[i%2], w.Color)
+				assert.True(t, c.inMask(w), w.Word)
+				for _, o := range c.Words[i+1:] {
+					assert.False(t, overlap(w, o), fmt.Sprint(w.Word, " overlaps ", o.Word))
+				}
+			}
+		})
+	}
+
+	t.Run("Deterministic", func(t *testing.T) {
+		words := sortablemap.Map{{Key: "b", Value: 2}, {Key: "c", Value: 1}, {Key: "a", Value: 2}}
+		opts := Options{Width: 300, Height: 300, FontFile: testFont, Colors: []string{"#000000"}}
+
+		first, err := New(words, opts)
+		assert.Nil(t, err)
+		second, err := New(sortablemap.Map{words[2], words[1], words[0]}, opts)
+		assert.Nil(t, err)
+
+		// ties are broken by the word, whatever order they came in
+		assert.Equal(t, first.Words, second.Words)
+		assert.Equal(t, "a", first.Words[0].Word)
+		assert.Equal(t, "b", first.Words[1].Word)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		// there's only room for a few words, so it gives up instead of
+		// trying all of them
+		c, err := New(testWords(500), Options{
+			Width: 100, Height: 100, FontFile: testFont, Colors: []string{"#000000"},
+			MaxFontSize: 40, MinFontSize: 30,
+		})
+		assert.Nil(t, err)
+		assert.True(t, len(c.Words) < 10)
+	})
+
+	t.Run("NoWords", func(t *testing.T) {
+		c, err := New(sortablemap.Map{}, Options{Width: 100, Height: 100, FontFile: testFont, Colors: []string{"#000000"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []Word{}, c.Words)
+	})
+
+	t.Run("MissingFont", func(t *testing.T) {
+		_, err := New(testWords(1), Options{Width: 100, Height: 100, FontFile: "nope.ttf", Colors: []string{"#000000"}})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestCloudDraw(t *testing.T) {
+	c, err := New(sortablemap.Map{{Key: "<rock & roll>", Value: 2}, {Key: "punk", Value: 1}}, Options{
+		Width:      320,
+		Height:     200,
+		FontFile:   testFont,
+		FontFamily: `"Ubuntu", sans-serif`,
+		FontWeight: 300,
+		Background: "#FFFFFF",
+		Colors:     []string{"#1ED760"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(c.Words))
+
+	t.Run("PNG", func(t *testing.T) {
+		b, err := c.PNG()
+		assert.Nil(t, err)
+
+		img, err := png.Decode(bytes.NewReader(b))
+		assert.Nil(t, err)
+		assert.Equal(t, 320, img.Bounds().Dx())
+		assert.Equal(t, 200, img.Bounds().Dy())
+
+		// the corner is background, and the middle is part of a word
+		r, g, b2, _ := img.At(0, 0).RGBA()
+		assert.Equal(t, []uint32{0xFFFF, 0xFFFF, 0xFFFF}, []uint32{r, g, b2})
+		found := false
+		for x := int(c.Words[0].X - c.Words[0].Width/2); x < int(c.Words[0].X+c.Words[0].Width/2) && !found; x++ {
+			r, _, _, _ := img.At(x, int(c.Words[0].Y)).RGBA()
+			found = r < 0xFFFF
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("SVG", func(t *testing.T) {
+		svg := c.SVG()
+		assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="320" height="200" viewBox="0 0 320 200">`))
+		assert.Contains(t, svg, `<rect width="100%" height="100%" fill="#FFFFFF"/>`)
+		assert.Contains(t, svg, `font-family="&#34;Ubuntu&#34;, sans-serif" font-weight="300"`)
+		assert.Contains(t, svg, `>&lt;rock &amp; roll&gt;</text>`)
+		assert.Equal(t, 2, strings.Count(svg, "<text "))
+		assert.Contains(t, svg, fmt.Sprintf(`textLength="%.1f"`, c.Words[0].Width))
+		assert.True(t, strings.HasSuffix(svg, "</g></svg>"))
+	})
+}
+
+func TestInMask(t *testing.T) {
+	c := Cloud{Options: Options{Width: 200, Height: 100}}
+	// a small word in the corner only fits when nothing is masked off
+	w := Word{X: 8, Y: 8, Width: 6, Height: 6}
+
+	for mask, in := range map[string]bool{MaskNone: true, MaskCircle: false, MaskDiamond: false} {
+		c.Mask = mask
+		assert.Equal(t, in, c.inMask(w), mask)
+	}
+
+	// the middle fits in every mask
+	w.X, w.Y = 100, 50
+	for _, mask := range []string{MaskNone, MaskCircle, MaskDiamond} {
+		c.Mask = mask
+		assert.True(t, c.inMask(w), mask)
+	}
+
+	// anything hanging off the edge never fits
+	w.X = 199
+	c.Mask = MaskNone
+	assert.False(t, c.inMask(w))
+}